@@ -0,0 +1,158 @@
+package audit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"json-schema-validation/lib/tkt"
+	"sync"
+	"time"
+)
+
+const (
+	VerdictAccepted  = "accepted"
+	VerdictRejected  = "rejected"
+	VerdictMalformed = "malformed"
+)
+
+// Entry is one validation decision. Entries are chained: Hash covers every other field,
+// PrevHash included, so changing, removing or reordering entries breaks the chain.
+type Entry struct {
+	Sequence      int64     `json:"sequence"`
+	Time          time.Time `json:"time"`
+	RequestId     string    `json:"requestId"`
+	Caller        string    `json:"caller"`
+	Sender        string    `json:"sender,omitempty"`
+	Receiver      string    `json:"receiver,omitempty"`
+	SchemaVersion string    `json:"schemaVersion"`
+	SchemaHash    string    `json:"schemaHash"`
+	Verdict       string    `json:"verdict"`
+	ErrorCount    int       `json:"errorCount"`
+	Errors        []string  `json:"errors,omitempty"`
+	PayloadDigest string    `json:"payloadDigest"`
+	PrevHash      string    `json:"prevHash"`
+	Hash          string    `json:"hash"`
+}
+
+// ComputeHash returns the hex SHA-256 of the entry JSON with an empty Hash and a UTC time.
+func (o *Entry) ComputeHash() string {
+	clone := *o
+	clone.Hash = ""
+	clone.Time = o.Time.UTC()
+	h := sha256.Sum256(tkt.Marshal(clone))
+	return hex.EncodeToString(h[:])
+}
+
+// Sink stores entries. Append and Last panic on storage errors, as the tkt helpers do.
+type Sink interface {
+	Append(entry *Entry)
+	Last() *Entry
+	Entries(visit func(entry *Entry) error) error
+}
+
+// Trail appends chained entries to its sinks. The chain resumes from the last entry of
+// the first sink.
+type Trail struct {
+	sinks    []Sink
+	sequence int64
+	prevHash string
+	mux      sync.Mutex
+}
+
+// Record completes entry with its sequence, time and hashes and appends it to every sink.
+// The chain advances as soon as the first sink has the entry, the one it resumes from, so
+// a later sink that panics misses that entry but never makes the next one reuse its
+// sequence.
+func (o *Trail) Record(entry *Entry) {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	entry.Sequence = o.sequence + 1
+	entry.Time = time.Now().UTC().Truncate(time.Microsecond)
+	entry.PrevHash = o.prevHash
+	entry.Hash = entry.ComputeHash()
+	for i, sink := range o.sinks {
+		sink.Append(entry)
+		if i == 0 {
+			o.sequence = entry.Sequence
+			o.prevHash = entry.Hash
+		}
+	}
+}
+
+func NewTrail(sinks ...Sink) *Trail {
+	trail := &Trail{sinks: sinks}
+	if len(sinks) > 0 {
+		if last := sinks[0].Last(); last != nil {
+			trail.sequence = last.Sequence
+			trail.prevHash = last.Hash
+		}
+	}
+	return trail
+}
+
+type VerificationError struct {
+	Sequence int64
+	Reason   string
+}
+
+func (o *VerificationError) Error() string {
+	return fmt.Sprintf("entry %d: %s", o.Sequence, o.Reason)
+}
+
+type VerificationResult struct {
+	First int64
+	Last  int64
+	Count int64
+}
+
+// Verify walks the entries of sink checking hashes, sequence numbers and links. The chain
+// must start at sequence 1, so that removing its first entries is detected.
+func Verify(sink Sink) (VerificationResult, error) {
+	return VerifyFrom(sink, 1)
+}
+
+// VerifyFrom verifies a chain that starts at sequence first, for sinks whose older entries
+// were removed on purpose.
+func VerifyFrom(sink Sink, first int64) (VerificationResult, error) {
+	result := VerificationResult{}
+	var prev *Entry
+	err := sink.Entries(func(entry *Entry) error {
+		if entry.Hash != entry.ComputeHash() {
+			return &VerificationError{Sequence: entry.Sequence, Reason: "hash does not match content"}
+		}
+		if prev == nil {
+			if entry.Sequence != first {
+				return &VerificationError{Sequence: entry.Sequence, Reason: fmt.Sprintf("chain starts here instead of at entry %d", first)}
+			}
+			if entry.Sequence == 1 && entry.PrevHash != "" {
+				return &VerificationError{Sequence: entry.Sequence, Reason: "first entry links to a previous one"}
+			}
+			result.First = entry.Sequence
+		} else {
+			if entry.Sequence != prev.Sequence+1 {
+				return &VerificationError{Sequence: entry.Sequence, Reason: fmt.Sprintf("follows entry %d", prev.Sequence)}
+			}
+			if entry.PrevHash != prev.Hash {
+				return &VerificationError{Sequence: entry.Sequence, Reason: "previous hash does not match entry " + fmt.Sprint(prev.Sequence)}
+			}
+		}
+		prev = entry
+		result.Last = entry.Sequence
+		result.Count++
+		return nil
+	})
+	return result, err
+}
+
+// PayloadDigest returns the SHA-256 of the JSON of a decoded payload as sanitized by the
+// given sanitizer, so that the audit trail can match payloads without holding secrets.
+func PayloadDigest(sanitizer *tkt.Sanitizer, value interface{}) string {
+	h := sha256.Sum256([]byte(sanitizer.SanitizeObject(value)))
+	return "sha256:" + hex.EncodeToString(h[:])
+}
+
+// RawDigest returns the SHA-256 of a payload as received, for payloads that were not
+// decoded: malformed or streamed ones.
+func RawDigest(sum []byte) string {
+	return "sha256-raw:" + hex.EncodeToString(sum)
+}
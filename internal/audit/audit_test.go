@@ -0,0 +1,92 @@
+package audit
+
+import (
+	"errors"
+	"testing"
+)
+
+// memorySink keeps entries in memory; failing makes Append panic.
+type memorySink struct {
+	entries []*Entry
+	failing bool
+}
+
+func (o *memorySink) Append(entry *Entry) {
+	if o.failing {
+		panic(errors.New("sink unavailable"))
+	}
+	clone := *entry
+	o.entries = append(o.entries, &clone)
+}
+
+func (o *memorySink) Last() *Entry {
+	if len(o.entries) == 0 {
+		return nil
+	}
+	return o.entries[len(o.entries)-1]
+}
+
+func (o *memorySink) Entries(visit func(entry *Entry) error) error {
+	for _, entry := range o.entries {
+		if err := visit(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func record(trail *Trail, entry *Entry) (failed bool) {
+	defer func() {
+		failed = recover() != nil
+	}()
+	trail.Record(entry)
+	return false
+}
+
+func TestRecordAdvancesOnceTheFirstSinkHasTheEntry(t *testing.T) {
+	first, second := &memorySink{}, &memorySink{}
+	trail := NewTrail(first, second)
+	record(trail, &Entry{RequestId: "1"})
+	second.failing = true
+	if !record(trail, &Entry{RequestId: "2"}) {
+		t.Fatal("a failing sink must fail the record")
+	}
+	second.failing = false
+	record(trail, &Entry{RequestId: "3"})
+	if result, err := Verify(first); err != nil || result.Count != 3 {
+		t.Fatalf("first sink: %+v, %v", result, err)
+	}
+	if last := second.Last(); last.Sequence != 3 {
+		t.Errorf("second sink: last entry %d, want 3", last.Sequence)
+	}
+}
+
+func TestRecordDoesNotAdvanceWhenTheFirstSinkFails(t *testing.T) {
+	first := &memorySink{}
+	trail := NewTrail(first)
+	first.failing = true
+	record(trail, &Entry{RequestId: "1"})
+	first.failing = false
+	record(trail, &Entry{RequestId: "2"})
+	if result, err := Verify(first); err != nil || result.Count != 1 {
+		t.Fatalf("%+v, %v", result, err)
+	}
+}
+
+func TestVerifyRequiresTheFirstEntry(t *testing.T) {
+	sink := &memorySink{}
+	trail := NewTrail(sink)
+	for i := 0; i < 3; i++ {
+		trail.Record(&Entry{})
+	}
+	sink.entries = sink.entries[1:]
+	_, err := Verify(sink)
+	var verification *VerificationError
+	if !errors.As(err, &verification) || verification.Sequence != 2 {
+		t.Fatalf("error %v, want one at entry 2", err)
+	}
+	result, err := VerifyFrom(sink, 2)
+	if err != nil || result.First != 2 || result.Last != 3 {
+		t.Errorf("from 2: %+v, %v", result, err)
+	}
+}
@@ -0,0 +1,116 @@
+package audit
+
+import (
+	"testing"
+)
+
+// sliceSink keeps copies of the entries appended to it.
+type sliceSink struct {
+	entries []*Entry
+}
+
+func (o *sliceSink) Append(entry *Entry) {
+	clone := *entry
+	o.entries = append(o.entries, &clone)
+}
+
+func (o *sliceSink) Last() *Entry {
+	if len(o.entries) == 0 {
+		return nil
+	}
+	return o.entries[len(o.entries)-1]
+}
+
+func (o *sliceSink) Entries(visit func(entry *Entry) error) error {
+	for _, entry := range o.entries {
+		if err := visit(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func chain(n int) *sliceSink {
+	sink := &sliceSink{}
+	trail := NewTrail(sink)
+	for i := 0; i < n; i++ {
+		trail.Record(&Entry{RequestId: string(rune('a' + i)), Verdict: VerdictAccepted})
+	}
+	return sink
+}
+
+func TestRecordChainsEntries(t *testing.T) {
+	first, second := &sliceSink{}, &sliceSink{}
+	trail := NewTrail(first, second)
+	for i := 0; i < 3; i++ {
+		trail.Record(&Entry{Verdict: VerdictRejected, ErrorCount: i})
+	}
+	for i, entry := range first.entries {
+		if entry.Sequence != int64(i+1) || entry.Hash != entry.ComputeHash() || entry.Time.IsZero() {
+			t.Errorf("entry %d: %+v", i, entry)
+		}
+		if i > 0 && entry.PrevHash != first.entries[i-1].Hash {
+			t.Errorf("entry %d does not link to the previous one", i)
+		}
+		if second.entries[i].Hash != entry.Hash {
+			t.Errorf("entry %d differs between sinks", i)
+		}
+	}
+	if first.entries[0].PrevHash != "" {
+		t.Errorf("first entry links to %q", first.entries[0].PrevHash)
+	}
+	if result, err := Verify(first); err != nil || result != (VerificationResult{First: 1, Last: 3, Count: 3}) {
+		t.Errorf("Verify: %+v, %v", result, err)
+	}
+}
+
+func TestNewTrailResumesTheChain(t *testing.T) {
+	sink := chain(2)
+	NewTrail(sink).Record(&Entry{})
+	if result, err := Verify(sink); err != nil || result.Count != 3 {
+		t.Errorf("Verify: %+v, %v", result, err)
+	}
+}
+
+func TestVerifyDetectsTampering(t *testing.T) {
+	tests := []struct {
+		name   string
+		tamper func(s *sliceSink)
+		want   string
+	}{
+		{"changed field", func(s *sliceSink) { s.entries[1].Verdict = VerdictAccepted + "!" }, "entry 2: hash does not match content"},
+		{"removed entry", func(s *sliceSink) { s.entries = append(s.entries[:1], s.entries[2:]...) }, "entry 3: follows entry 1"},
+		{"swapped entries", func(s *sliceSink) { s.entries[1], s.entries[2] = s.entries[2], s.entries[1] }, "entry 3: follows entry 1"},
+		{"rehashed entry", func(s *sliceSink) {
+			s.entries[1].Caller = "someone else"
+			s.entries[1].Hash = s.entries[1].ComputeHash()
+		}, "entry 3: previous hash does not match entry 2"},
+		{"relinked first entry", func(s *sliceSink) {
+			s.entries[0].PrevHash = "00"
+			s.entries[0].Hash = s.entries[0].ComputeHash()
+		}, "entry 1: first entry links to a previous one"},
+	}
+	for _, test := range tests {
+		sink := chain(3)
+		test.tamper(sink)
+		_, err := Verify(sink)
+		if _, ok := err.(*VerificationError); !ok || err.Error() != test.want {
+			t.Errorf("%s: %v, want %s", test.name, err, test.want)
+		}
+	}
+}
+
+func TestComputeHashIgnoresTimeZone(t *testing.T) {
+	entry := chain(1).entries[0]
+	local := *entry
+	local.Time = entry.Time.Local()
+	if local.ComputeHash() != entry.Hash {
+		t.Error("the hash depends on the time zone")
+	}
+}
+
+func TestRawDigest(t *testing.T) {
+	if d := RawDigest([]byte{0xab, 0x01}); d != "sha256-raw:ab01" {
+		t.Errorf("RawDigest = %q", d)
+	}
+}
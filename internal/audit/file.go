@@ -0,0 +1,105 @@
+package audit
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"json-schema-validation/lib/tkt"
+	"os"
+	"strings"
+)
+
+const maxEntrySize = 1 << 20
+
+// FileSink writes one JSON entry per line through a LogWriter, which rotates and may
+// compress the files; it must not remove them if the chain is to be verified from the
+// start.
+type FileSink struct {
+	writer *tkt.LogWriter
+}
+
+func (o *FileSink) Append(entry *Entry) {
+	line := append(tkt.Marshal(entry), '\n')
+	_, err := o.writer.Write(line)
+	tkt.CheckErr(err)
+}
+
+func (o *FileSink) Last() *Entry {
+	files, err := o.writer.Files()
+	tkt.CheckErr(err)
+	for i := len(files) - 1; i >= 0; i-- {
+		var last *Entry
+		tkt.CheckErr(readEntries(files[i], func(entry *Entry) error {
+			last = entry
+			return nil
+		}))
+		if last != nil {
+			return last
+		}
+	}
+	return nil
+}
+
+func (o *FileSink) Entries(visit func(entry *Entry) error) error {
+	files, err := o.writer.Files()
+	if err != nil {
+		return err
+	}
+	gzipped := make(map[string]bool)
+	for _, name := range files {
+		if strings.HasSuffix(name, ".gz") {
+			gzipped[strings.TrimSuffix(name, ".gz")] = true
+		}
+	}
+	for _, name := range files {
+		// A rotated file is removed once its gzipped copy is complete; until then both exist.
+		if gzipped[name] {
+			continue
+		}
+		if err := readEntries(name, visit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readEntries(name string, visit func(entry *Entry) error) error {
+	file, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	var r io.Reader = file
+	if strings.HasSuffix(name, ".gz") {
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		defer zr.Close()
+		r = zr
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxEntrySize)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		entry := &Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), entry); err != nil {
+			return fmt.Errorf("%s:%d: %w", name, line, err)
+		}
+		if err := visit(entry); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	return nil
+}
+
+func NewFileSink(writer *tkt.LogWriter) *FileSink {
+	return &FileSink{writer: writer}
+}
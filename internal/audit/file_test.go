@@ -0,0 +1,85 @@
+package audit
+
+import (
+	"compress/gzip"
+	"io"
+	"json-schema-validation/lib/tkt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileSinkAcrossRotations(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "audit.log")
+	writer := &tkt.LogWriter{FileName: name, MaxSize: 600}
+	sink := NewFileSink(writer)
+	trail := NewTrail(sink)
+	for i := 0; i < 5; i++ {
+		trail.Record(&Entry{Verdict: VerdictRejected, Errors: []string{"/name: expected string, but got number"}})
+	}
+	writer.Close()
+	if files, _ := writer.Files(); len(files) < 3 {
+		t.Fatalf("files %v, want several rotated files", files)
+	}
+	if result, err := Verify(sink); err != nil || result != (VerificationResult{First: 1, Last: 5, Count: 5}) {
+		t.Fatalf("Verify: %+v, %v", result, err)
+	}
+
+	reopened := NewFileSink(&tkt.LogWriter{FileName: name, MaxSize: 600})
+	if last := reopened.Last(); last == nil || last.Sequence != 5 {
+		t.Fatalf("last entry %+v", last)
+	}
+	NewTrail(reopened).Record(&Entry{Verdict: VerdictAccepted})
+	if result, err := Verify(reopened); err != nil || result.Last != 6 {
+		t.Errorf("Verify after reopening: %+v, %v", result, err)
+	}
+}
+
+func TestFileSinkReportsCorruptLines(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "audit.log")
+	writer := &tkt.LogWriter{FileName: name}
+	sink := NewFileSink(writer)
+	NewTrail(sink).Record(&Entry{})
+	writer.Close()
+	f, err := os.OpenFile(name, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString("\n{not json\n")
+	f.Close()
+	if _, err := Verify(sink); err == nil {
+		t.Error("a corrupt line verified")
+	}
+}
+
+func TestFileSinkSkipsFilesBeingCompressed(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "audit.log")
+	writer := &tkt.LogWriter{FileName: name, MaxSize: 600}
+	sink := NewFileSink(writer)
+	trail := NewTrail(sink)
+	for i := 0; i < 3; i++ {
+		trail.Record(&Entry{Verdict: VerdictRejected, Errors: []string{"/name: expected string, but got number"}})
+	}
+	writer.Close()
+	files, _ := writer.Files()
+	if len(files) < 2 {
+		t.Fatalf("files %v, want a rotated file", files)
+	}
+	// Compress the oldest file as the writer does, stopping before it removes the original.
+	in, err := os.Open(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := os.Create(files[0] + ".gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := gzip.NewWriter(out)
+	io.Copy(zw, in)
+	zw.Close()
+	out.Close()
+	in.Close()
+	if result, err := Verify(sink); err != nil || result != (VerificationResult{First: 1, Last: 3, Count: 3}) {
+		t.Errorf("Verify: %+v, %v", result, err)
+	}
+}
@@ -0,0 +1,20 @@
+package audit
+
+import (
+	"embed"
+	"json-schema-validation/lib/tkt"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// NewMigrator returns the migrator of the audit tables of a database schema. Applied
+// migrations are recorded in <schema>.SchemaMigration. Scripts see the quoted schema as
+// ${schema} and the column type of instants as ${timestamp}.
+func NewMigrator(config tkt.DatabaseConfig, schema string) *tkt.Migrator {
+	migrations, err := tkt.LoadMigrations(migrationFiles, "migrations")
+	tkt.CheckErr(err)
+	dialect := tkt.DialectOf(config)
+	return tkt.NewMigrator(tkt.SharedDB(config), schema+".SchemaMigration", migrations).SetDialect(dialect).
+		SetVariables(map[string]string{"schema": dialect.QuoteIdentifier(schema), "timestamp": dialect.TimestampType()})
+}
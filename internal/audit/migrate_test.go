@@ -0,0 +1,114 @@
+package audit
+
+import (
+	"context"
+	"json-schema-validation/lib/tkt"
+	_ "modernc.org/sqlite"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func sqliteAuditConfig(t *testing.T) tkt.DatabaseConfig {
+	driver, maxOpenConns := "sqlite", 1
+	datasource := "file:" + filepath.Join(t.TempDir(), "audit.db")
+	return tkt.DatabaseConfig{DatabaseDriver: &driver, DatasourceName: &datasource, MaxOpenConns: &maxOpenConns}
+}
+
+func tables(t *testing.T, config tkt.DatabaseConfig) string {
+	t.Helper()
+	rows, err := tkt.SharedDB(config).Query("select name from sqlite_master where type = 'table' order by name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, name)
+	}
+	return strings.Join(names, " ")
+}
+
+func TestMigratorOnSqlite(t *testing.T) {
+	defer tkt.ClosePools()
+	ctx := context.Background()
+	config := sqliteAuditConfig(t)
+	migrator := NewMigrator(config, "main")
+
+	status, err := migrator.Status(ctx)
+	if err != nil || len(status) != 1 || status[0].Applied || status[0].Name != "validation_audit" {
+		t.Fatalf("status before any migration: %+v, %v", status, err)
+	}
+	if got := tables(t, config); got != "" {
+		t.Fatalf("status created the tables %s", got)
+	}
+
+	done, err := migrator.Up(ctx, 0)
+	if err != nil || len(done) != 1 || done[0].Version != 1 {
+		t.Fatalf("up: %+v, %v", done, err)
+	}
+	// ${schema} and ${timestamp} are expanded
+	if got := tables(t, config); got != "SchemaMigration ValidationAudit" {
+		t.Fatalf("tables %q after up", got)
+	}
+	if done, err := migrator.Up(ctx, 0); err != nil || len(done) != 0 {
+		t.Errorf("second up: %+v, %v", done, err)
+	}
+	status, err = migrator.Status(ctx)
+	if err != nil || len(status) != 1 || !status[0].Applied || status[0].AppliedAt.IsZero() || status[0].Modified {
+		t.Errorf("status after up: %+v, %v", status, err)
+	}
+
+	done, err = migrator.Down(ctx, 5)
+	if err != nil || len(done) != 1 {
+		t.Fatalf("down: %+v, %v", done, err)
+	}
+	if got := tables(t, config); got != "SchemaMigration" {
+		t.Errorf("tables %q after down", got)
+	}
+	if status, err := migrator.Status(ctx); err != nil || status[0].Applied {
+		t.Errorf("status after down: %+v, %v", status, err)
+	}
+	if done, err := migrator.Up(ctx, 0); err != nil || len(done) != 1 {
+		t.Errorf("up again: %+v, %v", done, err)
+	}
+}
+
+func TestMigratorReportsModifiedAndMissingMigrations(t *testing.T) {
+	defer tkt.ClosePools()
+	ctx := context.Background()
+	config := sqliteAuditConfig(t)
+	migrator := NewMigrator(config, "main")
+	if _, err := migrator.Up(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	db := tkt.SharedDB(config)
+	if _, err := db.Exec(`update "SchemaMigration" set "Checksum" = 'edited'`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`insert into "SchemaMigration" values (99, 'dropped', 'gone', current_timestamp)`); err != nil {
+		t.Fatal(err)
+	}
+
+	status, err := migrator.Status(ctx)
+	if err != nil || len(status) != 2 {
+		t.Fatalf("status: %+v, %v", status, err)
+	}
+	if !status[0].Applied || !status[0].Modified || status[0].Missing {
+		t.Errorf("modified migration: %+v", status[0])
+	}
+	if status[1].Version != 99 || status[1].Name != "dropped" || !status[1].Applied || !status[1].Missing {
+		t.Errorf("missing migration: %+v", status[1])
+	}
+	want := "migration 1_validation_audit was modified after being applied"
+	if _, err := migrator.Up(ctx, 0); err == nil || err.Error() != want {
+		t.Errorf("up: %v, want %s", err, want)
+	}
+	if _, err := migrator.Down(ctx, 1); err == nil || err.Error() != want {
+		t.Errorf("down: %v, want %s", err, want)
+	}
+}
@@ -0,0 +1,16 @@
+create table if not exists ${schema}.ValidationAudit (
+	Sequence bigint primary key,
+	Time ${timestamp} not null,
+	RequestId text not null,
+	Caller text not null,
+	Sender text not null,
+	Receiver text not null,
+	SchemaVersion text not null,
+	SchemaHash text not null,
+	Verdict text not null,
+	ErrorCount integer not null,
+	Errors text not null,
+	PayloadDigest text not null,
+	PrevHash text not null,
+	Hash text not null
+);
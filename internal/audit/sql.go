@@ -0,0 +1,113 @@
+package audit
+
+import (
+	"context"
+	"json-schema-validation/lib/tkt"
+	"strconv"
+	"time"
+)
+
+const pageSize = 1000
+
+// ValidationAudit is the row of an entry, Errors being a JSON array.
+type ValidationAudit struct {
+	Sequence      int64
+	Time          time.Time
+	RequestId     string
+	Caller        string
+	Sender        string
+	Receiver      string
+	SchemaVersion string
+	SchemaHash    string
+	Verdict       string
+	ErrorCount    int
+	Errors        string
+	PayloadDigest string
+	PrevHash      string
+	Hash          string
+}
+
+func (o *ValidationAudit) entry() *Entry {
+	entry := &Entry{Sequence: o.Sequence, Time: o.Time.UTC(), RequestId: o.RequestId, Caller: o.Caller,
+		Sender: o.Sender, Receiver: o.Receiver, SchemaVersion: o.SchemaVersion, SchemaHash: o.SchemaHash,
+		Verdict: o.Verdict, ErrorCount: o.ErrorCount, PayloadDigest: o.PayloadDigest, PrevHash: o.PrevHash, Hash: o.Hash}
+	if o.Errors != "" {
+		tkt.Unmarshal([]byte(o.Errors), &entry.Errors)
+	}
+	return entry
+}
+
+func newValidationAudit(entry *Entry) ValidationAudit {
+	row := ValidationAudit{Sequence: entry.Sequence, Time: entry.Time, RequestId: entry.RequestId, Caller: entry.Caller,
+		Sender: entry.Sender, Receiver: entry.Receiver, SchemaVersion: entry.SchemaVersion, SchemaHash: entry.SchemaHash,
+		Verdict: entry.Verdict, ErrorCount: entry.ErrorCount, PayloadDigest: entry.PayloadDigest, PrevHash: entry.PrevHash, Hash: entry.Hash}
+	if len(entry.Errors) > 0 {
+		row.Errors = string(tkt.Marshal(entry.Errors))
+	}
+	return row
+}
+
+// SqlSink inserts entries in <schema>.ValidationAudit, one transaction per entry, in any
+// database tkt has a dialect for. The Sequence primary key rejects a second writer forking
+// the chain.
+type SqlSink struct {
+	config tkt.DatabaseConfig
+	schema string
+}
+
+func (o *SqlSink) Append(entry *Entry) {
+	tkt.ExecuteTransactional(o.config, func(txCtx *tkt.TxCtx, args ...interface{}) interface{} {
+		txCtx.InsertEntity(o.schema, newValidationAudit(entry), false)
+		return nil
+	})
+}
+
+func (o *SqlSink) Last() *Entry {
+	result := tkt.ExecuteTransactional(o.config, func(txCtx *tkt.TxCtx, args ...interface{}) interface{} {
+		dialect := txCtx.Dialect()
+		return txCtx.FindStruct(ValidationAudit{}, "select "+tkt.ForSelectDialect(dialect, ValidationAudit{}, 0)+" from "+
+			o.table(dialect)+" order by "+dialect.QuoteIdentifier(dialect.FoldIdentifier("Sequence"))+" desc limit 1")
+	})
+	if row := result.(*ValidationAudit); row != nil {
+		return row.entry()
+	}
+	return nil
+}
+
+func (o *SqlSink) Entries(visit func(entry *Entry) error) error {
+	dialect := tkt.DialectOf(o.config)
+	sequence := dialect.QuoteIdentifier(dialect.FoldIdentifier("Sequence"))
+	query := "select " + tkt.ForSelectDialect(dialect, ValidationAudit{}, 0) + " from " + o.table(dialect) +
+		" where " + sequence + " > " + dialect.Placeholder(1) + " order by " + sequence + " limit " + strconv.Itoa(pageSize)
+	after := int64(0)
+	for {
+		rows := tkt.ExecuteTransactional(o.config, func(txCtx *tkt.TxCtx, args ...interface{}) interface{} {
+			return txCtx.QueryStruct(ValidationAudit{}, query, after)
+		}).([]ValidationAudit)
+		for i := range rows {
+			if err := visit(rows[i].entry()); err != nil {
+				return err
+			}
+			after = rows[i].Sequence
+		}
+		if len(rows) < pageSize {
+			return nil
+		}
+	}
+}
+
+// table returns the audit table, created by the migrations in the quoted schema with an
+// unquoted name.
+func (o *SqlSink) table(dialect tkt.Dialect) string {
+	return dialect.QuoteIdentifier(o.schema) + "." + dialect.QuoteIdentifier(dialect.FoldIdentifier("ValidationAudit"))
+}
+
+// Migrate applies the pending migrations of the audit table.
+func (o *SqlSink) Migrate() {
+	_, err := NewMigrator(o.config, o.schema).Up(context.Background(), 0)
+	tkt.CheckErr(err)
+}
+
+func NewSqlSink(config tkt.DatabaseConfig, schema string) *SqlSink {
+	return &SqlSink{config: config, schema: schema}
+}
@@ -0,0 +1,217 @@
+package rating
+
+import (
+	"fmt"
+	"json-schema-validation/lib/tkt"
+)
+
+// Member is a line of a census: an enrolled employee with the coverage tier they elected
+// and what their rate depends on.
+type Member struct {
+	Id   string
+	Tier string
+	// Age is the age of the employee on the rate effective date.
+	Age int
+	// SpouseAge is the age of the other insured, for the rates based on the older or the
+	// younger of the insureds.
+	SpouseAge *int
+	Gender    string
+	Tobacco   bool
+	// Volume is the covered amount, for the rates given per volume.
+	Volume *tkt.Decimal
+}
+
+// Calculator computes the monthly premiums of a census under a rate schedule.
+type Calculator struct {
+	// RatingPeriod selects the rates used, current or renewal.
+	RatingPeriod string
+	// Rules maps the roundingRule names of a schedule to rules. Names not in it are parsed
+	// by ParseRoundingRule.
+	Rules map[string]RoundingRule
+	// DefaultRule rounds the premiums, and the pepm, of the schedules without a roundingRule.
+	DefaultRule RoundingRule
+}
+
+// Premium is the monthly premium of a member and the position of the rate it comes from.
+type Premium struct {
+	Member string      `json:"member"`
+	Rate   int         `json:"rate"`
+	Amount tkt.Decimal `json:"amount"`
+}
+
+// Cell totals the premiums of a rate: a tier, age band, gender and tobacco use.
+type Cell struct {
+	Rate    int         `json:"rate"`
+	Tier    string      `json:"tier,omitempty"`
+	Band    string      `json:"band"`
+	Gender  string      `json:"gender,omitempty"`
+	Tobacco *bool       `json:"tobacco,omitempty"`
+	Members int         `json:"members"`
+	Amount  tkt.Decimal `json:"amount"`
+}
+
+// Result holds the monthly premiums, their totals by rate and the issues found. Members
+// without a premium are reported among the issues.
+type Result struct {
+	Premiums []Premium `json:"premiums"`
+	Cells    []Cell    `json:"cells"`
+	// Pepm is the per employee per month charge of the schedule times the members rated.
+	Pepm   tkt.Decimal `json:"pepm"`
+	Total  tkt.Decimal `json:"total"`
+	Issues []Issue     `json:"issues"`
+}
+
+func (o *Calculator) rule(name string) (RoundingRule, error) {
+	if name == "" {
+		return o.DefaultRule, nil
+	}
+	if rule, ok := o.Rules[name]; ok {
+		return rule, nil
+	}
+	return ParseRoundingRule(name)
+}
+
+// selectRates returns the positions of the rates of the calculator period.
+func (o *Calculator) selectRates(schedule *RateSchedule) []int {
+	rates := make([]int, 0, len(schedule.Rates))
+	for i := range schedule.Rates {
+		if schedule.Rates[i].period() == o.RatingPeriod {
+			rates = append(rates, i)
+		}
+	}
+	return rates
+}
+
+// Calculate rates every member of the census. The issues of the schedule found by Check
+// come first in the result, followed by those of the members.
+func (o *Calculator) Calculate(schedule *RateSchedule, census []Member) *Result {
+	result := &Result{Premiums: make([]Premium, 0, len(census)), Cells: make([]Cell, 0), Issues: o.Check(schedule)}
+	rates := o.selectRates(schedule)
+	cells := make(map[int]int)
+	for m := range census {
+		member := &census[m]
+		i, issue := o.match(schedule, rates, member)
+		if issue == nil {
+			var amount tkt.Decimal
+			amount, issue = o.premium(schedule, i, member)
+			if issue == nil {
+				result.Premiums = append(result.Premiums, Premium{Member: member.Id, Rate: i, Amount: amount})
+				c, ok := cells[i]
+				if !ok {
+					c = len(result.Cells)
+					cells[i] = c
+					r := &schedule.Rates[i]
+					result.Cells = append(result.Cells, Cell{Rate: i, Tier: r.CoverageTierCode, Band: r.Band(),
+						Gender: r.Gender, Tobacco: r.IsTobaccoRated})
+				}
+				result.Cells[c].Members++
+				result.Cells[c].Amount = result.Cells[c].Amount.Add(amount)
+				result.Total = result.Total.Add(amount)
+			}
+		}
+		if issue != nil {
+			result.Issues = append(result.Issues, *issue)
+		}
+	}
+	if schedule.Pepm != nil {
+		pepm := schedule.Pepm.Mul(tkt.NewDecimal(int64(len(result.Premiums)), 0))
+		rule, err := o.rule(schedule.RoundingRule)
+		if err != nil {
+			rule = o.DefaultRule
+		}
+		result.Pepm = rule.Apply(pepm)
+		result.Total = result.Total.Add(result.Pepm)
+	}
+	result.Issues = append(result.Issues, o.checkLives(schedule, rates, census, cells, result)...)
+	return result
+}
+
+// match returns the rate of the member: the most specific of the rates it matches.
+func (o *Calculator) match(schedule *RateSchedule, rates []int, member *Member) (int, *Issue) {
+	best, bestSpecificity, ties := -1, -1, 0
+	for _, i := range rates {
+		r := &schedule.Rates[i]
+		if !r.matches(member, schedule.ageBasedOn(r)) {
+			continue
+		}
+		if s := r.specificity(); s > bestSpecificity {
+			best, bestSpecificity, ties = i, s, 1
+		} else if s == bestSpecificity {
+			ties++
+		}
+	}
+	if best < 0 {
+		return 0, &Issue{Kind: IssueNoRate, Member: member.Id,
+			Message: fmt.Sprintf("No rate for member %s: %s", member.Id, member.describe())}
+	}
+	if ties > 1 {
+		return 0, &Issue{Kind: IssueAmbiguousRate, Member: member.Id,
+			Message: fmt.Sprintf("%d rates for member %s: %s", ties, member.Id, member.describe())}
+	}
+	return best, nil
+}
+
+// premium returns rate i of the member, or the rate times the volume of the member over
+// the volume of the unit for rates given per volume, rounded with the rule of the rate.
+func (o *Calculator) premium(schedule *RateSchedule, i int, member *Member) (tkt.Decimal, *Issue) {
+	r := &schedule.Rates[i]
+	unusable := func(reason string) *Issue {
+		return &Issue{Kind: IssueNoRate, Rates: []int{i}, Member: member.Id,
+			Message: fmt.Sprintf("Rate %d of member %s cannot be applied: %s", i, member.Id, reason)}
+	}
+	if r.Rate == nil {
+		return tkt.Decimal{}, unusable("no rate")
+	}
+	rule, err := o.rule(schedule.roundingRule(r))
+	if err != nil {
+		return tkt.Decimal{}, unusable(err.Error())
+	}
+	per, ok, err := r.perVolume()
+	if !ok {
+		return rule.Apply(*r.Rate), nil
+	} else if err != nil || per.Sign() <= 0 {
+		return tkt.Decimal{}, unusable(fmt.Sprintf("invalid unit %q", r.Unit))
+	}
+	if member.Volume == nil {
+		return tkt.Decimal{}, &Issue{Kind: IssueMissingVolume, Rates: []int{i}, Member: member.Id,
+			Message: fmt.Sprintf("Member %s has no volume for rate %d, given %s", member.Id, i, r.Unit)}
+	}
+	amount, err := r.Rate.Mul(*member.Volume).Div(per, rule.Scale, rule.Mode)
+	if err != nil {
+		return tkt.Decimal{}, unusable(err.Error())
+	}
+	return amount, nil
+}
+
+// checkLives compares the numberOfLives of the schedule and of its rates with the members
+// rated.
+func (o *Calculator) checkLives(schedule *RateSchedule, rates []int, census []Member, cells map[int]int, result *Result) []Issue {
+	issues := make([]Issue, 0)
+	if schedule.NumberOfLives != nil && *schedule.NumberOfLives != len(census) {
+		issues = append(issues, Issue{Kind: IssueLivesMismatch,
+			Message: fmt.Sprintf("The schedule has %d lives, the census %d members", *schedule.NumberOfLives, len(census))})
+	}
+	for _, i := range rates {
+		r := &schedule.Rates[i]
+		if r.NumberOfLives == nil {
+			continue
+		}
+		members := 0
+		if c, ok := cells[i]; ok {
+			members = result.Cells[c].Members
+		}
+		if *r.NumberOfLives != members {
+			issues = append(issues, Issue{Kind: IssueLivesMismatch, Rates: []int{i},
+				Message: fmt.Sprintf("Rate %d has %d lives, %d members were rated with it", i, *r.NumberOfLives, members)})
+		}
+	}
+	return issues
+}
+
+func (o *Member) describe() string {
+	return fmt.Sprintf("tier %s, age %d, gender %s, tobacco %t", o.Tier, o.Age, o.Gender, o.Tobacco)
+}
+
+func NewCalculator() *Calculator {
+	return &Calculator{RatingPeriod: PeriodCurrent, Rules: make(map[string]RoundingRule), DefaultRule: DefaultRoundingRule}
+}
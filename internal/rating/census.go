@@ -0,0 +1,70 @@
+package rating
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"json-schema-validation/lib/tkt"
+	"strconv"
+	"strings"
+)
+
+// ReadCensus reads members from CSV with a header line naming the columns id, tier, age,
+// spouseAge, gender, tobacco and volume, in any order. Only id, tier and age are required;
+// empty cells are left out.
+func ReadCensus(r io.Reader) ([]Member, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("Census header: %w", err)
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"id", "tier", "age"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("Census has no %s column", name)
+		}
+	}
+	members := make([]Member, 0)
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return members, nil
+		} else if err != nil {
+			return nil, err
+		}
+		cell := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+		member := Member{Id: cell("id"), Tier: cell("tier"), Gender: cell("gender")}
+		if member.Age, err = strconv.Atoi(cell("age")); err != nil {
+			return nil, fmt.Errorf("Census line %d: invalid age %q", line, cell("age"))
+		}
+		if s := cell("spouseage"); s != "" {
+			age, err := strconv.Atoi(s)
+			if err != nil {
+				return nil, fmt.Errorf("Census line %d: invalid spouseAge %q", line, s)
+			}
+			member.SpouseAge = &age
+		}
+		if s := cell("tobacco"); s != "" {
+			if member.Tobacco, err = strconv.ParseBool(s); err != nil {
+				return nil, fmt.Errorf("Census line %d: invalid tobacco %q", line, s)
+			}
+		}
+		if s := cell("volume"); s != "" {
+			volume, err := tkt.ParseDecimal(s)
+			if err != nil {
+				return nil, fmt.Errorf("Census line %d: %w", line, err)
+			}
+			member.Volume = &volume
+		}
+		members = append(members, member)
+	}
+}
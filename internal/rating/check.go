@@ -0,0 +1,136 @@
+package rating
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Kinds of Issue.
+const (
+	IssueOverlap             = "overlap"
+	IssueGap                 = "gap"
+	IssueInvertedBand        = "invertedBand"
+	IssueMissingRate         = "missingRate"
+	IssueNegativeRate        = "negativeRate"
+	IssueInvalidUnit         = "invalidUnit"
+	IssueUnknownRoundingRule = "unknownRoundingRule"
+	IssueNoRate              = "noRate"
+	IssueAmbiguousRate       = "ambiguousRate"
+	IssueMissingVolume       = "missingVolume"
+	IssueLivesMismatch       = "livesMismatch"
+)
+
+// Issue is an inconsistency of a schedule, or of a census against it.
+type Issue struct {
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+	// Rates are the positions in the schedule of the rates involved.
+	Rates []int `json:"rates,omitempty"`
+	// Member is the id of the census member involved.
+	Member string `json:"member,omitempty"`
+}
+
+// Check reports the inconsistencies of the rates of the calculator period: overlapping age
+// bands, which leave some members with two rates, gaps between the bands of a cell, inverted
+// bands, missing or negative rates, unknown units and unknown rounding rules.
+func (o *Calculator) Check(schedule *RateSchedule) []Issue {
+	issues := make([]Issue, 0)
+	rates := o.selectRates(schedule)
+	if _, err := o.rule(schedule.RoundingRule); err != nil {
+		issues = append(issues, Issue{Kind: IssueUnknownRoundingRule, Message: fmt.Sprintf("Schedule: %s", err)})
+	}
+	for _, i := range rates {
+		r := &schedule.Rates[i]
+		if r.Rate == nil {
+			issues = append(issues, Issue{Kind: IssueMissingRate, Rates: []int{i},
+				Message: fmt.Sprintf("Rate %d has no rate", i)})
+		} else if r.Rate.Sign() < 0 {
+			issues = append(issues, Issue{Kind: IssueNegativeRate, Rates: []int{i},
+				Message: fmt.Sprintf("Rate %d is negative: %s", i, r.Rate)})
+		}
+		if lower, upper := r.band(); lower > upper {
+			issues = append(issues, Issue{Kind: IssueInvertedBand, Rates: []int{i},
+				Message: fmt.Sprintf("Rate %d has an age band from %d down to %d", i, lower, upper)})
+		}
+		if per, ok, err := r.perVolume(); ok && (err != nil || per.Sign() <= 0) {
+			issues = append(issues, Issue{Kind: IssueInvalidUnit, Rates: []int{i},
+				Message: fmt.Sprintf("Rate %d has an invalid unit %q", i, r.Unit)})
+		}
+		if _, err := o.rule(r.RoundingRule); r.RoundingRule != "" && err != nil {
+			issues = append(issues, Issue{Kind: IssueUnknownRoundingRule, Rates: []int{i},
+				Message: fmt.Sprintf("Rate %d: %s", i, err)})
+		}
+	}
+	issues = append(issues, overlaps(schedule, rates)...)
+	issues = append(issues, gaps(schedule, rates)...)
+	return issues
+}
+
+// overlaps reports the pairs of rates that a same member can match with the same
+// specificity, for which the calculation cannot pick one.
+func overlaps(schedule *RateSchedule, rates []int) []Issue {
+	issues := make([]Issue, 0)
+	for x, i := range rates {
+		a := &schedule.Rates[i]
+		for _, j := range rates[x+1:] {
+			b := &schedule.Rates[j]
+			if !compatible(schedule, a, b) || a.specificity() != b.specificity() {
+				continue
+			}
+			lowerA, upperA := a.band()
+			lowerB, upperB := b.band()
+			if lowerA <= upperB && lowerB <= upperA && lowerA <= upperA && lowerB <= upperB {
+				issues = append(issues, Issue{Kind: IssueOverlap, Rates: []int{i, j},
+					Message: fmt.Sprintf("Rates %d and %d of %s overlap: ages %s and %s", i, j, a.cell(), a.Band(), b.Band())})
+			}
+		}
+	}
+	return issues
+}
+
+// compatible reports whether a member can match both rates, ages aside.
+func compatible(schedule *RateSchedule, a *Rate, b *Rate) bool {
+	return (a.CoverageTierCode == "" || b.CoverageTierCode == "" || a.CoverageTierCode == b.CoverageTierCode) &&
+		(a.Gender == "" || b.Gender == "" || a.Gender == b.Gender) &&
+		(a.IsTobaccoRated == nil || b.IsTobaccoRated == nil || *a.IsTobaccoRated == *b.IsTobaccoRated) &&
+		schedule.ageBasedOn(a) == schedule.ageBasedOn(b)
+}
+
+// gaps reports the ages left without a rate between the bands of a cell. Ages below the
+// lowest band and above the highest are not rated, and not reported.
+func gaps(schedule *RateSchedule, rates []int) []Issue {
+	cells := make(map[string][]int)
+	keys := make([]string, 0)
+	for _, i := range rates {
+		r := &schedule.Rates[i]
+		if lower, upper := r.band(); lower > upper {
+			continue
+		}
+		key := r.cell()
+		if _, ok := cells[key]; !ok {
+			keys = append(keys, key)
+		}
+		cells[key] = append(cells[key], i)
+	}
+	issues := make([]Issue, 0)
+	for _, key := range keys {
+		cell := cells[key]
+		sort.SliceStable(cell, func(x, y int) bool {
+			lowerX, _ := schedule.Rates[cell[x]].band()
+			lowerY, _ := schedule.Rates[cell[y]].band()
+			return lowerX < lowerY
+		})
+		_, covered := schedule.Rates[cell[0]].band()
+		for x := 1; x < len(cell); x++ {
+			lower, upper := schedule.Rates[cell[x]].band()
+			if covered != maxAge && lower > covered+1 {
+				issues = append(issues, Issue{Kind: IssueGap, Rates: []int{cell[x-1], cell[x]},
+					Message: fmt.Sprintf("No rate of %s for ages %d to %d", key, covered+1, lower-1)})
+			}
+			if upper > covered {
+				covered = upper
+			}
+		}
+	}
+	return issues
+}
@@ -0,0 +1,163 @@
+package rating
+
+import (
+	"encoding/json"
+	"json-schema-validation/lib/tkt"
+	"reflect"
+	"testing"
+)
+
+func checkSchedule(t *testing.T, text string) *RateSchedule {
+	t.Helper()
+	var value interface{}
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		t.Fatal(err)
+	}
+	schedule, err := RateScheduleOf(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schedule
+}
+
+func issueKinds(issues []Issue) []string {
+	kinds := make([]string, 0, len(issues))
+	for _, issue := range issues {
+		kinds = append(kinds, issue.Kind)
+	}
+	return kinds
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		schedule string
+		kinds    []string
+		rates    [][]int
+	}{
+		{"consistent", `{"rates": [{"coverageTierCode": "EE", "ageBandUpper": 29, "rate": 1},
+			{"coverageTierCode": "EE", "ageBandLower": 30, "ageBandUpper": 39, "rate": 2},
+			{"coverageTierCode": "EE", "ageBandLower": 40, "rate": 3},
+			{"coverageTierCode": "ES", "rate": 4}]}`, []string{}, [][]int{}},
+		{"overlap", `{"rates": [{"coverageTierCode": "EE", "ageBandLower": 30, "ageBandUpper": 39, "rate": 1},
+			{"coverageTierCode": "EE", "ageBandLower": 35, "ageBandUpper": 44, "rate": 2}]}`,
+			[]string{IssueOverlap}, [][]int{{0, 1}}},
+		{"overlap of a single age", `{"rates": [{"coverageTierCode": "EE", "ageBandLower": 30, "ageBandUpper": 39, "rate": 1},
+			{"coverageTierCode": "EE", "ageBandLower": 39, "rate": 2}]}`,
+			[]string{IssueOverlap}, [][]int{{0, 1}}},
+		{"no overlap across tiers or genders", `{"rates": [{"coverageTierCode": "EE", "gender": "F", "rate": 1},
+			{"coverageTierCode": "EE", "gender": "M", "rate": 2}, {"coverageTierCode": "ES", "gender": "F", "rate": 3}]}`,
+			[]string{}, [][]int{}},
+		{"overlap of rates for all tiers", `{"rates": [{"coverageTierCode": "EE", "rate": 1}, {"gender": "F", "rate": 2}]}`,
+			[]string{IssueOverlap}, [][]int{{0, 1}}},
+		{"gap", `{"rates": [{"coverageTierCode": "EE", "ageBandUpper": 29, "rate": 1},
+			{"coverageTierCode": "EE", "ageBandLower": 40, "rate": 2}]}`,
+			[]string{IssueGap}, [][]int{{0, 1}}},
+		{"gap after an enclosing band", `{"rates": [{"coverageTierCode": "EE", "ageBandLower": 20, "ageBandUpper": 49, "rate": 1},
+			{"coverageTierCode": "EE", "gender": "F", "ageBandLower": 30, "ageBandUpper": 39, "rate": 2},
+			{"coverageTierCode": "EE", "ageBandLower": 55, "rate": 3}]}`,
+			[]string{IssueGap}, [][]int{{0, 2}}},
+		{"inverted band", `{"rates": [{"coverageTierCode": "EE", "ageBandLower": 50, "ageBandUpper": 40, "rate": 1},
+			{"coverageTierCode": "EE", "ageBandLower": 30, "ageBandUpper": 60, "rate": 2}]}`,
+			[]string{IssueInvertedBand}, [][]int{{0}}},
+		{"missing and negative rates", `{"rates": [{"coverageTierCode": "EE"}, {"coverageTierCode": "ES", "rate": -1}]}`,
+			[]string{IssueMissingRate, IssueNegativeRate}, [][]int{{0}, {1}}},
+		{"invalid unit", `{"rates": [{"coverageTierCode": "EE", "rate": 1, "unit": "USD per 0 volume"},
+			{"coverageTierCode": "ES", "rate": 1, "unit": "USD per 1,000 volume"}]}`,
+			[]string{IssueInvalidUnit}, [][]int{{0}}},
+		{"renewal rates left out", `{"rates": [{"coverageTierCode": "EE", "rate": 1},
+			{"coverageTierCode": "EE", "rate": 2, "ratingPeriod": "renewal"}]}`, []string{}, [][]int{}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			issues := NewCalculator().Check(checkSchedule(t, test.schedule))
+			rates := make([][]int, 0, len(issues))
+			for _, issue := range issues {
+				rates = append(rates, issue.Rates)
+			}
+			if kinds := issueKinds(issues); !reflect.DeepEqual(kinds, test.kinds) || !reflect.DeepEqual(rates, test.rates) {
+				t.Errorf("issues %+v, want %v of rates %v", issues, test.kinds, test.rates)
+			}
+		})
+	}
+}
+
+func TestCheckRenewal(t *testing.T) {
+	schedule := checkSchedule(t, `{"rates": [{"coverageTierCode": "EE", "rate": 1},
+		{"coverageTierCode": "EE", "rate": 2, "ratingPeriod": "renewal"},
+		{"coverageTierCode": "EE", "rate": 3, "ratingPeriod": "renewal"}]}`)
+	calculator := NewCalculator()
+	calculator.RatingPeriod = PeriodRenewal
+	if issues := calculator.Check(schedule); len(issues) != 1 || !reflect.DeepEqual(issues[0].Rates, []int{1, 2}) {
+		t.Errorf("issues %+v, want an overlap of rates 1 and 2", issues)
+	}
+}
+
+func TestCalculate(t *testing.T) {
+	schedule := checkSchedule(t, `{"numberOfLives": 4, "pepm": 2.5, "rates": [
+		{"coverageTierCode": "EE", "ageBandUpper": 39, "rate": 100.115, "numberOfLives": 1},
+		{"coverageTierCode": "EE", "ageBandLower": 40, "rate": 150.125, "roundingRule": "halfEven"},
+		{"coverageTierCode": "EE", "ageBandLower": 40, "gender": "F", "isTobaccoRated": true, "rate": 175},
+		{"coverageTierCode": "LIFE", "rate": 0.29, "unit": "USD per 1,000 volume"},
+		{"coverageTierCode": "LIFE", "rate": 0.5, "unit": "USD per 1,000 volume", "ratingPeriod": "renewal"}]}`)
+	volume := tkt.NewDecimal(150000, 0)
+	census := []Member{
+		{Id: "a", Tier: "EE", Age: 30},
+		{Id: "b", Tier: "EE", Age: 45, Gender: "M"},
+		{Id: "c", Tier: "EE", Age: 50, Gender: "F", Tobacco: true},
+		{Id: "d", Tier: "LIFE", Age: 50, Volume: &volume},
+		{Id: "e", Tier: "LIFE", Age: 50},
+		{Id: "f", Tier: "ES", Age: 50},
+	}
+	result := NewCalculator().Calculate(schedule, census)
+	premiums := make(map[string]string)
+	for _, premium := range result.Premiums {
+		premiums[premium.Member] = premium.Amount.String()
+	}
+	want := map[string]string{"a": "100.12", "b": "150.12", "c": "175.00", "d": "43.50"}
+	if !reflect.DeepEqual(premiums, want) {
+		t.Errorf("premiums %v, want %v", premiums, want)
+	}
+	if len(result.Cells) != 4 || result.Cells[3].Rate != 3 || result.Cells[3].Members != 1 || result.Cells[3].Amount.String() != "43.50" {
+		t.Errorf("cells %+v", result.Cells)
+	}
+	if got := result.Pepm.String(); got != "10.00" {
+		t.Errorf("pepm %s, want 10.00", got)
+	}
+	if got := result.Total.String(); got != "478.74" {
+		t.Errorf("total %s, want 478.74", got)
+	}
+	kinds := issueKinds(result.Issues)
+	if want := []string{IssueMissingVolume, IssueNoRate, IssueLivesMismatch}; !reflect.DeepEqual(kinds, want) {
+		t.Fatalf("issues %+v, want %v", result.Issues, want)
+	}
+	if result.Issues[0].Member != "e" || result.Issues[1].Member != "f" || result.Issues[2].Rates != nil {
+		t.Errorf("issues %+v", result.Issues)
+	}
+}
+
+func TestCalculateAmbiguousRate(t *testing.T) {
+	schedule := checkSchedule(t, `{"rates": [{"coverageTierCode": "EE", "gender": "F", "rate": 1},
+		{"coverageTierCode": "EE", "ageBandLower": 30, "ageBandUpper": 39, "rate": 2}]}`)
+	result := NewCalculator().Calculate(schedule, []Member{
+		{Id: "a", Tier: "EE", Age: 35, Gender: "F"},
+		{Id: "b", Tier: "EE", Age: 35, Gender: "M"},
+		{Id: "c", Tier: "EE", Age: 45, Gender: "F"},
+	})
+	if kinds := issueKinds(result.Issues); !reflect.DeepEqual(kinds, []string{IssueOverlap, IssueAmbiguousRate}) || result.Issues[1].Member != "a" {
+		t.Errorf("issues %+v, want an overlap and an ambiguous rate for a", result.Issues)
+	}
+	if len(result.Premiums) != 2 || result.Total.String() != "3.00" {
+		t.Errorf("premiums %+v, total %s", result.Premiums, result.Total)
+	}
+}
+
+func TestCalculateLivesMismatch(t *testing.T) {
+	schedule := checkSchedule(t, `{"numberOfLives": 2, "rates": [{"coverageTierCode": "EE", "rate": 1, "numberOfLives": 2},
+		{"coverageTierCode": "ES", "rate": 2, "numberOfLives": 1}, {"coverageTierCode": "EC", "rate": 3, "numberOfLives": 1}]}`)
+	result := NewCalculator().Calculate(schedule, []Member{{Id: "a", Tier: "EE", Age: 30}, {Id: "b", Tier: "ES", Age: 30}})
+	if kinds := issueKinds(result.Issues); !reflect.DeepEqual(kinds, []string{IssueLivesMismatch, IssueLivesMismatch}) ||
+		!reflect.DeepEqual(result.Issues[0].Rates, []int{0}) || !reflect.DeepEqual(result.Issues[1].Rates, []int{2}) {
+		t.Errorf("issues %+v, want lives mismatches of rates 0 and 2", result.Issues)
+	}
+}
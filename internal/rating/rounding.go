@@ -0,0 +1,48 @@
+package rating
+
+import (
+	"fmt"
+	"json-schema-validation/lib/tkt"
+	"strconv"
+	"strings"
+)
+
+// RoundingRule says to how many decimals and how a premium is rounded.
+type RoundingRule struct {
+	Scale int
+	Mode  tkt.RoundingMode
+}
+
+// DefaultRoundingRule rounds to cents, half up.
+var DefaultRoundingRule = RoundingRule{Scale: 2, Mode: tkt.RoundHalfUp}
+
+var roundingModes = map[string]tkt.RoundingMode{
+	"halfup":   tkt.RoundHalfUp,
+	"halfeven": tkt.RoundHalfEven,
+	"down":     tkt.RoundDown,
+}
+
+// ParseRoundingRule reads a rule written as a mode and an optional scale, such as "halfUp",
+// "halfEven:2" or "down:0". The modes are halfUp, halfEven and down, in any case and with or
+// without a dash or an underscore; the scale defaults to 2.
+func ParseRoundingRule(s string) (RoundingRule, error) {
+	name, scale, hasScale := strings.Cut(strings.TrimSpace(s), ":")
+	name = strings.ToLower(strings.NewReplacer("-", "", "_", "").Replace(name))
+	mode, ok := roundingModes[name]
+	if !ok {
+		return RoundingRule{}, fmt.Errorf("Unknown rounding rule %q", s)
+	}
+	rule := RoundingRule{Scale: DefaultRoundingRule.Scale, Mode: mode}
+	if hasScale {
+		n, err := strconv.Atoi(strings.TrimSpace(scale))
+		if err != nil || n < 0 {
+			return RoundingRule{}, fmt.Errorf("Invalid scale in rounding rule %q", s)
+		}
+		rule.Scale = n
+	}
+	return rule, nil
+}
+
+func (o RoundingRule) Apply(d tkt.Decimal) tkt.Decimal {
+	return d.Round(o.Scale, o.Mode)
+}
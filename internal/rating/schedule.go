@@ -0,0 +1,211 @@
+package rating
+
+import (
+	"encoding/json"
+	"json-schema-validation/lib/tkt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	PeriodCurrent = "current"
+	PeriodRenewal = "renewal"
+)
+
+// Values of RateSchedule.AgeBasedOn.
+const (
+	AgeOfEmployee       = "employee"
+	AgeOfInsured        = "insured"
+	AgeOfOlderInsured   = "olderOfInsureds"
+	AgeOfYoungerInsured = "youngerOfInsureds"
+)
+
+// maxAge stands for the open upper bound of an age band.
+const maxAge = math.MaxInt32
+
+var perVolumeRegexp = regexp.MustCompile(`(?i)\bper\s+([0-9][0-9,]*(?:\.[0-9]+)?)`)
+
+// RateSchedule is a rateSchedule of a canonical BenefitPlan. Its roundingRule and
+// ageBasedOn apply to all its rates.
+type RateSchedule struct {
+	RateDesign        string       `json:"rateDesign"`
+	RateEffectiveDate string       `json:"rateEffectiveDate"`
+	NumberOfLives     *int         `json:"numberOfLives"`
+	Pepm              *tkt.Decimal `json:"pepm"`
+	Rates             []Rate       `json:"rates"`
+	RoundingRule      string       `json:"roundingRule"`
+	AgeBasedOn        string       `json:"ageBasedOn"`
+}
+
+// Rate is the monthly rate of a cell of the schedule: a coverage tier, optionally narrowed
+// to an age band, a gender and tobacco use. The fields left out match every member.
+// RoundingRule and AgeBasedOn, which the schema only defines on the schedule, override
+// those of the schedule when a rate carries them.
+type Rate struct {
+	AgeBandLower     *int         `json:"ageBandLower"`
+	AgeBandUpper     *int         `json:"ageBandUpper"`
+	CoverageTierCode string       `json:"coverageTierCode"`
+	NumberOfLives    *int         `json:"numberOfLives"`
+	Gender           string       `json:"gender"`
+	IsTobaccoRated   *bool        `json:"isTobaccoRated"`
+	Rate             *tkt.Decimal `json:"rate"`
+	Volume           string       `json:"volume"`
+	Unit             string       `json:"unit"`
+	RatingPeriod     string       `json:"ratingPeriod"`
+	RoundingRule     string       `json:"roundingRule"`
+	AgeBasedOn       string       `json:"ageBasedOn"`
+}
+
+// RateScheduleOf reads a rateSchedule out of a decoded, validated document value.
+func RateScheduleOf(value interface{}) (*RateSchedule, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	schedule := &RateSchedule{}
+	if err := json.Unmarshal(data, schedule); err != nil {
+		return nil, err
+	}
+	return schedule, nil
+}
+
+// period returns the rating period of the rate, current when left out as in the schema.
+func (o *Rate) period() string {
+	if o.RatingPeriod == "" {
+		return PeriodCurrent
+	}
+	return o.RatingPeriod
+}
+
+// band returns the ages of the band, both included; a bound left out leaves it open.
+func (o *Rate) band() (int, int) {
+	lower, upper := 0, maxAge
+	if o.AgeBandLower != nil {
+		lower = *o.AgeBandLower
+	}
+	if o.AgeBandUpper != nil {
+		upper = *o.AgeBandUpper
+	}
+	return lower, upper
+}
+
+// Band returns the age band for people: "30-39", "65+" or "all".
+func (o *Rate) Band() string {
+	lower, upper := o.band()
+	if lower == 0 && upper == maxAge {
+		return "all"
+	} else if upper == maxAge {
+		return strconv.Itoa(lower) + "+"
+	}
+	return strconv.Itoa(lower) + "-" + strconv.Itoa(upper)
+}
+
+// perVolume returns the volume the rate is given for when its unit reads "per <number>",
+// as in "USD per 100 volume", and false for rates per member.
+func (o *Rate) perVolume() (tkt.Decimal, bool, error) {
+	m := perVolumeRegexp.FindStringSubmatch(o.Unit)
+	if m == nil {
+		return tkt.Decimal{}, false, nil
+	}
+	per, err := tkt.ParseDecimal(strings.ReplaceAll(m[1], ",", ""))
+	return per, true, err
+}
+
+// AgeOn returns the age in whole years on the date of someone born on birthDate.
+func AgeOn(birthDate time.Time, date time.Time) int {
+	age := date.Year() - birthDate.Year()
+	if date.Month() < birthDate.Month() || (date.Month() == birthDate.Month() && date.Day() < birthDate.Day()) {
+		age--
+	}
+	return age
+}
+
+// roundingRule returns the name of the rounding rule of the rate, that of the schedule
+// unless the rate has its own.
+func (o *RateSchedule) roundingRule(r *Rate) string {
+	if r.RoundingRule != "" {
+		return r.RoundingRule
+	}
+	return o.RoundingRule
+}
+
+// ageBasedOn returns whose age the band of the rate applies to, as given by the rate or
+// else the schedule, the employee's when both leave it out.
+func (o *RateSchedule) ageBasedOn(r *Rate) string {
+	if r.AgeBasedOn != "" {
+		return r.AgeBasedOn
+	} else if o.AgeBasedOn != "" {
+		return o.AgeBasedOn
+	}
+	return AgeOfEmployee
+}
+
+// ageOf returns the age of the member the band of a rate based on basedOn applies to.
+func ageOf(member *Member, basedOn string) int {
+	if member.SpouseAge == nil {
+		return member.Age
+	}
+	switch basedOn {
+	case AgeOfOlderInsured:
+		if *member.SpouseAge > member.Age {
+			return *member.SpouseAge
+		}
+	case AgeOfYoungerInsured:
+		if *member.SpouseAge < member.Age {
+			return *member.SpouseAge
+		}
+	}
+	return member.Age
+}
+
+// matches tells whether the member falls in the cell of the rate, its age being that of
+// basedOn.
+func (o *Rate) matches(member *Member, basedOn string) bool {
+	if (o.CoverageTierCode != "" && o.CoverageTierCode != member.Tier) ||
+		(o.Gender != "" && o.Gender != member.Gender) ||
+		(o.IsTobaccoRated != nil && *o.IsTobaccoRated != member.Tobacco) {
+		return false
+	}
+	lower, upper := o.band()
+	age := ageOf(member, basedOn)
+	return lower <= age && age <= upper
+}
+
+// specificity counts the criteria the rate narrows, so that a rate for female employees
+// takes precedence over a rate for all employees.
+func (o *Rate) specificity() int {
+	s := 0
+	if o.CoverageTierCode != "" {
+		s++
+	}
+	if o.AgeBandLower != nil || o.AgeBandUpper != nil {
+		s++
+	}
+	if o.Gender != "" {
+		s++
+	}
+	if o.IsTobaccoRated != nil {
+		s++
+	}
+	return s
+}
+
+// cell names the tier, gender and tobacco use of the rate for messages.
+func (o *Rate) cell() string {
+	s := "all tiers"
+	if o.CoverageTierCode != "" {
+		s = o.CoverageTierCode
+	}
+	if o.Gender != "" {
+		s += ", " + o.Gender
+	}
+	if o.IsTobaccoRated != nil && *o.IsTobaccoRated {
+		s += ", tobacco"
+	} else if o.IsTobaccoRated != nil {
+		s += ", non tobacco"
+	}
+	return s
+}
@@ -0,0 +1,80 @@
+package rating
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func scheduleOf(t *testing.T, text string) *RateSchedule {
+	var value interface{}
+	if err := json.Unmarshal([]byte(text), &value); err != nil {
+		t.Fatal(err)
+	}
+	schedule, err := RateScheduleOf(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schedule
+}
+
+func premiumOf(t *testing.T, schedule *RateSchedule, member Member) string {
+	result := NewCalculator().Calculate(schedule, []Member{member})
+	if len(result.Premiums) != 1 {
+		t.Fatalf("no premium: %+v", result.Issues)
+	}
+	return result.Premiums[0].Amount.String()
+}
+
+func TestScheduleRoundingRule(t *testing.T) {
+	rates := `"rates": [{"coverageTierCode": "EE", "rate": 10.755}]`
+	member := Member{Id: "1", Tier: "EE", Age: 40}
+	tests := []struct {
+		schedule string
+		want     string
+	}{
+		{`{` + rates + `}`, "10.76"},
+		{`{"roundingRule": "down", ` + rates + `}`, "10.75"},
+		{`{"roundingRule": "halfUp:0", ` + rates + `}`, "11"},
+		{`{"roundingRule": "down:0", "rates": [{"coverageTierCode": "EE", "rate": 10.755, "roundingRule": "halfEven:1"}]}`, "10.8"},
+	}
+	for _, test := range tests {
+		if got := premiumOf(t, scheduleOf(t, test.schedule), member); got != test.want {
+			t.Errorf("%s: premium %s, want %s", test.schedule, got, test.want)
+		}
+	}
+}
+
+func TestScheduleRoundingRuleOfRatesPerVolume(t *testing.T) {
+	schedule := scheduleOf(t, `{"roundingRule": "down:0", "rates": [{"rate": 0.29, "unit": "USD per 1,000 volume"}]}`)
+	volume := scheduleOf(t, `{"pepm": 123456}`).Pepm
+	if got := premiumOf(t, schedule, Member{Id: "1", Tier: "EE", Age: 40, Volume: volume}); got != "35" {
+		t.Errorf("premium %s, want 35", got)
+	}
+}
+
+func TestScheduleUnknownRoundingRule(t *testing.T) {
+	issues := NewCalculator().Check(scheduleOf(t, `{"roundingRule": "nearest", "rates": [{"coverageTierCode": "EE", "rate": 1}, {"coverageTierCode": "ES", "rate": 2}]}`))
+	if len(issues) != 1 || issues[0].Kind != IssueUnknownRoundingRule {
+		t.Errorf("issues %+v, want one unknown rounding rule", issues)
+	}
+}
+
+func TestScheduleAgeBasedOn(t *testing.T) {
+	rates := `"rates": [{"coverageTierCode": "ES", "ageBandLower": 0, "ageBandUpper": 49, "rate": 100},
+		{"coverageTierCode": "ES", "ageBandLower": 50, "rate": 200}]`
+	spouse := 55
+	member := Member{Id: "1", Tier: "ES", Age: 45, SpouseAge: &spouse}
+	tests := []struct {
+		schedule string
+		want     string
+	}{
+		{`{` + rates + `}`, "100.00"},
+		{`{"ageBasedOn": "olderOfInsureds", ` + rates + `}`, "200.00"},
+		{`{"ageBasedOn": "youngerOfInsureds", ` + rates + `}`, "100.00"},
+	}
+	for _, test := range tests {
+		if got := premiumOf(t, scheduleOf(t, test.schedule), member); got != test.want {
+			t.Errorf("%s: premium %s, want %s", test.schedule, got, test.want)
+		}
+	}
+}
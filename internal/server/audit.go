@@ -0,0 +1,131 @@
+package server
+
+import (
+	"fmt"
+	"json-schema-validation/internal/audit"
+	"json-schema-validation/lib/tkt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+const maxAuditErrors = 20
+
+// recordDecision appends the decision on a payload to the audit trail, when there is one.
+// doc is nil for malformed and streamed payloads, err is set for malformed ones.
+func (s *httpServer) recordDecision(r *http.Request, digest string, doc *Document, failure *ValidationFailure, err error) {
+	if s.audit == nil {
+		return
+	}
+	entry := &audit.Entry{
+		RequestId:     tkt.RequestId(r.Context()),
+		Caller:        s.callerIdentity(r),
+		SchemaVersion: s.validator.SchemaVersion(),
+		SchemaHash:    s.validator.SchemaHash(),
+		Verdict:       audit.VerdictAccepted,
+		PayloadDigest: digest,
+	}
+	if doc != nil {
+		if m, ok := doc.Value.(map[string]interface{}); ok {
+			entry.Sender, _ = m["senderName"].(string)
+			entry.Receiver, _ = m["receiverName"].(string)
+		}
+	}
+	if err != nil {
+		entry.Verdict = audit.VerdictMalformed
+		entry.ErrorCount = 1
+		entry.Errors = []string{err.Error()}
+	} else if failure != nil {
+		entry.Verdict = audit.VerdictRejected
+		leaves := failure.Leaves()
+		entry.ErrorCount = len(leaves)
+		for i, leaf := range leaves {
+			if i == maxAuditErrors {
+				break
+			}
+			entry.Errors = append(entry.Errors, fmt.Sprintf("%s: %s", displayLocation(leaf.InstanceLocation), leaf.Message))
+		}
+	}
+	s.audit.Record(entry)
+}
+
+func displayLocation(pointer string) string {
+	if pointer == "" {
+		return "/"
+	}
+	return pointer
+}
+
+// Authenticator returns the user a request authenticated as, such as the basic auth user
+// once its password is checked, and false when the request is not authenticated.
+type Authenticator func(r *http.Request) (string, bool)
+
+// callerIdentity names who sent the request: the subject of a verified client certificate,
+// the user the authenticator accepted, or else the client address. Credentials nothing
+// verified are ignored, since any client can send them. X-Forwarded-For is only read from
+// the trusted proxies, right to left, up to the first address that is not one of them.
+func (s *httpServer) callerIdentity(r *http.Request) string {
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		return "cert:" + r.TLS.VerifiedChains[0][0].Subject.CommonName
+	}
+	if s.authenticate != nil {
+		if user, ok := s.authenticate(r); ok {
+			return "user:" + user
+		}
+	}
+	addr := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	if s.trusted(addr) {
+		forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+		for i := len(forwarded) - 1; i >= 0 && s.trusted(addr); i-- {
+			if hop := strings.TrimSpace(forwarded[i]); hop != "" {
+				addr = hop
+			}
+		}
+	}
+	return "addr:" + addr
+}
+
+func (s *httpServer) trusted(addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, network := range s.trustedProxies {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseTrustedProxies parses a comma separated list of addresses and CIDR networks.
+func ParseTrustedProxies(list string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0)
+	for _, item := range strings.Split(list, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid proxy address %q", item)
+			}
+			bits := 8 * net.IPv6len
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 8*net.IPv4len
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy network %q", item)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
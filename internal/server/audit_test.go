@@ -0,0 +1,99 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCallerIdentityTrustsOnlyConfiguredProxies(t *testing.T) {
+	proxies, err := ParseTrustedProxies("10.0.0.0/8, 192.168.1.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := newHttpServer()
+	s.trustedProxies = proxies
+	tests := []struct {
+		remote    string
+		forwarded string
+		want      string
+	}{
+		{"203.0.113.9:1234", "", "addr:203.0.113.9"},
+		{"203.0.113.9:1234", "198.51.100.1", "addr:203.0.113.9"},
+		{"10.1.2.3:1234", "198.51.100.1", "addr:198.51.100.1"},
+		{"192.168.1.1:1234", "198.51.100.1", "addr:198.51.100.1"},
+		{"10.1.2.3:1234", "6.6.6.6, 198.51.100.1, 10.0.0.2", "addr:198.51.100.1"},
+		{"10.1.2.3:1234", "10.0.0.5", "addr:10.0.0.5"},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/validate", nil)
+		r.RemoteAddr = test.remote
+		if test.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", test.forwarded)
+		}
+		if got := s.callerIdentity(r); got != test.want {
+			t.Errorf("%s forwarding %q: %s, want %s", test.remote, test.forwarded, got, test.want)
+		}
+	}
+}
+
+func TestCallerIdentityIgnoresUnverifiedCredentials(t *testing.T) {
+	s := newHttpServer()
+	s.authenticate = func(r *http.Request) (string, bool) {
+		user, password, ok := r.BasicAuth()
+		return user, ok && user == "alice" && password == "secret"
+	}
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "carrier"}}
+	tests := []struct {
+		name string
+		set  func(r *http.Request)
+		want string
+	}{
+		{"no credentials", func(r *http.Request) {}, "addr:203.0.113.9"},
+		{"forged basic auth", func(r *http.Request) { r.SetBasicAuth("admin", "guess") }, "addr:203.0.113.9"},
+		{"authenticated user", func(r *http.Request) { r.SetBasicAuth("alice", "secret") }, "user:alice"},
+		{"unverified certificate", func(r *http.Request) {
+			r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}}
+		}, "addr:203.0.113.9"},
+		{"verified certificate", func(r *http.Request) {
+			r.TLS = &tls.ConnectionState{PeerCertificates: []*x509.Certificate{cert}, VerifiedChains: [][]*x509.Certificate{{cert}}}
+			r.SetBasicAuth("alice", "secret")
+		}, "cert:carrier"},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/validate", nil)
+		r.RemoteAddr = "203.0.113.9:1234"
+		test.set(r)
+		if got := s.callerIdentity(r); got != test.want {
+			t.Errorf("%s: %s, want %s", test.name, got, test.want)
+		}
+	}
+}
+
+func TestForgedAuthorizationDoesNotNameTheCaller(t *testing.T) {
+	s, sink := testServer()
+	for _, header := range []string{"", "Basic YWRtaW46Z3Vlc3M="} {
+		r := httptest.NewRequest(http.MethodPost, "/validate", strings.NewReader(`{"transmissionGUID": "g"}`))
+		r.RemoteAddr = "203.0.113.9:1234"
+		r.Header.Set("Content-Type", "application/json")
+		if header != "" {
+			r.Header.Set("Authorization", header)
+		}
+		s.validate(httptest.NewRecorder(), r)
+		if caller := sink.Last().Caller; caller != "addr:203.0.113.9" {
+			t.Errorf("authorization %q: caller %s", header, caller)
+		}
+	}
+}
+
+func TestParseTrustedProxiesRejectsInvalidEntries(t *testing.T) {
+	for _, list := range []string{"proxy.local", "10.0.0.0/33"} {
+		if _, err := ParseTrustedProxies(list); err == nil {
+			t.Errorf("%q accepted", list)
+		}
+	}
+}
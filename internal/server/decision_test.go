@@ -0,0 +1,71 @@
+package server
+
+import (
+	"json-schema-validation/internal/audit"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// decisionSink keeps the audit entries recorded by a test server.
+type decisionSink struct {
+	entries []*audit.Entry
+}
+
+func (o *decisionSink) Append(entry *audit.Entry) {
+	clone := *entry
+	o.entries = append(o.entries, &clone)
+}
+
+func (o *decisionSink) Last() *audit.Entry {
+	if len(o.entries) == 0 {
+		return nil
+	}
+	return o.entries[len(o.entries)-1]
+}
+
+func (o *decisionSink) Entries(visit func(entry *audit.Entry) error) error {
+	for _, entry := range o.entries {
+		if err := visit(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestValidateRecordsDecisions(t *testing.T) {
+	sink := &decisionSink{}
+	s := newHttpServer()
+	s.audit = audit.NewTrail(sink)
+	for _, body := range []string{
+		`{"transmissionGUID": "g", "senderName": "sender", "receiverName": "receiver"}`,
+		`{"transmissionGUID": 5}`,
+		`{"transmissionGUID": `,
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/validate", strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		s.validate(httptest.NewRecorder(), r)
+	}
+	if len(sink.entries) != 3 {
+		t.Fatalf("%d entries", len(sink.entries))
+	}
+	accepted, rejected, malformed := sink.entries[0], sink.entries[1], sink.entries[2]
+	if accepted.Verdict != audit.VerdictAccepted || accepted.Sender != "sender" || accepted.Receiver != "receiver" ||
+		accepted.ErrorCount != 0 || !strings.HasPrefix(accepted.PayloadDigest, "sha256:") {
+		t.Errorf("accepted entry %+v", accepted)
+	}
+	if accepted.SchemaVersion == "" || accepted.SchemaHash == "" || accepted.Caller == "" {
+		t.Errorf("accepted entry without schema or caller %+v", accepted)
+	}
+	if rejected.Verdict != audit.VerdictRejected || rejected.ErrorCount == 0 || len(rejected.Errors) != rejected.ErrorCount ||
+		!strings.HasPrefix(rejected.Errors[0], "/transmissionGUID: ") {
+		t.Errorf("rejected entry %+v", rejected)
+	}
+	if malformed.Verdict != audit.VerdictMalformed || malformed.ErrorCount != 1 || !strings.HasPrefix(malformed.PayloadDigest, "sha256-raw:") {
+		t.Errorf("malformed entry %+v", malformed)
+	}
+	if result, err := audit.Verify(sink); err != nil || result.Count != 3 {
+		t.Errorf("Verify: %+v, %v", result, err)
+	}
+}
@@ -0,0 +1,219 @@
+package server
+
+import (
+	"fmt"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"json-schema-validation/lib/tkt"
+	"mime"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	jsonFormat = "json"
+	yamlFormat = "yaml"
+	xmlFormat  = "xml"
+)
+
+var mediaTypeFormats = map[string]string{
+	"":                   jsonFormat,
+	"application/json":   jsonFormat,
+	"text/json":          jsonFormat,
+	"application/yaml":   yamlFormat,
+	"application/x-yaml": yamlFormat,
+	"text/yaml":          yamlFormat,
+	"text/x-yaml":        yamlFormat,
+	"application/xml":    xmlFormat,
+	"text/xml":           xmlFormat,
+}
+
+type SyntaxError struct {
+	Message string
+	Line    int   `json:",omitempty"`
+	Column  int   `json:",omitempty"`
+	Offset  int64 `json:",omitempty"`
+}
+
+func (o *SyntaxError) Error() string {
+	if o.Line > 0 {
+		return fmt.Sprintf("line %d, column %d: %s", o.Line, o.Column, o.Message)
+	}
+	return o.Message
+}
+
+// Decode converts a request body into the JSON data model the schema validates, keeping
+// the source position of every value so errors can point back at the original text.
+func (o *Validator) Decode(contentType string, data []byte) (*Document, error) {
+	format, err := resolveFormat(contentType)
+	if err != nil {
+		return nil, err
+	}
+	switch format {
+	case yamlFormat:
+		return decodeYaml(o.schema, data)
+	case xmlFormat:
+		return decodeXml(o.schema, data)
+	default:
+		return decodeJson(data)
+	}
+}
+
+func resolveFormat(contentType string) (string, error) {
+	mediaType := ""
+	if contentType != "" {
+		var err error
+		mediaType, _, err = mime.ParseMediaType(contentType)
+		if err != nil {
+			return "", &SyntaxError{Message: "invalid content type " + contentType}
+		}
+	}
+	format, ok := mediaTypeFormats[strings.ToLower(mediaType)]
+	if !ok {
+		return "", &SyntaxError{Message: "unsupported content type " + contentType}
+	}
+	return format, nil
+}
+
+type lineIndex struct {
+	data   []byte
+	starts []int
+}
+
+func newLineIndex(data []byte) *lineIndex {
+	starts := []int{0}
+	for i, b := range data {
+		if b == '\n' {
+			starts = append(starts, i+1)
+		}
+	}
+	return &lineIndex{data: data, starts: starts}
+}
+
+// position converts a byte offset into a line and a column counted in characters, the way
+// editors display them.
+func (o *lineIndex) position(offset int64) Position {
+	if offset > int64(len(o.data)) {
+		offset = int64(len(o.data))
+	}
+	line := sort.Search(len(o.starts), func(i int) bool { return int64(o.starts[i]) > offset }) - 1
+	column := utf8.RuneCount(o.data[o.starts[line]:offset]) + 1
+	return Position{Line: line + 1, Column: column, Offset: offset}
+}
+
+// at converts a line and character column back into a full position.
+func (o *lineIndex) at(line int, column int) Position {
+	if line < 1 || line > len(o.starts) {
+		return Position{Line: line, Column: column}
+	}
+	offset := o.starts[line-1]
+	for i := 1; i < column && offset < len(o.data); i++ {
+		_, size := utf8.DecodeRune(o.data[offset:])
+		offset += size
+	}
+	return Position{Line: line, Column: column, Offset: int64(offset)}
+}
+
+func (o *lineIndex) syntaxError(message string, offset int64) *SyntaxError {
+	p := o.position(offset)
+	return &SyntaxError{Message: message, Line: p.Line, Column: p.Column, Offset: p.Offset}
+}
+
+func childPointer(pointer string, token string) string {
+	return pointer + "/" + escapeToken(token)
+}
+
+func escapeToken(token string) string {
+	token = strings.ReplaceAll(token, "~", "~0")
+	token = strings.ReplaceAll(token, "/", "~1")
+	return url.PathEscape(token)
+}
+
+func indexPointer(pointer string, index int) string {
+	return pointer + "/" + strconv.Itoa(index)
+}
+
+// guideSchemas lists the subschemas that describe the same instance as schema, so that
+// decoders of untyped formats can look up property types through $ref and combinators.
+func guideSchemas(schema *jsonschema.Schema) []*jsonschema.Schema {
+	result := make([]*jsonschema.Schema, 0)
+	seen := make(map[*jsonschema.Schema]bool)
+	var add func(s *jsonschema.Schema)
+	add = func(s *jsonschema.Schema) {
+		if s == nil || seen[s] {
+			return
+		}
+		seen[s] = true
+		result = append(result, s)
+		add(s.Ref)
+		add(s.DynamicRef)
+		add(s.RecursiveRef)
+		for _, list := range [][]*jsonschema.Schema{s.AllOf, s.OneOf, s.AnyOf} {
+			for _, c := range list {
+				add(c)
+			}
+		}
+	}
+	add(schema)
+	return result
+}
+
+func guideTypes(schema *jsonschema.Schema) []string {
+	types := make([]string, 0)
+	for _, s := range guideSchemas(schema) {
+		types = append(types, s.Types...)
+	}
+	return types
+}
+
+func guideProperty(schema *jsonschema.Schema, name string) *jsonschema.Schema {
+	for _, s := range guideSchemas(schema) {
+		if p, ok := s.Properties[name]; ok {
+			return p
+		}
+	}
+	for _, s := range guideSchemas(schema) {
+		if p, ok := s.AdditionalProperties.(*jsonschema.Schema); ok {
+			return p
+		}
+	}
+	return nil
+}
+
+func guideItems(schema *jsonschema.Schema, index int) *jsonschema.Schema {
+	for _, s := range guideSchemas(schema) {
+		if items := itemSchema(s, index); items != nil {
+			return items
+		}
+	}
+	return nil
+}
+
+func expectsArray(schema *jsonschema.Schema) bool {
+	return tkt.InStringList("array", guideTypes(schema))
+}
+
+// coerceScalar converts source text into the type the schema expects, returning fallback
+// when the schema gives no guidance or the text does not parse as that type.
+func coerceScalar(schema *jsonschema.Schema, text string, fallback interface{}) interface{} {
+	types := guideTypes(schema)
+	switch {
+	case tkt.InStringList("string", types):
+		return text
+	case tkt.InStringList("integer", types), tkt.InStringList("number", types):
+		if f, err := strconv.ParseFloat(strings.TrimSpace(text), 64); err == nil {
+			return f
+		}
+	case tkt.InStringList("boolean", types):
+		if b, err := strconv.ParseBool(strings.TrimSpace(text)); err == nil {
+			return b
+		}
+	case tkt.InStringList("null", types):
+		if strings.TrimSpace(text) == "" {
+			return nil
+		}
+	}
+	return fallback
+}
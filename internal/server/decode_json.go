@@ -0,0 +1,200 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"unicode/utf8"
+)
+
+// positionReader keeps line and column counters for the bytes flowing into a json.Decoder.
+// Only the bytes read ahead of the last resolved position are retained, so memory does not
+// grow with the size of the input.
+type positionReader struct {
+	reader io.Reader
+	window []byte
+	start  int64
+	line   int
+	column int
+}
+
+func newPositionReader(r io.Reader) *positionReader {
+	return &positionReader{reader: r, line: 1, column: 1}
+}
+
+func (o *positionReader) Read(p []byte) (int, error) {
+	n, err := o.reader.Read(p)
+	o.window = append(o.window, p[:n]...)
+	return n, err
+}
+
+// position resolves an offset; offsets before the last resolved one resolve to it.
+func (o *positionReader) position(offset int64) Position {
+	n := int(offset - o.start)
+	if n < 0 {
+		n = 0
+	} else if n > len(o.window) {
+		n = len(o.window)
+	}
+	for i := 0; i < n; {
+		if o.window[i] == '\n' {
+			o.line++
+			o.column = 1
+			i++
+			continue
+		}
+		_, size := utf8.DecodeRune(o.window[i:n])
+		o.column++
+		i += size
+	}
+	o.window = o.window[n:]
+	o.start += int64(n)
+	return Position{Line: o.line, Column: o.column, Offset: o.start}
+}
+
+// runeStart returns the offset where the character holding the byte at offset starts. The
+// offset of a json.SyntaxError follows the offending character, or its first byte.
+func (o *positionReader) runeStart(offset int64) int64 {
+	for offset > o.start && offset-o.start < int64(len(o.window)) && !utf8.RuneStart(o.window[offset-o.start]) {
+		offset--
+	}
+	if offset < o.start {
+		return o.start
+	}
+	return offset
+}
+
+func (o *positionReader) syntaxError(message string, offset int64) *SyntaxError {
+	p := o.position(offset)
+	return &SyntaxError{Message: message, Line: p.Line, Column: p.Column, Offset: p.Offset}
+}
+
+type jsonDecoder struct {
+	reader    *positionReader
+	decoder   *json.Decoder
+	positions map[string]Position
+}
+
+func newJsonDecoder(r io.Reader) *jsonDecoder {
+	reader := newPositionReader(r)
+	return &jsonDecoder{reader: reader, decoder: json.NewDecoder(reader), positions: make(map[string]Position)}
+}
+
+// decodeJson decodes the body token by token, recording the line, column and byte offset
+// of every value and property name against its JSON Pointer.
+func decodeJson(data []byte) (*Document, error) {
+	d := newJsonDecoder(bytes.NewReader(data))
+	value, err := d.decodeValue("")
+	if err != nil {
+		return nil, d.wrap(err)
+	}
+	if err := d.end(); err != nil {
+		return nil, err
+	}
+	return &Document{Value: value, positions: d.positions}, nil
+}
+
+func (o *jsonDecoder) decodeValue(pointer string) (interface{}, error) {
+	position := o.reader.position(o.nextOffset())
+	token, err := o.decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := o.positions[pointer]; !ok {
+		o.positions[pointer] = position
+	}
+	return o.decodeToken(token, pointer)
+}
+
+func (o *jsonDecoder) decodeToken(token json.Token, pointer string) (interface{}, error) {
+	switch token {
+	case json.Delim('{'):
+		object := make(map[string]interface{})
+		for o.decoder.More() {
+			position := o.reader.position(o.nextOffset())
+			key, err := o.decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			name := key.(string)
+			child := childPointer(pointer, name)
+			o.positions[child] = position
+			object[name], err = o.decodeValue(child)
+			if err != nil {
+				return nil, err
+			}
+		}
+		_, err := o.decoder.Token()
+		return object, err
+	case json.Delim('['):
+		list := make([]interface{}, 0)
+		for o.decoder.More() {
+			item, err := o.decodeValue(indexPointer(pointer, len(list)))
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, item)
+		}
+		_, err := o.decoder.Token()
+		return list, err
+	default:
+		return token, nil
+	}
+}
+
+func (o *jsonDecoder) skipValue() error {
+	depth := 0
+	for {
+		token, err := o.decoder.Token()
+		if err != nil {
+			return err
+		}
+		o.reader.position(o.decoder.InputOffset())
+		switch token {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+		if depth == 0 {
+			return nil
+		}
+	}
+}
+
+// nextOffset returns the offset where the next token starts, skipping the whitespace and
+// separators the decoder has not consumed yet.
+func (o *jsonDecoder) nextOffset() int64 {
+	offset := o.decoder.InputOffset()
+	window := o.reader.window
+	for i := int(offset - o.reader.start); i >= 0 && i < len(window); i++ {
+		switch window[i] {
+		case ' ', '\t', '\r', '\n', ',', ':':
+			offset++
+		default:
+			return offset
+		}
+	}
+	return offset
+}
+
+func (o *jsonDecoder) end() error {
+	offset := o.nextOffset()
+	_, err := o.decoder.Token()
+	if err == io.EOF {
+		return nil
+	}
+	return o.reader.syntaxError("invalid character after top-level value", offset)
+}
+
+func (o *jsonDecoder) wrap(err error) error {
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		return o.reader.syntaxError(e.Error(), o.reader.runeStart(e.Offset-1))
+	default:
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return o.reader.syntaxError("unexpected end of JSON input", o.decoder.InputOffset())
+		}
+		return o.reader.syntaxError(err.Error(), o.decoder.InputOffset())
+	}
+}
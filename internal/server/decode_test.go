@@ -0,0 +1,131 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+)
+
+const decodeSchema = `{
+	"$defs": {
+		"Plan": {"type": "object", "properties": {"lives": {"type": "integer"}}}
+	},
+	"properties": {
+		"zip": {"type": "string"},
+		"note": {"type": ["string", "null"]},
+		"count": {"type": "integer"},
+		"active": {"type": "boolean"},
+		"tags": {"type": "array", "items": {"type": "string"}},
+		"plan": {"$ref": "#/$defs/Plan"}
+	}
+}`
+
+func TestDecode(t *testing.T) {
+	validator := &Validator{schema: compileTestSchema(t, decodeSchema)}
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        interface{}
+	}{
+		{
+			"yaml typed by the schema", "application/yaml",
+			"zip: 01234\ncount: 7\nactive: true\ntags: [a, 2]\nplan: {lives: 3}\n",
+			map[string]interface{}{
+				"zip": "01234", "count": 7.0, "active": true,
+				"tags": []interface{}{"a", "2"},
+				"plan": map[string]interface{}{"lives": 3.0},
+			},
+		},
+		{
+			"yaml quoted scalars stay strings", "text/yaml",
+			"count: '7'\nother: \"true\"\n",
+			map[string]interface{}{"count": "7", "other": "true"},
+		},
+		{
+			"yaml untyped scalars", "application/x-yaml; charset=utf-8",
+			"other: [1, 2.5, false, text]\n",
+			map[string]interface{}{"other": []interface{}{1.0, 2.5, false, "text"}},
+		},
+		{
+			"xml typed by the schema", "application/xml",
+			`<doc active="true"><zip>01234</zip><count> 7 </count><tags>a</tags><plan><lives>3</lives></plan></doc>`,
+			map[string]interface{}{
+				"zip": "01234", "count": 7.0, "active": true,
+				"tags": []interface{}{"a"},
+				"plan": map[string]interface{}{"lives": 3.0},
+			},
+		},
+		{
+			"yaml nulls where a string is expected", "application/yaml",
+			"zip: null\nnote: null\ntags: [~, '']\nother: ~\nplan:\n",
+			map[string]interface{}{"zip": "null", "note": nil, "tags": []interface{}{"~", ""}, "other": nil, "plan": nil},
+		},
+		{
+			"yaml tagged null", "application/yaml",
+			"zip: !!null ''\n",
+			map[string]interface{}{"zip": nil},
+		},
+		{
+			"yaml single document", "application/yaml",
+			"---\nzip: '1'\n...\n",
+			map[string]interface{}{"zip": "1"},
+		},
+		{
+			"xml repeated elements", "text/xml",
+			`<doc><other>1</other><other>2</other></doc>`,
+			map[string]interface{}{"other": []interface{}{"1", "2"}},
+		},
+		{
+			"xml nil and empty elements", "application/xml",
+			`<doc xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"><zip xsi:nil="true"/><plan/></doc>`,
+			map[string]interface{}{"zip": nil, "plan": map[string]interface{}{}},
+		},
+		{
+			"xml text beside attributes", "application/xml",
+			`<doc><note lang="en">hi</note></doc>`,
+			map[string]interface{}{"note": map[string]interface{}{"lang": "en", xmlTextProperty: "hi"}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			doc, err := validator.Decode(test.contentType, []byte(test.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(doc.Value, test.want) {
+				t.Errorf("got %#v, want %#v", doc.Value, test.want)
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	validator := &Validator{schema: compileTestSchema(t, decodeSchema)}
+	tests := []struct {
+		contentType string
+		body        string
+		line        int
+	}{
+		{"text/plain", "a", 0},
+		{"application/json; charset", "{}", 0},
+		{"application/yaml", "a: 1\nb: c: d\n", 2},
+		{"application/xml", "<doc>\n<a></b></doc>", 2},
+		{"application/xml", "", 0},
+		{"application/yaml", "zip: '1'\n---\nzip: '2'\n", 2},
+		{"application/yaml", "zip: '1'\n---\n", 2},
+		{"application/yaml", "zip: '1'\n---\nzip: [\n", 3},
+		{"application/xml", "<doc><zip>1</zip></doc>\n<doc/>", 2},
+		{"application/xml", "<doc/><doc/>", 1},
+	}
+	for _, test := range tests {
+		_, err := validator.Decode(test.contentType, []byte(test.body))
+		se, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("%s %q: err = %v, want a syntax error", test.contentType, test.body, err)
+			continue
+		}
+		if se.Line != test.line {
+			t.Errorf("%s %q: line = %d, want %d", test.contentType, test.body, se.Line, test.line)
+		}
+	}
+}
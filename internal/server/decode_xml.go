@@ -0,0 +1,157 @@
+package server
+
+import (
+	"bytes"
+	"encoding/xml"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"io"
+	"json-schema-validation/lib/tkt"
+	"strings"
+)
+
+const (
+	xmlSchemaInstance = "http://www.w3.org/2001/XMLSchema-instance"
+	xmlTextProperty   = "#text"
+)
+
+type xmlElement struct {
+	name     string
+	attrs    []xml.Attr
+	children []*xmlElement
+	text     strings.Builder
+	position Position
+}
+
+func (o *xmlElement) isNil() bool {
+	for _, a := range o.attrs {
+		if a.Name.Space == xmlSchemaInstance && a.Name.Local == "nil" {
+			return a.Value == "true" || a.Value == "1"
+		}
+	}
+	return false
+}
+
+func (o *xmlElement) properties() []xml.Attr {
+	result := make([]xml.Attr, 0, len(o.attrs))
+	for _, a := range o.attrs {
+		if a.Name.Space == "xmlns" || a.Name.Local == "xmlns" || a.Name.Space == xmlSchemaInstance {
+			continue
+		}
+		result = append(result, a)
+	}
+	return result
+}
+
+type xmlDecoder struct {
+	positions map[string]Position
+}
+
+// decodeXml maps an XML document onto the JSON data model: the root element is the
+// document, attributes and child elements become properties, repeated child elements
+// (or any element whose schema is an array) become arrays, and text is converted to the
+// type the schema expects.
+func decodeXml(schema *jsonschema.Schema, data []byte) (*Document, error) {
+	root, err := parseXmlTree(data)
+	if err != nil {
+		return nil, err
+	}
+	d := xmlDecoder{positions: make(map[string]Position)}
+	return &Document{Value: d.convert(root, schema, ""), positions: d.positions}, nil
+}
+
+func parseXmlTree(data []byte) (*xmlElement, error) {
+	lines := newLineIndex(data)
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var root *xmlElement
+	stack := make([]*xmlElement, 0)
+	for {
+		offset := decoder.InputOffset()
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if se, ok := err.(*xml.SyntaxError); ok {
+				return nil, lines.syntaxError(se.Msg, decoder.InputOffset())
+			}
+			return nil, &SyntaxError{Message: err.Error()}
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			element := &xmlElement{name: t.Name.Local, attrs: t.Attr, position: lines.position(offset)}
+			if len(stack) > 0 {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, element)
+			} else if root == nil {
+				root = element
+			} else {
+				return nil, lines.syntaxError("a document has a single root element", offset)
+			}
+			stack = append(stack, element)
+		case xml.EndElement:
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) > 0 {
+				stack[len(stack)-1].text.Write(t)
+			}
+		}
+	}
+	if root == nil {
+		return nil, &SyntaxError{Message: "missing root element"}
+	}
+	return root, nil
+}
+
+func (o *xmlDecoder) convert(element *xmlElement, schema *jsonschema.Schema, pointer string) interface{} {
+	o.positions[pointer] = element.position
+	if element.isNil() {
+		return nil
+	}
+	attrs := element.properties()
+	text := strings.TrimSpace(element.text.String())
+	if len(attrs) == 0 && len(element.children) == 0 {
+		types := guideTypes(schema)
+		if text == "" && len(types) > 0 && !tkt.InStringList("string", types) {
+			if tkt.InStringList("object", types) {
+				return map[string]interface{}{}
+			}
+			if tkt.InStringList("array", types) {
+				return []interface{}{}
+			}
+		}
+		return coerceScalar(schema, text, text)
+	}
+	object := make(map[string]interface{})
+	for _, a := range attrs {
+		child := childPointer(pointer, a.Name.Local)
+		o.positions[child] = element.position
+		object[a.Name.Local] = coerceScalar(guideProperty(schema, a.Name.Local), a.Value, a.Value)
+	}
+	names := make([]string, 0)
+	groups := make(map[string][]*xmlElement)
+	for _, c := range element.children {
+		if _, ok := groups[c.name]; !ok {
+			names = append(names, c.name)
+		}
+		groups[c.name] = append(groups[c.name], c)
+	}
+	for _, name := range names {
+		group := groups[name]
+		child := childPointer(pointer, name)
+		property := guideProperty(schema, name)
+		if len(group) > 1 || expectsArray(property) {
+			o.positions[child] = group[0].position
+			list := make([]interface{}, len(group))
+			for i, e := range group {
+				list[i] = o.convert(e, guideItems(property, i), indexPointer(child, i))
+			}
+			object[name] = list
+		} else {
+			object[name] = o.convert(group[0], property, child)
+		}
+	}
+	if text != "" {
+		object[xmlTextProperty] = text
+	}
+	return object
+}
@@ -0,0 +1,191 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"gopkg.in/yaml.v3"
+	"io"
+	"json-schema-validation/lib/tkt"
+	"regexp"
+	"strconv"
+)
+
+var yamlLineRegexp = regexp.MustCompile(`line (\d+)`)
+
+// maxYamlAliasNodes bounds the nodes that aliases may expand to, so that a few nested
+// anchors (the billion laughs) cannot make a small body decode into gigabytes.
+const maxYamlAliasNodes = 100000
+
+type yamlDecoder struct {
+	lines     *lineIndex
+	positions map[string]Position
+	// expanding holds the anchored nodes being expanded through an alias, to reject an
+	// alias within its own anchor, which yaml.v3 accepts.
+	expanding map[*yaml.Node]bool
+	expanded  int
+}
+
+func decodeYaml(schema *jsonschema.Schema, data []byte) (*Document, error) {
+	var root yaml.Node
+	lines := newLineIndex(data)
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	err := decoder.Decode(&root)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, yamlSyntaxError(lines, err)
+	}
+	d := yamlDecoder{lines: lines, positions: make(map[string]Position), expanding: make(map[*yaml.Node]bool)}
+	// The payload is one document: a second one would be left unvalidated.
+	var next yaml.Node
+	if err := decoder.Decode(&next); err == nil {
+		return nil, d.syntaxError("a payload holds a single YAML document", &next)
+	} else if !errors.Is(err, io.EOF) {
+		return nil, yamlSyntaxError(lines, err)
+	}
+	var value interface{}
+	if len(root.Content) > 0 {
+		value, err = d.convert(root.Content[0], schema, "")
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &Document{Value: value, positions: d.positions}, nil
+}
+
+func yamlSyntaxError(lines *lineIndex, err error) *SyntaxError {
+	e := &SyntaxError{Message: err.Error()}
+	if m := yamlLineRegexp.FindStringSubmatch(e.Message); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		p := lines.at(line, 1)
+		e.Line, e.Column, e.Offset = p.Line, p.Column, p.Offset
+	}
+	return e
+}
+
+func (o *yamlDecoder) convert(node *yaml.Node, schema *jsonschema.Schema, pointer string) (interface{}, error) {
+	if len(o.expanding) > 0 {
+		o.expanded++
+		if o.expanded > maxYamlAliasNodes {
+			return nil, o.syntaxError("aliases expand to too many nodes", node)
+		}
+	}
+	if _, ok := o.positions[pointer]; !ok {
+		o.positions[pointer] = o.lines.at(node.Line, node.Column)
+	}
+	switch node.Kind {
+	case yaml.AliasNode:
+		if err := o.enterAlias(node); err != nil {
+			return nil, err
+		}
+		defer delete(o.expanding, node.Alias)
+		return o.convert(node.Alias, schema, pointer)
+	case yaml.MappingNode:
+		object := make(map[string]interface{})
+		err := o.fillObject(object, node, schema, pointer)
+		return object, err
+	case yaml.SequenceNode:
+		list := make([]interface{}, len(node.Content))
+		for i, item := range node.Content {
+			var err error
+			list[i], err = o.convert(item, guideItems(schema, i), indexPointer(pointer, i))
+			if err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	default:
+		return o.convertScalar(node, schema)
+	}
+}
+
+func (o *yamlDecoder) fillObject(object map[string]interface{}, node *yaml.Node, schema *jsonschema.Schema, pointer string) error {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if key.Tag == "!!merge" {
+			if err := o.merge(object, value, schema, pointer); err != nil {
+				return err
+			}
+			continue
+		}
+		child := childPointer(pointer, key.Value)
+		o.positions[child] = o.lines.at(key.Line, key.Column)
+		v, err := o.convert(value, guideProperty(schema, key.Value), child)
+		if err != nil {
+			return err
+		}
+		object[key.Value] = v
+	}
+	return nil
+}
+
+func (o *yamlDecoder) merge(object map[string]interface{}, node *yaml.Node, schema *jsonschema.Schema, pointer string) error {
+	if node.Kind == yaml.AliasNode {
+		if err := o.enterAlias(node); err != nil {
+			return err
+		}
+		defer delete(o.expanding, node.Alias)
+		node = node.Alias
+	}
+	switch node.Kind {
+	case yaml.MappingNode:
+		return o.fillObject(object, node, schema, pointer)
+	case yaml.SequenceNode:
+		for _, n := range node.Content {
+			if err := o.merge(object, n, schema, pointer); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		return o.syntaxError("merge value is not a mapping", node)
+	}
+}
+
+// enterAlias marks the anchored node of the alias as being expanded, failing when it
+// already is.
+func (o *yamlDecoder) enterAlias(alias *yaml.Node) error {
+	if o.expanding[alias.Alias] {
+		return o.syntaxError("alias *"+alias.Value+" refers to itself", alias)
+	}
+	o.expanding[alias.Alias] = true
+	return nil
+}
+
+func (o *yamlDecoder) convertScalar(node *yaml.Node, schema *jsonschema.Schema) (interface{}, error) {
+	var native interface{} = node.Value
+	var err error
+	switch node.ShortTag() {
+	case "!!null":
+		// null, ~ and empty values are strings where the schema expects one, unless it allows
+		// null too or the null is tagged.
+		types := guideTypes(schema)
+		if node.Style&yaml.TaggedStyle == 0 && tkt.InStringList("string", types) && !tkt.InStringList("null", types) {
+			return node.Value, nil
+		}
+		return nil, nil
+	case "!!bool":
+		var b bool
+		err = node.Decode(&b)
+		native = b
+	case "!!int":
+		var i int64
+		err = node.Decode(&i)
+		native = float64(i)
+	case "!!float":
+		var f float64
+		err = node.Decode(&f)
+		native = f
+	}
+	if err != nil {
+		return nil, o.syntaxError(err.Error(), node)
+	}
+	if node.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0 {
+		return node.Value, nil
+	}
+	return coerceScalar(schema, node.Value, native), nil
+}
+
+func (o *yamlDecoder) syntaxError(message string, node *yaml.Node) *SyntaxError {
+	p := o.lines.at(node.Line, node.Column)
+	return &SyntaxError{Message: message, Line: p.Line, Column: p.Column, Offset: p.Offset}
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDecodeYamlAliases(t *testing.T) {
+	doc, err := decodeYaml(nil, []byte("a: &a [1, 2]\nb: *a\nc:\n  <<: &m {x: 1}\n  y: 2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := doc.Value.(map[string]interface{})
+	if b := m["b"].([]interface{}); len(b) != 2 {
+		t.Errorf("b = %v", b)
+	}
+	if c := m["c"].(map[string]interface{}); c["x"] != 1.0 || c["y"] != 2.0 {
+		t.Errorf("c = %v", c)
+	}
+}
+
+func TestDecodeYamlRejectsRecursiveAliases(t *testing.T) {
+	for _, body := range []string{"a: &a [*a]", "a: &a {b: *a}", "a: &a {<<: *a}"} {
+		_, err := decodeYaml(nil, []byte(body))
+		if _, ok := err.(*SyntaxError); !ok {
+			t.Errorf("%q: err = %v, want a syntax error", body, err)
+		}
+	}
+}
+
+func TestDecodeYamlRejectsAliasBomb(t *testing.T) {
+	b := strings.Builder{}
+	b.WriteString("a0: &a0 [x, x, x, x, x, x, x, x, x, x]\n")
+	for i := 1; i < 9; i++ {
+		prev := "*a" + string(rune('0'+i-1))
+		b.WriteString("a" + string(rune('0'+i)) + ": &a" + string(rune('0'+i)) + " [")
+		b.WriteString(strings.TrimSuffix(strings.Repeat(prev+", ", 10), ", "))
+		b.WriteString("]\n")
+	}
+	_, err := decodeYaml(nil, []byte(b.String()))
+	if err == nil || !strings.Contains(err.Error(), "too many nodes") {
+		t.Errorf("err = %v, want too many nodes", err)
+	}
+}
@@ -0,0 +1,146 @@
+package server
+
+import (
+	"reflect"
+	"testing"
+)
+
+const explainSchema = `{
+	"type": "object",
+	"required": ["email"],
+	"additionalProperties": false,
+	"properties": {
+		"email": {"title": "Email address", "type": "string", "format": "email"},
+		"tier": {"description": " Coverage tier ", "enum": ["EE", "FAM"]},
+		"count": {"type": "integer"},
+		"rates": {"type": "array", "items": {"type": "number"}}
+	}
+}`
+
+// explanations validates body and returns the explanation of every failure leaf by keyword
+// location.
+func explanations(t *testing.T, validator *Validator, body string, lang string, withDoc bool) map[string]*ValidationFailure {
+	doc, err := validator.Decode("application/json", []byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	failure := validator.Validate(doc, false)
+	if failure == nil {
+		t.Fatalf("%s accepted", body)
+	}
+	if !withDoc {
+		doc = nil
+	}
+	validator.Explain(failure, doc, lang)
+	result := make(map[string]*ValidationFailure)
+	for _, leaf := range failure.Leaves() {
+		result[leaf.KeywordLocation] = leaf
+	}
+	return result
+}
+
+func TestExplain(t *testing.T) {
+	validator := &Validator{schema: compileTestSchema(t, explainSchema)}
+	tests := []struct {
+		body string
+		lang string
+		want map[string]string
+	}{
+		{`{"email": "a@b", "count": "x"}`, "en", map[string]string{
+			"/properties/count/type": "'count' must be a whole number, but it is text.",
+		}},
+		{`{"email": "a@b", "count": "x"}`, "es", map[string]string{
+			"/properties/count/type": "'count' debe ser un número entero, pero es texto.",
+		}},
+		{`{"email": "a@b", "tier": "XX"}`, "en", map[string]string{
+			"/properties/tier/enum": "'tier' must be one of 'EE', 'FAM'.",
+		}},
+		{`{}`, "es", map[string]string{
+			"/required": "Faltan campos obligatorios en el documento: email.",
+		}},
+		{`{"emial": "a@b"}`, "en", map[string]string{
+			"/required":             "the document is missing required fields: email. Did you mean 'email' instead of 'emial'?",
+			"/additionalProperties": "the document has fields that are not allowed: emial. Did you mean 'email' instead of 'emial'?",
+		}},
+		{`{"email": "a@b", "rates": [1, "x"]}`, "en", map[string]string{
+			"/properties/rates/items/type": "item 1 of 'rates' must be a number, but it is text.",
+		}},
+		{`{"email": 5}`, "es", map[string]string{
+			"/properties/email/type": "Email address debe escribirse como name@example.com.",
+		}},
+	}
+	for _, test := range tests {
+		got := make(map[string]string)
+		for location, leaf := range explanations(t, validator, test.body, test.lang, true) {
+			got[location] = leaf.Explanation
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s in %s: got %q, want %q", test.body, test.lang, got, test.want)
+		}
+	}
+}
+
+func TestExplainFillsSchemaFields(t *testing.T) {
+	validator := &Validator{schema: compileTestSchema(t, explainSchema)}
+	tier := explanations(t, validator, `{"email": "a@b", "tier": "XX"}`, "en", true)["/properties/tier/enum"]
+	if tier.Description != "Coverage tier" || !reflect.DeepEqual(tier.Allowed, []interface{}{"EE", "FAM"}) {
+		t.Errorf("tier: description %q, allowed %v", tier.Description, tier.Allowed)
+	}
+	email := explanations(t, validator, `{"email": 5}`, "en", true)["/properties/email/type"]
+	if email.Title != "Email address" || email.Example != "name@example.com" {
+		t.Errorf("email: title %q, example %q", email.Title, email.Example)
+	}
+}
+
+func TestExplainWithoutDocument(t *testing.T) {
+	validator := &Validator{schema: compileTestSchema(t, explainSchema)}
+	leaves := explanations(t, validator, `{"emial": "a@b"}`, "en", false)
+	if s := leaves["/required"].Suggestion; s != "" {
+		t.Errorf("required suggestion %q without the document", s)
+	}
+	if s := leaves["/additionalProperties"].Suggestion; s != "Did you mean 'email' instead of 'emial'?" {
+		t.Errorf("additionalProperties suggestion %q", s)
+	}
+}
+
+func TestExplainSuggestsClosestName(t *testing.T) {
+	validator := &Validator{schema: compileTestSchema(t, `{
+		"required": ["name"],
+		"additionalProperties": false,
+		"properties": {"name": {}, "names": {}, "lane": {}, "game": {}}
+	}`)}
+	tests := []struct {
+		body     string
+		location string
+		want     string
+	}{
+		{`{"name": 1, "nane": 1}`, "/additionalProperties", "Did you mean 'lane' instead of 'nane'?"},
+		{`{"name": 1, "nmae": 1}`, "/additionalProperties", "Did you mean 'name' instead of 'nmae'?"},
+		{`{"name": 1, "namess": 1}`, "/additionalProperties", "Did you mean 'names' instead of 'namess'?"},
+		{`{"nmae": 1, "anme": 1, "Name": 1}`, "/required", "Did you mean 'name' instead of 'Name'?"},
+		{`{"nmae": 1, "anme": 1}`, "/required", "Did you mean 'name' instead of 'anme'?"},
+	}
+	for _, test := range tests {
+		for i := 0; i < 20; i++ {
+			if got := explanations(t, validator, test.body, "en", true)[test.location].Suggestion; got != test.want {
+				t.Fatalf("%s: suggestion %q, want %q", test.body, got, test.want)
+			}
+		}
+	}
+}
+
+func TestResolveLanguage(t *testing.T) {
+	for accept, want := range map[string]string{
+		"":                "en",
+		"es":              "es",
+		"es-MX,en;q=0.8":  "es",
+		"fr, es;q=0.5":    "es",
+		"es_ES":           "es",
+		"de-DE, fr":       "en",
+		" EN-us ; q=0.9 ": "en",
+	} {
+		if got := ResolveLanguage(accept); got != want {
+			t.Errorf("ResolveLanguage(%q) = %q, want %q", accept, got, want)
+		}
+	}
+}
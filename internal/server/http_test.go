@@ -0,0 +1,123 @@
+package server
+
+import (
+	"json-schema-validation/internal/audit"
+	"json-schema-validation/lib/tkt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// memorySink keeps audit entries in memory.
+type memorySink struct {
+	entries []*audit.Entry
+}
+
+func (o *memorySink) Append(entry *audit.Entry) {
+	clone := *entry
+	o.entries = append(o.entries, &clone)
+}
+
+func (o *memorySink) Last() *audit.Entry {
+	if len(o.entries) == 0 {
+		return nil
+	}
+	return o.entries[len(o.entries)-1]
+}
+
+func (o *memorySink) Entries(visit func(entry *audit.Entry) error) error {
+	for _, entry := range o.entries {
+		if err := visit(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func testServer() (*httpServer, *memorySink) {
+	sink := &memorySink{}
+	s := newHttpServer()
+	s.audit = audit.NewTrail(sink)
+	return s, sink
+}
+
+func post(s *httpServer, query string, contentType string, body string, chunked bool) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/validate"+query, strings.NewReader(body))
+	r.Header.Set("Content-Type", contentType)
+	if chunked {
+		r.ContentLength = -1
+	}
+	w := httptest.NewRecorder()
+	s.validate(w, r)
+	return w
+}
+
+func TestStreamingIsOptIn(t *testing.T) {
+	s, sink := testServer()
+	body := `{"transmissionGUID": "g", "senderName": "s"}`
+	for _, chunked := range []bool{false, true} {
+		if w := post(s, "", "application/json", body, chunked); w.Code != http.StatusOK {
+			t.Fatalf("chunked %t: status %d", chunked, w.Code)
+		}
+		if digest := sink.Last().PayloadDigest; !strings.HasPrefix(digest, "sha256:") {
+			t.Errorf("chunked %t: digest %s, want the sanitized one", chunked, digest)
+		}
+	}
+	if w := post(s, "?stream=true", "application/json", body, true); w.Code != http.StatusOK {
+		t.Fatalf("stream: status %d", w.Code)
+	}
+	if digest := sink.Last().PayloadDigest; !strings.HasPrefix(digest, "sha256-raw:") {
+		t.Errorf("stream: digest %s, want the raw one", digest)
+	}
+}
+
+func TestStreamRejectsUnsupportedOptions(t *testing.T) {
+	s, sink := testServer()
+	for _, c := range []struct{ query, contentType string }{
+		{"?stream=true&normalize=true", "application/json"},
+		{"?stream=true", "application/yaml"},
+		{"?stream=true", "application/xml"},
+	} {
+		w := post(s, c.query, c.contentType, `{}`, true)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("%s %s: status %d, want 400", c.query, c.contentType, w.Code)
+		}
+	}
+	if len(sink.entries) != 0 {
+		t.Errorf("%d decisions recorded for refused requests", len(sink.entries))
+	}
+}
+
+func TestLenientAssignsGuidToChunkedBodiesAndRefusesStreaming(t *testing.T) {
+	s, sink := testServer()
+	guids, _ := tkt.IdGeneratorOf(tkt.UuidV7)
+	s.validator.SetLenient(guids)
+	w := post(s, "", "application/json", `{"senderName": "s"}`, true)
+	if w.Code != http.StatusOK || w.Header().Get(transmissionGuidHeader) == "" {
+		t.Fatalf("chunked: status %d, guid %q", w.Code, w.Header().Get(transmissionGuidHeader))
+	}
+	w = post(s, "?stream=true", "application/json", `{"senderName": "s"}`, true)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "lenient") {
+		t.Errorf("stream: status %d, body %s", w.Code, w.Body.String())
+	}
+	if len(sink.entries) != 1 {
+		t.Errorf("%d decisions recorded, want the chunked one", len(sink.entries))
+	}
+}
+
+func TestPoolStatsOnlyOnTheAdminServer(t *testing.T) {
+	for _, test := range []struct {
+		server *http.Server
+		want   int
+	}{
+		{NewHttpServer(":0", nil, nil, nil, nil, nil), http.StatusNotFound},
+		{NewAdminServer(":0"), http.StatusOK},
+	} {
+		w := httptest.NewRecorder()
+		test.server.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/stats/pools", nil))
+		if w.Code != test.want {
+			t.Errorf("status %d, want %d", w.Code, test.want)
+		}
+	}
+}
@@ -0,0 +1,514 @@
+package server
+
+import (
+	"bytes"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"json-schema-validation/lib/tkt"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+const defaultLanguage = "en"
+
+var (
+	typeMessageRegexp       = regexp.MustCompile(`^expected (.+), but got (\w+)$`)
+	requiredMessageRegexp   = regexp.MustCompile(`^missing properties: (.+)$`)
+	additionalMessageRegexp = regexp.MustCompile(`^additionalProperties (.+) not allowed$`)
+	quotedNameRegexp        = regexp.MustCompile(`'((?:[^'\\]|\\.)*)'`)
+)
+
+var formatExamples = map[string]string{
+	"date":      "YYYY-MM-DD",
+	"date-time": "YYYY-MM-DDTHH:MM:SSZ",
+	"time":      "HH:MM:SSZ",
+	"uuid":      "xxxxxxxx-xxxx-xxxx-xxxx-xxxxxxxxxxxx",
+	"decimal":   "1234.56",
+	"email":     "name@example.com",
+	"uri":       "https://example.com/path",
+}
+
+// messageTemplates holds, per language, one template per failing keyword. "default" is used
+// for keywords without their own template and "document" names the root of the payload.
+var messageTemplates = map[string]map[string]string{
+	"en": {
+		"default":              `{{.Property}}: {{.Message}}`,
+		"document":             `the document`,
+		"item":                 `item {{.Index}} of {{.Property}}`,
+		"type":                 `{{.Property}} must be {{.Expected}}, but it is {{.Actual}}.`,
+		"enum":                 `{{.Property}} must be one of {{.Allowed}}.`,
+		"required":             `{{.Property}} is missing required fields: {{.Names}}.`,
+		"additionalProperties": `{{.Property}} has fields that are not allowed: {{.Names}}.`,
+		"oneOf":                `{{.Property}} does not match exactly one of the allowed shapes.`,
+		"anyOf":                `{{.Property}} does not match any of the allowed shapes.`,
+		"format":               `{{.Property}} must be written like {{.Example}}.`,
+		"suggestion":           `Did you mean '{{.Expected}}' instead of '{{.Actual}}'?`,
+		"or":                   `or`,
+		"string":               `text`,
+		"number":               `a number`,
+		"integer":              `a whole number`,
+		"boolean":              `true or false`,
+		"object":               `an object`,
+		"array":                `a list`,
+		"null":                 `null`,
+	},
+	"es": {
+		"default":              `{{.Property}}: {{.Message}}`,
+		"document":             `el documento`,
+		"item":                 `el elemento {{.Index}} de {{.Property}}`,
+		"type":                 `{{.Property}} debe ser {{.Expected}}, pero es {{.Actual}}.`,
+		"enum":                 `{{.Property}} debe ser uno de {{.Allowed}}.`,
+		"required":             `Faltan campos obligatorios en {{.Property}}: {{.Names}}.`,
+		"additionalProperties": `{{.Property}} tiene campos no permitidos: {{.Names}}.`,
+		"oneOf":                `{{.Property}} no coincide con exactamente una de las formas permitidas.`,
+		"anyOf":                `{{.Property}} no coincide con ninguna de las formas permitidas.`,
+		"format":               `{{.Property}} debe escribirse como {{.Example}}.`,
+		"suggestion":           `¿Quiso decir '{{.Expected}}' en lugar de '{{.Actual}}'?`,
+		"or":                   `o`,
+		"string":               `texto`,
+		"number":               `un número`,
+		"integer":              `un número entero`,
+		"boolean":              `verdadero o falso`,
+		"object":               `un objeto`,
+		"array":                `una lista`,
+		"null":                 `nulo`,
+	},
+}
+
+type messageData struct {
+	Property string
+	Message  string
+	Index    string
+	Expected string
+	Actual   string
+	Allowed  string
+	Names    string
+	Example  string
+}
+
+// parsedTemplates holds the messageTemplates parsed, a nil template for those that do not
+// parse. Both maps are guarded by templatesMux, since templates may be added while requests
+// are explained.
+var parsedTemplates = parseMessageTemplates(messageTemplates)
+var templatesMux = sync.RWMutex{}
+
+func parseMessageTemplates(languages map[string]map[string]string) map[string]map[string]*template.Template {
+	parsed := make(map[string]map[string]*template.Template, len(languages))
+	for lang, templates := range languages {
+		parsed[lang] = make(map[string]*template.Template, len(templates))
+		for name, text := range templates {
+			parsed[lang][name] = parseMessageTemplate(name, text)
+		}
+	}
+	return parsed
+}
+
+func parseMessageTemplate(name string, text string) *template.Template {
+	t, err := template.New(name).Parse(text)
+	if err != nil {
+		return nil
+	}
+	return t
+}
+
+// AddMessageTemplates registers or overrides the message templates of a language.
+func AddMessageTemplates(lang string, templates map[string]string) {
+	templatesMux.Lock()
+	defer templatesMux.Unlock()
+	m, ok := messageTemplates[lang]
+	if !ok {
+		m = make(map[string]string)
+		messageTemplates[lang] = m
+		parsedTemplates[lang] = make(map[string]*template.Template)
+	}
+	for k, v := range templates {
+		m[k] = v
+		parsedTemplates[lang][k] = parseMessageTemplate(k, v)
+	}
+}
+
+// ResolveLanguage picks the first supported language of an Accept-Language style list.
+func ResolveLanguage(accept string) string {
+	templatesMux.RLock()
+	defer templatesMux.RUnlock()
+	for _, part := range strings.Split(accept, ",") {
+		tag := strings.ToLower(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+		tag = strings.ReplaceAll(tag, "_", "-")
+		if _, ok := messageTemplates[tag]; ok {
+			return tag
+		}
+		if i := strings.IndexByte(tag, '-'); i > 0 {
+			if _, ok := messageTemplates[tag[:i]]; ok {
+				return tag[:i]
+			}
+		}
+	}
+	return defaultLanguage
+}
+
+type messageRenderer struct {
+	root *jsonschema.Schema
+	lang string
+	doc  *Document
+}
+
+// Explain fills the human oriented fields of every leaf failure: the title and description
+// of the failing property, the allowed values, a format example, a typo suggestion and a
+// message rendered in the given language. doc may be nil when the payload was streamed,
+// in which case no suggestions are made.
+func (o *Validator) Explain(failure *ValidationFailure, doc *Document, lang string) {
+	if failure == nil {
+		return
+	}
+	r := &messageRenderer{root: o.schema, lang: ResolveLanguage(lang), doc: doc}
+	for _, leaf := range failure.Leaves() {
+		r.explain(leaf)
+	}
+}
+
+func (o *messageRenderer) explain(failure *ValidationFailure) {
+	chain, keyword := resolveKeywordLocation(o.root, failure.KeywordLocation)
+	data := messageData{Property: o.propertyName(chain, failure.InstanceLocation), Message: failure.Message}
+	for _, s := range chain {
+		if failure.Title == "" {
+			failure.Title = s.Title
+		}
+		if failure.Description == "" {
+			failure.Description = strings.TrimSpace(s.Description)
+		}
+		if failure.Allowed == nil && len(s.Enum) > 0 {
+			failure.Allowed = s.Enum
+		}
+		if failure.Example == "" {
+			failure.Example = formatExamples[s.Format]
+		}
+	}
+	data.Example = failure.Example
+	template := keyword
+	switch keyword {
+	case "type":
+		if m := typeMessageRegexp.FindStringSubmatch(failure.Message); m != nil {
+			expected := make([]string, 0)
+			for _, t := range strings.Split(m[1], " or ") {
+				expected = append(expected, o.text(t, data))
+			}
+			data.Expected = strings.Join(expected, " "+o.text("or", data)+" ")
+			data.Actual = o.text(m[2], data)
+			if failure.Example != "" && strings.Contains(m[1], "string") {
+				template = "format"
+			}
+		} else {
+			template = "default"
+		}
+	case "enum":
+		data.Allowed = joinValues(failure.Allowed)
+	case "required":
+		names := quotedNames(requiredMessageRegexp, failure.Message)
+		data.Names = strings.Join(names, ", ")
+		failure.Suggestion = o.suggestMissing(chain, failure.InstanceLocation, names, data)
+	case "additionalProperties":
+		names := quotedNames(additionalMessageRegexp, failure.Message)
+		data.Names = strings.Join(names, ", ")
+		failure.Suggestion = o.suggestUnknown(chain, names, data)
+	case "oneOf":
+		if failure.Message == "oneOf failed" {
+			template = "default"
+		}
+	case "anyOf":
+	default:
+		template = "default"
+	}
+	failure.Explanation = o.text(template, data)
+	if failure.Suggestion != "" {
+		failure.Explanation += " " + failure.Suggestion
+	}
+}
+
+// messageTemplate returns the parsed template of the language, falling back to English and
+// then to the default template.
+func messageTemplate(lang string, name string) *template.Template {
+	templatesMux.RLock()
+	defer templatesMux.RUnlock()
+	t, ok := parsedTemplates[lang][name]
+	if !ok {
+		t, ok = parsedTemplates[defaultLanguage][name]
+	}
+	if !ok {
+		t = parsedTemplates[defaultLanguage]["default"]
+	}
+	return t
+}
+
+func (o *messageRenderer) text(name string, data messageData) string {
+	t := messageTemplate(o.lang, name)
+	if t == nil {
+		return data.Message
+	}
+	buf := bytes.Buffer{}
+	if err := t.Execute(&buf, data); err != nil {
+		return data.Message
+	}
+	return buf.String()
+}
+
+func (o *messageRenderer) propertyName(chain []*jsonschema.Schema, pointer string) string {
+	if pointer == "" {
+		return o.text("document", messageData{})
+	}
+	for _, s := range chain {
+		if s.Title != "" {
+			return s.Title
+		}
+	}
+	tokens := pointerTokens(pointer)
+	name := tokens[len(tokens)-1]
+	if _, err := strconv.Atoi(name); err == nil && len(tokens) > 1 {
+		return o.text("item", messageData{Index: name, Property: "'" + tokens[len(tokens)-2] + "'"})
+	}
+	return "'" + name + "'"
+}
+
+func (o *messageRenderer) suggestMissing(chain []*jsonschema.Schema, pointer string, missing []string, data messageData) string {
+	if o.doc == nil {
+		return ""
+	}
+	object, ok := valueAt(o.doc.Value, pointer).(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	known := schemaPropertyNames(chain)
+	keys := make([]string, 0, len(object))
+	for key := range object {
+		if !known[key] {
+			keys = append(keys, key)
+		}
+	}
+	for _, name := range missing {
+		if key := closestName(name, keys, func(key string) bool { return similarNames(key, name) }); key != "" {
+			data.Expected, data.Actual = name, key
+			return o.text("suggestion", data)
+		}
+	}
+	return ""
+}
+
+func (o *messageRenderer) suggestUnknown(chain []*jsonschema.Schema, unknown []string, data messageData) string {
+	known := schemaPropertyNames(chain)
+	properties := make([]string, 0, len(known))
+	for property := range known {
+		properties = append(properties, property)
+	}
+	for _, name := range unknown {
+		if property := closestName(name, properties, func(property string) bool { return similarNames(name, property) }); property != "" {
+			data.Expected, data.Actual = property, name
+			return o.text("suggestion", data)
+		}
+	}
+	return ""
+}
+
+// resolveKeywordLocation follows a keyword location from the root schema. It returns the
+// schemas that apply to the failing instance, from the one that reached it through a
+// property or item down to the one holding the failing keyword, and that keyword.
+func resolveKeywordLocation(root *jsonschema.Schema, location string) ([]*jsonschema.Schema, string) {
+	chain := []*jsonschema.Schema{root}
+	s := root
+	tokens := pointerTokens(location)
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		var next *jsonschema.Schema
+		reset := false
+		switch token {
+		case "properties":
+			if i+1 < len(tokens) {
+				i++
+				next = s.Properties[tokens[i]]
+				reset = true
+			}
+		case "patternProperties":
+			if i+1 < len(tokens) {
+				i++
+				for pattern, p := range s.PatternProperties {
+					if pattern.String() == tokens[i] {
+						next = p
+					}
+				}
+				reset = true
+			}
+		case "additionalProperties":
+			next, _ = s.AdditionalProperties.(*jsonschema.Schema)
+			reset = true
+		case "items":
+			if s.Items2020 != nil {
+				next = s.Items2020
+			} else if items, ok := s.Items.(*jsonschema.Schema); ok {
+				next = items
+			}
+			reset = true
+		case "prefixItems", "allOf", "anyOf", "oneOf":
+			if i+1 < len(tokens) {
+				index, err := strconv.Atoi(tokens[i+1])
+				if err != nil {
+					break
+				}
+				i++
+				list := map[string][]*jsonschema.Schema{"prefixItems": s.PrefixItems, "allOf": s.AllOf,
+					"anyOf": s.AnyOf, "oneOf": s.OneOf}[token]
+				if index < len(list) {
+					next = list[index]
+				}
+				reset = token == "prefixItems"
+			}
+		case "$ref":
+			next = s.Ref
+		}
+		if next == nil {
+			return chain, strings.Join(tokens[i:], "/")
+		}
+		if reset {
+			chain = chain[:0]
+		}
+		chain = append(chain, next)
+		s = next
+	}
+	return chain, ""
+}
+
+func schemaPropertyNames(chain []*jsonschema.Schema) map[string]bool {
+	names := make(map[string]bool)
+	for _, s := range chain {
+		for _, g := range guideSchemas(s) {
+			for name := range g.Properties {
+				names[name] = true
+			}
+		}
+	}
+	return names
+}
+
+func pointerTokens(pointer string) []string {
+	if pointer == "" {
+		return nil
+	}
+	tokens := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i, t := range tokens {
+		if u, err := url.PathUnescape(t); err == nil {
+			t = u
+		}
+		t = strings.ReplaceAll(t, "~1", "/")
+		tokens[i] = strings.ReplaceAll(t, "~0", "~")
+	}
+	return tokens
+}
+
+func valueAt(value interface{}, pointer string) interface{} {
+	for _, token := range pointerTokens(pointer) {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			value = v[token]
+		case []interface{}:
+			i, err := strconv.Atoi(token)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil
+			}
+			value = v[i]
+		default:
+			return nil
+		}
+	}
+	return value
+}
+
+func quotedNames(r *regexp.Regexp, message string) []string {
+	m := r.FindStringSubmatch(message)
+	if m == nil {
+		return nil
+	}
+	names := make([]string, 0)
+	for _, q := range quotedNameRegexp.FindAllStringSubmatch(m[1], -1) {
+		names = append(names, strings.ReplaceAll(q[1], `\'`, `'`))
+	}
+	return names
+}
+
+func joinValues(values []interface{}) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		if s, ok := v.(string); ok {
+			parts[i] = "'" + s + "'"
+		} else {
+			parts[i] = string(tkt.Marshal(v))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// similarNames tells whether two property names probably differ only by a typo: a change
+// of case, or at most one edit (insertion, deletion, substitution or transposition of
+// adjacent characters) per five characters.
+func similarNames(a string, b string) bool {
+	if a == b {
+		return false
+	}
+	la, lb := strings.ToLower(a), strings.ToLower(b)
+	if la == lb {
+		return true
+	}
+	limit := len(lb)/5 + 1
+	return editDistance(la, lb) <= limit
+}
+
+// closestName returns the similar candidate at the smallest edit distance from name, the
+// first in alphabetical order among equals, or "" when none is similar.
+func closestName(name string, candidates []string, similar func(candidate string) bool) string {
+	sort.Strings(candidates)
+	best, bestDistance := "", -1
+	for _, candidate := range candidates {
+		if !similar(candidate) {
+			continue
+		}
+		if d := editDistance(strings.ToLower(name), strings.ToLower(candidate)); bestDistance < 0 || d < bestDistance {
+			best, bestDistance = candidate, d
+		}
+	}
+	return best
+}
+
+func editDistance(a string, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			d[i][j] = minInt(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
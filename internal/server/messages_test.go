@@ -0,0 +1,41 @@
+package server
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestAddMessageTemplates(t *testing.T) {
+	validator := testValidator(t, `{"required": ["name"]}`)
+	AddMessageTemplates("xx", map[string]string{"required": `falta {{.Names}}`})
+	doc := &Document{Value: map[string]interface{}{}}
+	failure := validator.Validate(doc, false)
+	validator.Explain(failure, doc, "xx")
+	if explanation := failure.Leaves()[0].Explanation; explanation != "falta name" {
+		t.Errorf("explanation %q", explanation)
+	}
+}
+
+func TestAddMessageTemplatesWhileExplaining(t *testing.T) {
+	validator := testValidator(t, `{"required": ["name"]}`)
+	wg := sync.WaitGroup{}
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		lang := "l" + strconv.Itoa(i)
+		go func() {
+			defer wg.Done()
+			AddMessageTemplates(lang, map[string]string{"required": `missing {{.Names}}`})
+		}()
+		go func() {
+			defer wg.Done()
+			doc := &Document{Value: map[string]interface{}{}}
+			failure := validator.Validate(doc, false)
+			validator.Explain(failure, doc, lang)
+			if failure.Leaves()[0].Explanation == "" {
+				t.Error("no explanation")
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"strings"
+	"time"
+)
+
+var dateLayouts = []string{
+	"2006-01-02",
+	"2006/01/02",
+	"01/02/2006",
+	"20060102",
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+}
+
+var dateTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+// Normalize returns a copy of the document with schema defaults filled in, strings trimmed,
+// enum values matched to their canonical case and dates in RFC 3339 form. The original
+// document is left untouched.
+func Normalize(schema *jsonschema.Schema, doc interface{}) interface{} {
+	return normalizeValue(schema, doc)
+}
+
+func normalizeValue(schema *jsonschema.Schema, value interface{}) interface{} {
+	if schema == nil {
+		return value
+	}
+	if schema.Ref != nil {
+		value = normalizeValue(schema.Ref, value)
+	}
+	if schema.DynamicRef != nil {
+		value = normalizeValue(schema.DynamicRef, value)
+	}
+	if schema.RecursiveRef != nil {
+		value = normalizeValue(schema.RecursiveRef, value)
+	}
+	for _, s := range schema.AllOf {
+		value = normalizeValue(s, value)
+	}
+	value = normalizeAlternatives(schema.OneOf, value)
+	value = normalizeAlternatives(schema.AnyOf, value)
+	switch v := value.(type) {
+	case map[string]interface{}:
+		return normalizeObject(schema, v)
+	case []interface{}:
+		return normalizeArray(schema, v)
+	case string:
+		return normalizeString(schema, v)
+	default:
+		return value
+	}
+}
+
+func normalizeAlternatives(alternatives []*jsonschema.Schema, value interface{}) interface{} {
+	for _, s := range alternatives {
+		normalized := normalizeValue(s, value)
+		if s.Validate(normalized) == nil {
+			return normalized
+		}
+	}
+	return value
+}
+
+func normalizeObject(schema *jsonschema.Schema, object map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{}, len(object))
+	for key, value := range object {
+		if s, ok := schema.Properties[key]; ok {
+			result[key] = normalizeValue(s, value)
+		} else if s, ok := schema.AdditionalProperties.(*jsonschema.Schema); ok {
+			result[key] = normalizeValue(s, value)
+		} else {
+			result[key] = value
+		}
+	}
+	for key, s := range schema.Properties {
+		if _, ok := result[key]; ok {
+			continue
+		}
+		if d := schemaDefault(s); d != nil {
+			result[key] = normalizeValue(s, copyValue(d))
+		}
+	}
+	return result
+}
+
+func normalizeArray(schema *jsonschema.Schema, array []interface{}) []interface{} {
+	result := make([]interface{}, len(array))
+	for i, value := range array {
+		result[i] = normalizeValue(itemSchema(schema, i), value)
+	}
+	return result
+}
+
+func itemSchema(schema *jsonschema.Schema, index int) *jsonschema.Schema {
+	if index < len(schema.PrefixItems) {
+		return schema.PrefixItems[index]
+	}
+	if schema.Items2020 != nil {
+		return schema.Items2020
+	}
+	switch items := schema.Items.(type) {
+	case *jsonschema.Schema:
+		return items
+	case []*jsonschema.Schema:
+		if index < len(items) {
+			return items[index]
+		}
+	}
+	if s, ok := schema.AdditionalItems.(*jsonschema.Schema); ok {
+		return s
+	}
+	return nil
+}
+
+func normalizeString(schema *jsonschema.Schema, s string) interface{} {
+	s = strings.TrimSpace(s)
+	for _, e := range schema.Enum {
+		if option, ok := e.(string); ok && strings.EqualFold(option, s) {
+			return option
+		}
+	}
+	switch schema.Format {
+	case "date":
+		if t, ok := parseTime(s, dateLayouts); ok {
+			return t.Format("2006-01-02")
+		}
+	case "date-time":
+		if t, ok := parseTime(s, dateTimeLayouts); ok {
+			return t.UTC().Format(time.RFC3339Nano)
+		}
+	}
+	return s
+}
+
+func parseTime(s string, layouts []string) (time.Time, bool) {
+	for _, layout := range layouts {
+		t, err := time.Parse(layout, s)
+		if err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+func schemaDefault(schema *jsonschema.Schema) interface{} {
+	for s := schema; s != nil; s = s.Ref {
+		if s.Default != nil {
+			return s.Default
+		}
+	}
+	return nil
+}
+
+func copyValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[key] = copyValue(item)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = copyValue(item)
+		}
+		return result
+	default:
+		return v
+	}
+}
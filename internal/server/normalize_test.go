@@ -0,0 +1,76 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func compileTestSchema(t *testing.T, text string) *jsonschema.Schema {
+	compiler := jsonschema.NewCompiler()
+	compiler.ExtractAnnotations = true
+	if err := compiler.AddResource("normalize.json", strings.NewReader(text)); err != nil {
+		t.Fatal(err)
+	}
+	schema, err := compiler.Compile("normalize.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schema
+}
+
+const normalizeSchema = `{
+	"$defs": {
+		"Status": {"type": "string", "enum": ["active", "terminated"], "default": "active"}
+	},
+	"properties": {
+		"name": {"type": "string"},
+		"status": {"$ref": "#/$defs/Status"},
+		"effectiveDate": {"type": "string", "format": "date"},
+		"createdAt": {"type": "string", "format": "date-time"},
+		"country": {"type": "string", "default": "US"},
+		"plans": {"type": "array", "items": {
+			"properties": {"tier": {"enum": ["EE", "ES", "FAM"]}, "lives": {"type": "integer", "default": 0}}
+		}}
+	}
+}`
+
+func TestNormalize(t *testing.T) {
+	schema := compileTestSchema(t, normalizeSchema)
+	tests := []struct {
+		name string
+		doc  map[string]interface{}
+		want map[string]interface{}
+	}{
+		{"defaults through refs", map[string]interface{}{},
+			map[string]interface{}{"status": "active", "country": "US"}},
+		{"trimmed strings", map[string]interface{}{"name": "  Acme  ", "status": "active", "country": "US"},
+			map[string]interface{}{"name": "Acme", "status": "active", "country": "US"}},
+		{"canonical enum case", map[string]interface{}{"status": " Terminated", "country": "US"},
+			map[string]interface{}{"status": "terminated", "country": "US"}},
+		{"dates", map[string]interface{}{"effectiveDate": "01/31/2024", "createdAt": "2024-01-31 10:00:00", "status": "active", "country": "US"},
+			map[string]interface{}{"effectiveDate": "2024-01-31", "createdAt": "2024-01-31T10:00:00Z", "status": "active", "country": "US"}},
+		{"unparsable date kept", map[string]interface{}{"effectiveDate": "soon", "status": "active", "country": "US"},
+			map[string]interface{}{"effectiveDate": "soon", "status": "active", "country": "US"}},
+		{"array items", map[string]interface{}{"plans": []interface{}{map[string]interface{}{"tier": "fam"}}, "status": "active", "country": "US"},
+			map[string]interface{}{"plans": []interface{}{map[string]interface{}{"tier": "FAM", "lives": json.Number("0")}}, "status": "active", "country": "US"}},
+	}
+	for _, test := range tests {
+		got := Normalize(schema, test.doc)
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestNormalizeLeavesTheOriginal(t *testing.T) {
+	schema := compileTestSchema(t, normalizeSchema)
+	doc := map[string]interface{}{"name": " Acme ", "plans": []interface{}{map[string]interface{}{"tier": "ee"}}}
+	Normalize(schema, doc)
+	want := map[string]interface{}{"name": " Acme ", "plans": []interface{}{map[string]interface{}{"tier": "ee"}}}
+	if !reflect.DeepEqual(doc, want) {
+		t.Errorf("original changed: %v", doc)
+	}
+}
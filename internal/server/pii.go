@@ -0,0 +1,159 @@
+package server
+
+import (
+	"fmt"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"json-schema-validation/lib/tkt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// piiKeyword marks a property as personal data and names how it is redacted.
+const piiKeyword = "x-pii"
+
+var piiMeta = jsonschema.MustCompileString("x-pii.json", `{
+	"properties": {
+		"x-pii": {"enum": ["mask", "partial", "hash", "drop"]}
+	}
+}`)
+
+type piiCompiler struct{}
+
+func (piiCompiler) Compile(ctx jsonschema.CompilerContext, m map[string]interface{}) (jsonschema.ExtSchema, error) {
+	if action, ok := m[piiKeyword].(string); ok {
+		return piiSchema(action), nil
+	}
+	return nil, nil
+}
+
+// piiSchema is the compiled x-pii keyword. It does not validate anything.
+type piiSchema string
+
+func (piiSchema) Validate(ctx jsonschema.ValidationContext, v interface{}) error {
+	return nil
+}
+
+// sanitizedValue stands for a value the sanitizer dropped.
+const sanitizedValue = "**********"
+
+// valueMessageRegexps capture the instance value quoted in the messages of these keywords.
+var valueMessageRegexps = map[string]*regexp.Regexp{
+	"format":           regexp.MustCompile(`^(.*) is not valid '`),
+	"minimum":          regexp.MustCompile(`found (.*)$`),
+	"maximum":          regexp.MustCompile(`found (.*)$`),
+	"exclusiveMinimum": regexp.MustCompile(`found (.*)$`),
+	"exclusiveMaximum": regexp.MustCompile(`found (.*)$`),
+	"multipleOf":       regexp.MustCompile(`^(.*) not multipleOf`),
+}
+
+// newPiiSanitizer returns a sanitizer masking the secret keys and redacting the values the
+// schema marks with x-pii, by their JSON Pointer. Values to hash get an HMAC with hashKey;
+// without a key they are dropped, since a plain hash of a short identifier such as a FEIN
+// is reversed by trying every value.
+func newPiiSanitizer(schema *jsonschema.Schema, hashKey []byte) *tkt.Sanitizer {
+	sanitizer := tkt.NewDefaultSanitizer()
+	if len(hashKey) > 0 {
+		sanitizer.SetHashKey(hashKey)
+	}
+	seen := make(map[string]bool)
+	collectPii(schema, "", make(map[*jsonschema.Schema]bool), func(path string, action string) {
+		if !seen[path] {
+			seen[path] = true
+			if action == tkt.SanitizeHash && len(hashKey) == 0 {
+				action = tkt.SanitizeDrop
+			}
+			sanitizer.AddRule(tkt.SanitizeRule{Path: path, Action: action})
+		}
+	})
+	return sanitizer
+}
+
+// collectPii reports the paths of the x-pii properties under schema, "*" standing for any
+// property name or array index. active guards against recursive schemas.
+func collectPii(schema *jsonschema.Schema, path string, active map[*jsonschema.Schema]bool, add func(path string, action string)) {
+	if schema == nil || active[schema] {
+		return
+	}
+	if action, ok := schema.Extensions[piiKeyword].(piiSchema); ok && path != "" {
+		add(path, string(action))
+		return
+	}
+	active[schema] = true
+	defer delete(active, schema)
+
+	for _, s := range []*jsonschema.Schema{schema.Ref, schema.RecursiveRef, schema.DynamicRef, schema.Then, schema.Else} {
+		collectPii(s, path, active, add)
+	}
+	for _, group := range [][]*jsonschema.Schema{schema.AllOf, schema.AnyOf, schema.OneOf} {
+		for _, s := range group {
+			collectPii(s, path, active, add)
+		}
+	}
+	names := make([]string, 0, len(schema.Properties))
+	for name := range schema.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		collectPii(schema.Properties[name], path+"/"+globToken(name), active, add)
+	}
+	for _, s := range schema.PatternProperties {
+		collectPii(s, path+"/*", active, add)
+	}
+	if s, ok := schema.AdditionalProperties.(*jsonschema.Schema); ok {
+		collectPii(s, path+"/*", active, add)
+	}
+	switch items := schema.Items.(type) {
+	case *jsonschema.Schema:
+		collectPii(items, path+"/*", active, add)
+	case []*jsonschema.Schema:
+		for i, s := range items {
+			collectPii(s, path+"/"+strconv.Itoa(i), active, add)
+		}
+	}
+	for i, s := range schema.PrefixItems {
+		collectPii(s, path+"/"+strconv.Itoa(i), active, add)
+	}
+	collectPii(schema.Items2020, path+"/*", active, add)
+	if s, ok := schema.AdditionalItems.(*jsonschema.Schema); ok {
+		collectPii(s, path+"/*", active, add)
+	}
+}
+
+var globReplacer = strings.NewReplacer("~", "~0", "/", "~1", `\`, `\\`, "*", `\*`, "?", `\?`, "[", `\[`)
+
+// globToken escapes a property name as a JSON Pointer token matched literally.
+func globToken(name string) string {
+	return globReplacer.Replace(name)
+}
+
+// redact removes the personal data the library quotes in the messages of failure leaves.
+func (o *Validator) redact(failure *ValidationFailure) {
+	if failure == nil {
+		return
+	}
+	for _, leaf := range failure.Leaves() {
+		keyword := leaf.KeywordLocation[strings.LastIndexByte(leaf.KeywordLocation, '/')+1:]
+		re, ok := valueMessageRegexps[keyword]
+		if !ok {
+			continue
+		}
+		m := re.FindStringSubmatchIndex(leaf.Message)
+		if m == nil {
+			continue
+		}
+		start, end := m[2], m[3]
+		if end-start >= 2 && leaf.Message[start] == '\'' && leaf.Message[end-1] == '\'' {
+			start, end = start+1, end-1
+		}
+		text := leaf.Message[start:end]
+		value, keep := o.sanitizer.SanitizeAt(pointerTokens(leaf.InstanceLocation), text)
+		replacement := sanitizedValue
+		if keep {
+			replacement = fmt.Sprint(value)
+		}
+		leaf.Message = leaf.Message[:start] + replacement + leaf.Message[end:]
+	}
+}
@@ -0,0 +1,152 @@
+package server
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const piiTestSchema = `{
+	"$defs": {
+		"Address": {"type": "object", "properties": {"line": {"type": "string", "x-pii": "mask"}, "city": {"type": "string"}}},
+		"Node": {"type": "object", "properties": {"name": {"type": "string", "x-pii": "partial"}, "child": {"$ref": "#/$defs/Node"}}}
+	},
+	"type": "object",
+	"properties": {
+		"contact": {
+			"type": "object",
+			"properties": {
+				"email": {"type": "string", "format": "email", "x-pii": "mask"},
+				"home": {"$ref": "#/$defs/Address"}
+			}
+		},
+		"work": {"$ref": "#/$defs/Address"},
+		"members": {
+			"type": "array",
+			"items": {"type": "object", "properties": {"salary": {"type": "integer", "minimum": 200000, "x-pii": "partial"}}}
+		},
+		"age": {"type": "integer", "maximum": 120, "x-pii": "drop"},
+		"count": {"type": "integer", "maximum": 5},
+		"a*b": {"x-pii": "mask"},
+		"tree": {"$ref": "#/$defs/Node"}
+	}
+}`
+
+func piiValidator(t *testing.T) *Validator {
+	compiler := jsonschema.NewCompiler()
+	compiler.RegisterExtension(piiKeyword, piiMeta, piiCompiler{})
+	if err := compiler.AddResource("pii.json", strings.NewReader(piiTestSchema)); err != nil {
+		t.Fatal(err)
+	}
+	schema, err := compiler.Compile("pii.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Validator{schema: schema, sanitizer: newPiiSanitizer(schema, nil)}
+}
+
+func TestCollectPii(t *testing.T) {
+	validator := piiValidator(t)
+	got := make([]string, 0)
+	collectPii(validator.schema, "", make(map[*jsonschema.Schema]bool), func(path string, action string) {
+		got = append(got, path+" "+action)
+	})
+	sort.Strings(got)
+	want := []string{
+		`/a\*b mask`,
+		"/age drop",
+		"/contact/email mask",
+		"/contact/home/line mask",
+		"/members/*/salary partial",
+		"/tree/name partial",
+		"/work/line mask",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestPiiSanitizer(t *testing.T) {
+	validator := piiValidator(t)
+	doc, err := validator.Decode("application/json", []byte(`{
+		"contact": {"email": "jane@example.com", "home": {"line": "1 Main St", "city": "Springfield"}},
+		"members": [{"salary": 123456}, {"salary": 98765}],
+		"age": 40,
+		"a*b": "x", "axb": "y",
+		"tree": {"name": "Jane Doe"},
+		"password": "secret"
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := validator.Sanitizer().Sanitize(doc.Value)
+	want := map[string]interface{}{
+		"contact":  map[string]interface{}{"email": "**********", "home": map[string]interface{}{"line": "**********", "city": "Springfield"}},
+		"members":  []interface{}{map[string]interface{}{"salary": "**3456"}, map[string]interface{}{"salary": "*8765"}},
+		"a*b":      "**********",
+		"axb":      "y",
+		"tree":     map[string]interface{}{"name": "**** Doe"},
+		"password": "**********",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestValidateRedactsEchoedValues(t *testing.T) {
+	validator := piiValidator(t)
+	body := `{"contact": {"email": "jane.doe"}, "members": [{"salary": 123456}], "age": 130, "count": 9}`
+	doc, err := validator.Decode("application/json", []byte(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buffered := validator.Validate(doc, false)
+	streamed, err := validator.ValidateStream(bufio.NewReader(strings.NewReader(body)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"/contact/email":    "'**********' is not valid 'email'",
+		"/members/0/salary": "must be >= 200000 but found **3456",
+		"/age":              "must be <= 120 but found **********",
+		"/count":            "must be <= 5 but found 9",
+	}
+	for name, failure := range map[string]*ValidationFailure{"buffered": buffered, "streamed": streamed} {
+		got := make(map[string]string)
+		for _, leaf := range failure.Leaves() {
+			got[leaf.InstanceLocation] = leaf.Message
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestPiiHashNeedsKey(t *testing.T) {
+	validator := NewValidator()
+	fein := make([]string, 0)
+	collectPii(validator.schema, "", make(map[*jsonschema.Schema]bool), func(path string, action string) {
+		if strings.HasSuffix(path, "/federalEmployerIdentificationNumber") && action == "hash" {
+			fein = pointerTokens(strings.ReplaceAll(path, "*", "0"))
+		}
+	})
+	if len(fein) == 0 {
+		t.Fatal("no federalEmployerIdentificationNumber to hash in the schema")
+	}
+	if value, keep := validator.Sanitizer().SanitizeAt(fein, "12-3456789"); keep {
+		t.Errorf("hashed without a key: %v", value)
+	}
+	validator.SetPiiHashKey([]byte("key"))
+	mac := hmac.New(sha256.New, []byte("key"))
+	mac.Write([]byte("12-3456789"))
+	want := "hmac-sha256:" + hex.EncodeToString(mac.Sum(nil)[:16])
+	if value, keep := validator.Sanitizer().SanitizeAt(fein, "12-3456789"); !keep || value != want {
+		t.Errorf("hashed with a key: %v, want %s", value, want)
+	}
+}
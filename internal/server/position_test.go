@@ -0,0 +1,76 @@
+package server
+
+import (
+	"testing"
+)
+
+func TestValidationFailurePosition(t *testing.T) {
+	schema := compileTestSchema(t, `{"properties": {"count": {"type": "integer", "maximum": 5}}}`)
+	validator := &Validator{schema: schema, sanitizer: newPiiSanitizer(schema, nil)}
+	tests := []struct {
+		name        string
+		contentType string
+		body        string
+		want        Position
+	}{
+		{"json", "application/json", "{\"count\": 9}", Position{Line: 1, Column: 2, Offset: 1}},
+		{"json multibyte and crlf", "application/json", "{\"name\": \"x\",\r\n\"ñ\": \"ñandú\", \"count\": 9}", Position{Line: 2, Column: 15, Offset: 32}},
+		{"yaml", "application/yaml", "name: x\ncount: 9\n", Position{Line: 2, Column: 1, Offset: 8}},
+		{"yaml multibyte and crlf", "application/yaml", "# ñ\r\n{ñ: ñandú, count: 9}\r\n", Position{Line: 2, Column: 12, Offset: 20}},
+		{"xml", "application/xml", "<doc>\n<count>9</count></doc>", Position{Line: 2, Column: 1, Offset: 6}},
+		{"xml multibyte and crlf", "application/xml", "<doc>\r\n<ñ>ñandú</ñ><count>9</count></doc>", Position{Line: 2, Column: 13, Offset: 23}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			doc, err := validator.Decode(test.contentType, []byte(test.body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			failure := validator.Validate(doc, false)
+			if failure == nil {
+				t.Fatal("accepted")
+			}
+			for _, leaf := range failure.Leaves() {
+				if leaf.InstanceLocation != "/count" {
+					continue
+				}
+				got := Position{Line: leaf.Line, Column: leaf.Column, Offset: leaf.Offset}
+				if got != test.want {
+					t.Errorf("position = %+v, want %+v", got, test.want)
+				}
+				return
+			}
+			t.Errorf("no failure at /count in %+v", failure.Leaves())
+		})
+	}
+}
+
+func TestSyntaxErrorPosition(t *testing.T) {
+	validator := &Validator{}
+	tests := []struct {
+		contentType string
+		body        string
+		line        int
+		column      int
+	}{
+		{"application/json", "{\"a\": 1} x", 1, 10},
+		{"application/json", "{\"ñ\": 1,\r\n\"b\": }", 2, 6},
+		{"application/json", "{\"a\": tru}", 1, 10},
+		{"application/json", "[1, 2,\n ñ]", 2, 2},
+		{"application/xml", "<doc>\r\n<ñ></b></doc>", 2, 8},
+	}
+	for _, test := range tests {
+		_, err := validator.Decode(test.contentType, []byte(test.body))
+		se, ok := err.(*SyntaxError)
+		if !ok {
+			t.Errorf("%q: err = %v, want a syntax error", test.body, err)
+			continue
+		}
+		if se.Line != test.line || se.Column != test.column {
+			t.Errorf("%q: at %d:%d, want %d:%d", test.body, se.Line, se.Column, test.line, test.column)
+		}
+		if p := newLineIndex([]byte(test.body)).position(se.Offset); p.Line != se.Line || p.Column != se.Column {
+			t.Errorf("%q: offset %d is at %d:%d, not %d:%d", test.body, se.Offset, p.Line, p.Column, se.Line, se.Column)
+		}
+	}
+}
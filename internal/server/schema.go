@@ -0,0 +1,35 @@
+package server
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/hex"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"json-schema-validation/lib/tkt"
+	"strings"
+)
+
+const schemaURL = "https://ecosystem.xyz.com/canonical/v2/transmission.schema.json"
+
+//go:embed schemas/schema_v2.json
+var schemaText string
+
+func compileSchema() *jsonschema.Schema {
+	compiler := jsonschema.NewCompiler()
+	compiler.ExtractAnnotations = true
+	compiler.RegisterExtension(piiKeyword, piiMeta, piiCompiler{})
+	tkt.CheckErr(compiler.AddResource(schemaURL, strings.NewReader(schemaText)))
+	schema, err := compiler.Compile(schemaURL)
+	tkt.CheckErr(err)
+	return schema
+}
+
+// schemaIdentity returns the version declared by the schema and the hash of its text.
+func schemaIdentity() (string, string) {
+	root := struct {
+		Version string `json:"version"`
+	}{}
+	tkt.Unmarshal([]byte(schemaText), &root)
+	h := sha256.Sum256([]byte(schemaText))
+	return root.Version, "sha256:" + hex.EncodeToString(h[:])
+}
@@ -0,0 +1,464 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"io"
+	"json-schema-validation/lib/tkt"
+	"strconv"
+	"strings"
+)
+
+type streamCheck struct {
+	schema  *jsonschema.Schema
+	keyword string
+}
+
+// streamNode is a schema applied to the object or array being streamed, reached from one of
+// the checks through $ref and the combinators. Keyword results are collected while the
+// members go by and combined the way the jsonschema library does once the container ends.
+type streamNode struct {
+	schema      *jsonschema.Schema
+	keyword     string
+	pointer     string
+	position    Position
+	typeError   *ValidationFailure
+	ref         *streamNode
+	allOf       []*streamNode
+	anyOf       []*streamNode
+	oneOf       []*streamNode
+	count       int
+	found       map[string]bool
+	unevaluated []string
+	causes      []*ValidationFailure
+}
+
+func (o *streamNode) failure(keywordPath string, format string, a ...interface{}) *ValidationFailure {
+	return &ValidationFailure{
+		KeywordLocation:         joinLocation(o.keyword, keywordPath),
+		AbsoluteKeywordLocation: joinLocation(o.schema.Location, keywordPath),
+		InstanceLocation:        o.pointer,
+		Message:                 fmt.Sprintf(format, a...),
+		Line:                    o.position.Line,
+		Column:                  o.position.Column,
+		Offset:                  o.position.Offset,
+	}
+}
+
+func (o *streamNode) result() *ValidationFailure {
+	if o.typeError != nil {
+		return o.typeError
+	}
+	s := o.schema
+	errors := make([]*ValidationFailure, 0)
+	if o.found != nil {
+		if s.MinProperties != -1 && o.count < s.MinProperties {
+			errors = append(errors, o.failure("minProperties", "minimum %d properties allowed, but found %d properties", s.MinProperties, o.count))
+		}
+		if s.MaxProperties != -1 && o.count > s.MaxProperties {
+			errors = append(errors, o.failure("maxProperties", "maximum %d properties allowed, but found %d properties", s.MaxProperties, o.count))
+		}
+		missing := make([]string, 0)
+		for _, name := range s.Required {
+			if !o.found[name] {
+				missing = append(missing, quote(name))
+			}
+		}
+		if len(missing) > 0 {
+			errors = append(errors, o.failure("required", "missing properties: %s", strings.Join(missing, ", ")))
+		}
+		errors = append(errors, o.causes...)
+		if len(o.unevaluated) > 0 {
+			errors = append(errors, o.failure("additionalProperties", "additionalProperties %s not allowed", strings.Join(o.unevaluated, ", ")))
+		}
+	} else {
+		if s.MinItems != -1 && o.count < s.MinItems {
+			errors = append(errors, o.failure("minItems", "minimum %d items required, but found %d items", s.MinItems, o.count))
+		}
+		if s.MaxItems != -1 && o.count > s.MaxItems {
+			errors = append(errors, o.failure("maxItems", "maximum %d items required, but found %d items", s.MaxItems, o.count))
+		}
+		errors = append(errors, o.causes...)
+	}
+	if o.ref != nil {
+		if err := o.ref.result(); err != nil {
+			errors = append(errors, o.failure("$ref", "doesn't validate with %s", quote(refLocation(s, o.ref.schema))).addCauses(err))
+		}
+	}
+	for i, n := range o.allOf {
+		if err := n.result(); err != nil {
+			path := "allOf/" + strconv.Itoa(i)
+			errors = append(errors, o.failure(path, "allOf failed").addCauses(err))
+		}
+	}
+	if len(o.anyOf) > 0 {
+		causes := make([]*ValidationFailure, 0)
+		for _, n := range o.anyOf {
+			if err := n.result(); err != nil {
+				causes = append(causes, err)
+			}
+		}
+		if len(causes) == len(o.anyOf) {
+			f := o.failure("anyOf", "anyOf failed")
+			f.Causes = causes
+			errors = append(errors, f)
+		}
+	}
+	if len(o.oneOf) > 0 {
+		matched := -1
+		causes := make([]*ValidationFailure, 0)
+		for i, n := range o.oneOf {
+			if err := n.result(); err != nil {
+				causes = append(causes, err)
+			} else if matched == -1 {
+				matched = i
+			} else {
+				errors = append(errors, o.failure("oneOf", "valid against schemas at indexes %d and %d", matched, i))
+				break
+			}
+		}
+		if matched == -1 {
+			f := o.failure("oneOf", "oneOf failed")
+			f.Causes = causes
+			errors = append(errors, f)
+		}
+	}
+	switch len(errors) {
+	case 0:
+		return nil
+	case 1:
+		return errors[0]
+	default:
+		f := o.failure("", "")
+		f.Causes = errors
+		return f
+	}
+}
+
+type streamPlan struct {
+	kind       string
+	pointer    string
+	position   Position
+	active     []*streamNode
+	streamable bool
+}
+
+func (o *streamPlan) expand(s *jsonschema.Schema, keyword string, path map[*jsonschema.Schema]bool) *streamNode {
+	n := &streamNode{schema: s, keyword: keyword, pointer: o.pointer, position: o.position}
+	if path[s] || !streamableSchema(s, o.kind) {
+		o.streamable = false
+		return n
+	}
+	if len(s.Types) > 0 && !tkt.InStringList(o.kind, s.Types) {
+		n.typeError = n.failure("type", "expected %s, but got %s", strings.Join(s.Types, " or "), o.kind)
+		return n
+	}
+	path[s] = true
+	defer delete(path, s)
+	o.active = append(o.active, n)
+	if o.kind == "object" {
+		n.found = make(map[string]bool)
+	}
+	if s.Ref != nil {
+		n.ref = o.expand(s.Ref, joinLocation(keyword, "$ref"), path)
+	}
+	for i, c := range s.AllOf {
+		n.allOf = append(n.allOf, o.expand(c, joinLocation(keyword, "allOf/"+strconv.Itoa(i)), path))
+	}
+	for i, c := range s.AnyOf {
+		n.anyOf = append(n.anyOf, o.expand(c, joinLocation(keyword, "anyOf/"+strconv.Itoa(i)), path))
+	}
+	for i, c := range s.OneOf {
+		n.oneOf = append(n.oneOf, o.expand(c, joinLocation(keyword, "oneOf/"+strconv.Itoa(i)), path))
+	}
+	return n
+}
+
+// streamableSchema tells whether every keyword of the schema can be evaluated while the
+// members of an object or array go by, without holding the whole value.
+func streamableSchema(s *jsonschema.Schema, kind string) bool {
+	if s.Always != nil || len(s.Enum) > 0 || len(s.Constant) > 0 || s.Not != nil || s.If != nil ||
+		s.RecursiveRef != nil || s.DynamicRef != nil || hasValidatingExtension(s) {
+		return false
+	}
+	if kind == "object" {
+		return s.PropertyNames == nil && !s.RegexProperties && len(s.Dependencies) == 0 &&
+			len(s.DependentRequired) == 0 && len(s.DependentSchemas) == 0 && s.UnevaluatedProperties == nil
+	}
+	_, legacyItems := s.Items.([]*jsonschema.Schema)
+	return !s.UniqueItems && s.Contains == nil && !legacyItems && s.AdditionalItems == nil && s.UnevaluatedItems == nil
+}
+
+// hasValidatingExtension tells whether the schema carries an extension keyword other than
+// the x-pii annotation, which never fails.
+func hasValidatingExtension(s *jsonschema.Schema) bool {
+	for name := range s.Extensions {
+		if name != piiKeyword {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckStream tells why a payload of the content type cannot be streamed, if it cannot:
+// streaming reads JSON only and validates the payload as it is, never normalized nor
+// given a transmissionGUID, so a lenient validator does not stream.
+func (o *Validator) CheckStream(contentType string, normalize bool) error {
+	format, err := resolveFormat(contentType)
+	if err != nil {
+		return err
+	}
+	if format != jsonFormat {
+		return &SyntaxError{Message: "only JSON can be streamed, not " + contentType}
+	}
+	if normalize {
+		return errors.New("a streamed payload cannot be normalized")
+	}
+	if o.guids != nil {
+		return errors.New("a streamed payload cannot be given a transmissionGUID in lenient mode")
+	}
+	return nil
+}
+
+type streamValidator struct {
+	*jsonDecoder
+}
+
+// ValidateStream validates a JSON body while it is being read. Objects and arrays are
+// walked member by member and only scalars, or subtrees whose schema needs the whole value
+// (enum, uniqueItems, not...), are materialized, so memory stays bounded by the nesting
+// depth and the largest such subtree rather than by the payload size.
+func (o *Validator) ValidateStream(r io.Reader) (*ValidationFailure, error) {
+	v := &streamValidator{jsonDecoder: newJsonDecoder(r)}
+	position := v.reader.position(v.nextOffset())
+	results, err := v.validate([]streamCheck{{schema: o.schema}}, "", &position)
+	if err != nil {
+		return nil, v.wrap(err)
+	}
+	if err := v.end(); err != nil {
+		return nil, err
+	}
+	if results[0] == nil {
+		return nil, nil
+	}
+	root := &ValidationFailure{
+		AbsoluteKeywordLocation: o.schema.Location,
+		Message:                 fmt.Sprintf("doesn't validate with %s", o.schema.Location),
+		Line:                    position.Line,
+		Column:                  position.Column,
+		Offset:                  position.Offset,
+	}
+	failure := root.addCauses(results[0])
+	o.redact(failure)
+	return failure, nil
+}
+
+func (o *streamValidator) validate(checks []streamCheck, pointer string, at *Position) ([]*ValidationFailure, error) {
+	position := o.reader.position(o.nextOffset())
+	if at != nil {
+		position = *at
+	}
+	token, err := o.decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	plan := &streamPlan{pointer: pointer, position: position, streamable: true}
+	switch token {
+	case json.Delim('{'):
+		plan.kind = "object"
+	case json.Delim('['):
+		plan.kind = "array"
+	default:
+		doc := &Document{Value: token, positions: map[string]Position{pointer: position}}
+		return validateChecks(checks, doc, pointer), nil
+	}
+	nodes := make([]*streamNode, len(checks))
+	for i, c := range checks {
+		nodes[i] = plan.expand(c.schema, c.keyword, make(map[*jsonschema.Schema]bool))
+	}
+	if !plan.streamable {
+		o.positions = map[string]Position{pointer: position}
+		value, err := o.decodeToken(token, pointer)
+		if err != nil {
+			return nil, err
+		}
+		return validateChecks(checks, &Document{Value: value, positions: o.positions}, pointer), nil
+	}
+	if plan.kind == "object" {
+		err = o.streamObject(plan.active, pointer)
+	} else {
+		err = o.streamArray(plan.active, pointer)
+	}
+	if err != nil {
+		return nil, err
+	}
+	results := make([]*ValidationFailure, len(nodes))
+	for i, n := range nodes {
+		results[i] = n.result()
+	}
+	return results, nil
+}
+
+func (o *streamValidator) streamObject(active []*streamNode, pointer string) error {
+	for o.decoder.More() {
+		position := o.reader.position(o.nextOffset())
+		token, err := o.decoder.Token()
+		if err != nil {
+			return err
+		}
+		key := token.(string)
+		checks := make([]streamCheck, 0)
+		owners := make([]*streamNode, 0)
+		for _, n := range active {
+			s := n.schema
+			n.count++
+			n.found[key] = true
+			evaluated := false
+			if p, ok := s.Properties[key]; ok {
+				checks = append(checks, streamCheck{p, joinLocation(n.keyword, "properties/"+escapeToken(key))})
+				owners = append(owners, n)
+				evaluated = true
+			}
+			for pattern, p := range s.PatternProperties {
+				if pattern.MatchString(key) {
+					checks = append(checks, streamCheck{p, joinLocation(n.keyword, "patternProperties/"+escapeToken(pattern.String()))})
+					owners = append(owners, n)
+					evaluated = true
+				}
+			}
+			if evaluated {
+				continue
+			}
+			switch additional := s.AdditionalProperties.(type) {
+			case bool:
+				if !additional {
+					n.unevaluated = append(n.unevaluated, quote(key))
+				}
+			case *jsonschema.Schema:
+				checks = append(checks, streamCheck{additional, joinLocation(n.keyword, "additionalProperties")})
+				owners = append(owners, n)
+			}
+		}
+		if err := o.streamMember(checks, owners, childPointer(pointer, key), &position); err != nil {
+			return err
+		}
+	}
+	_, err := o.decoder.Token()
+	return err
+}
+
+func (o *streamValidator) streamArray(active []*streamNode, pointer string) error {
+	for i := 0; o.decoder.More(); i++ {
+		checks := make([]streamCheck, 0)
+		owners := make([]*streamNode, 0)
+		for _, n := range active {
+			s := n.schema
+			n.count++
+			var items *jsonschema.Schema
+			path := "items"
+			if i < len(s.PrefixItems) {
+				items = s.PrefixItems[i]
+				path = "prefixItems/" + strconv.Itoa(i)
+			} else if s.Items2020 != nil {
+				items = s.Items2020
+			} else if legacy, ok := s.Items.(*jsonschema.Schema); ok {
+				items = legacy
+			}
+			if items != nil {
+				checks = append(checks, streamCheck{items, joinLocation(n.keyword, path)})
+				owners = append(owners, n)
+			}
+		}
+		if err := o.streamMember(checks, owners, indexPointer(pointer, i), nil); err != nil {
+			return err
+		}
+	}
+	_, err := o.decoder.Token()
+	return err
+}
+
+func (o *streamValidator) streamMember(checks []streamCheck, owners []*streamNode, pointer string, at *Position) error {
+	if len(checks) == 0 {
+		return o.skipValue()
+	}
+	results, err := o.validate(checks, pointer, at)
+	if err != nil {
+		return err
+	}
+	for i, r := range results {
+		if r != nil {
+			owners[i].causes = append(owners[i].causes, r)
+		}
+	}
+	return nil
+}
+
+// validateChecks runs the jsonschema library on a materialized value and rebases the
+// resulting errors onto the keyword and instance locations of the enclosing document.
+func validateChecks(checks []streamCheck, doc *Document, pointer string) []*ValidationFailure {
+	results := make([]*ValidationFailure, len(checks))
+	for i, c := range checks {
+		err := c.schema.Validate(doc.Value)
+		if err == nil {
+			continue
+		}
+		ve, ok := err.(*jsonschema.ValidationError)
+		if !ok {
+			results[i] = &ValidationFailure{KeywordLocation: c.keyword, AbsoluteKeywordLocation: c.schema.Location,
+				InstanceLocation: pointer, Message: err.Error()}
+			continue
+		}
+		if len(ve.Causes) == 1 {
+			results[i] = rebaseFailure(ve.Causes[0], c.keyword, pointer, doc)
+			continue
+		}
+		f := rebaseFailure(ve, c.keyword, pointer, doc)
+		f.Message = ""
+		results[i] = f
+	}
+	return results
+}
+
+func rebaseFailure(err *jsonschema.ValidationError, keyword string, pointer string, doc *Document) *ValidationFailure {
+	failure := &ValidationFailure{
+		KeywordLocation:         keyword + err.KeywordLocation,
+		AbsoluteKeywordLocation: err.AbsoluteKeywordLocation,
+		InstanceLocation:        pointer + err.InstanceLocation,
+		Message:                 err.Message,
+	}
+	if p, ok := doc.Position(failure.InstanceLocation); ok {
+		failure.Line = p.Line
+		failure.Column = p.Column
+		failure.Offset = p.Offset
+	}
+	for _, c := range err.Causes {
+		failure.Causes = append(failure.Causes, rebaseFailure(c, keyword, pointer, doc))
+	}
+	return failure
+}
+
+func joinLocation(base string, path string) string {
+	if path == "" {
+		return base
+	}
+	return base + "/" + path
+}
+
+func refLocation(s *jsonschema.Schema, ref *jsonschema.Schema) string {
+	i := strings.IndexByte(s.Location, '#')
+	j := strings.IndexByte(ref.Location, '#')
+	if i >= 0 && j >= 0 && s.Location[:i] == ref.Location[:j] {
+		return ref.Location[j+1:]
+	}
+	return ref.Location
+}
+
+// quote formats a name the way the jsonschema library does in its messages.
+func quote(s string) string {
+	s = fmt.Sprintf("%q", s)
+	s = strings.ReplaceAll(s, `\"`, `"`)
+	s = strings.ReplaceAll(s, `'`, `\'`)
+	return "'" + s[1:len(s)-1] + "'"
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+const streamSchema = `{
+	"$defs": {
+		"Rate": {
+			"type": "object",
+			"required": ["tier", "rate"],
+			"properties": {
+				"tier": {"enum": ["EE", "ES", "FAM"]},
+				"rate": {"type": "number", "minimum": 0}
+			},
+			"additionalProperties": false
+		}
+	},
+	"type": "object",
+	"required": ["name"],
+	"properties": {
+		"name": {"type": "string", "maxLength": 10},
+		"rates": {"type": "array", "minItems": 1, "items": {"$ref": "#/$defs/Rate"}},
+		"contact": {
+			"anyOf": [
+				{"type": "object", "required": ["email"]},
+				{"type": "object", "required": ["phone"]}
+			]
+		},
+		"kind": {"oneOf": [{"type": "string"}, {"type": "integer"}]},
+		"tags": {"type": "array", "uniqueItems": true}
+	}
+}`
+
+func leafSummary(failure *ValidationFailure) []string {
+	if failure == nil {
+		return nil
+	}
+	result := make([]string, 0)
+	for _, leaf := range failure.Leaves() {
+		result = append(result, fmt.Sprintf("%s %s %s %d:%d", leaf.InstanceLocation, leaf.KeywordLocation, leaf.Message, leaf.Line, leaf.Column))
+	}
+	sort.Strings(result)
+	return result
+}
+
+func TestValidateStreamAgreesWithValidate(t *testing.T) {
+	schema := compileTestSchema(t, streamSchema)
+	validator := &Validator{schema: schema, sanitizer: newPiiSanitizer(schema, nil)}
+	for _, body := range []string{
+		`{"name": "group", "rates": [{"tier": "EE", "rate": 1.5}], "contact": {"email": "a"}, "kind": 1}`,
+		`{}`,
+		`{"name": "a name that is too long"}`,
+		`{"name": "g", "rates": []}`,
+		"{\"name\": \"g\",\n \"rates\": [{\"tier\": \"EE\", \"rate\": 1}, {\"tier\": \"XX\", \"rate\": -1, \"extra\": true}]}",
+		`{"name": "g", "rates": [{"rate": 1}]}`,
+		`{"name": "g", "contact": {"fax": "1"}}`,
+		`{"name": "g", "kind": 1.5}`,
+		`{"name": "g", "tags": ["a", "b", "a"]}`,
+		`[1, 2]`,
+		`"text"`,
+	} {
+		doc, err := validator.Decode("application/json", []byte(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		buffered := leafSummary(validator.Validate(doc, false))
+		failure, err := validator.ValidateStream(bufio.NewReader(strings.NewReader(body)))
+		if err != nil {
+			t.Fatalf("%s: %v", body, err)
+		}
+		if streamed := leafSummary(failure); !reflect.DeepEqual(buffered, streamed) {
+			t.Errorf("%s:\nbuffered %q\nstreamed %q", body, buffered, streamed)
+		}
+	}
+}
+
+func TestValidateStreamSyntaxError(t *testing.T) {
+	schema := compileTestSchema(t, streamSchema)
+	validator := &Validator{schema: schema, sanitizer: newPiiSanitizer(schema, nil)}
+	for _, body := range []string{`{"name": `, `{"name": "g"} x`, `{"name" "g"}`, ``} {
+		_, err := validator.ValidateStream(bufio.NewReader(strings.NewReader(body)))
+		if _, ok := err.(*SyntaxError); !ok {
+			t.Errorf("%q: err = %v, want a syntax error", body, err)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"runtime"
+	"runtime/metrics"
+	"testing"
+	"time"
+)
+
+// ratesPayload returns a GroupPolicy transmission whose rate schedule has n rates.
+func ratesPayload(n int) []byte {
+	b := bytes.Buffer{}
+	b.WriteString(`{"transmissionGUID":"g","data":{"status":"active","employer":{"name":"e"},"groupPolicyConfiguration":{"coverages":[{"benefitPlans":[{"rateSchedule":[{"rates":[`)
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `{"ageBandLower":%d,"ageBandUpper":%d,"coverageTierCode":"employee","gender":"male","isTobaccoRated":false,"rate":12.5,"unit":"USD","ratingPeriod":"current"}`, i%100, i%100+4)
+	}
+	b.WriteString(`]}]}]}]}}}`)
+	return b.Bytes()
+}
+
+func TestValidateStreamMatchesBuffered(t *testing.T) {
+	validator := NewValidator()
+	for _, c := range []struct {
+		payload []byte
+		valid   bool
+	}{
+		{ratesPayload(10), true},
+		{[]byte(`{"data": {"status": "active", "employer": {"name": 1}}}`), false},
+	} {
+		doc, err := validator.Decode("application/json", c.payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buffered := validator.Validate(doc, false)
+		streamed, err := validator.ValidateStream(bufio.NewReader(bytes.NewReader(c.payload)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (buffered == nil) != c.valid || (streamed == nil) != c.valid {
+			t.Errorf("%.40s: buffered %v, streamed %v, want valid %t", c.payload, buffered, streamed, c.valid)
+		} else if !c.valid && len(buffered.Leaves()) != len(streamed.Leaves()) {
+			t.Errorf("buffered %d errors, streamed %d", len(buffered.Leaves()), len(streamed.Leaves()))
+		}
+	}
+}
+
+// peakHeap runs f while sampling the live heap, and reports the highest sample above the
+// heap before f as peak-heap-MB.
+func peakHeap(b *testing.B, f func()) {
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	runtime.GC()
+	metrics.Read(sample)
+	base, peak := sample[0].Value.Uint64(), uint64(0)
+	done := make(chan struct{})
+	sampled := make(chan struct{})
+	go func() {
+		defer close(sampled)
+		s := []metrics.Sample{{Name: sample[0].Name}}
+		for {
+			metrics.Read(s)
+			if v := s[0].Value.Uint64(); v > peak {
+				peak = v
+			}
+			select {
+			case <-done:
+				return
+			case <-time.After(time.Millisecond):
+			}
+		}
+	}()
+	f()
+	close(done)
+	<-sampled
+	if peak < base {
+		peak = base
+	}
+	b.ReportMetric(float64(peak-base)/(1<<20), "peak-heap-MB")
+}
+
+// The two benchmarks validate the same payload: B/op compares the bytes allocated and
+// peak-heap-MB the memory held at once, which the streaming path keeps small.
+func BenchmarkValidateBuffered(b *testing.B) {
+	validator := NewValidator()
+	payload := ratesPayload(20000)
+	b.SetBytes(int64(len(payload)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	peakHeap(b, func() {
+		for i := 0; i < b.N; i++ {
+			doc, err := validator.Decode("application/json", payload)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if failure := validator.Validate(doc, false); failure != nil {
+				b.Fatal(failure.Message)
+			}
+		}
+	})
+}
+
+func BenchmarkValidateStreamed(b *testing.B) {
+	validator := NewValidator()
+	payload := ratesPayload(20000)
+	b.SetBytes(int64(len(payload)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	peakHeap(b, func() {
+		for i := 0; i < b.N; i++ {
+			failure, err := validator.ValidateStream(bufio.NewReader(bytes.NewReader(payload)))
+			if err != nil {
+				b.Fatal(err)
+			}
+			if failure != nil {
+				b.Fatal(failure.Message)
+			}
+		}
+	})
+}
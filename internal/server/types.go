@@ -7,3 +7,46 @@ type PayloadValidationRequest struct {
 func NewPayloadValidationRequest() *PayloadValidationRequest {
 	return &PayloadValidationRequest{}
 }
+
+type Position struct {
+	Line   int
+	Column int
+	Offset int64
+}
+
+type ValidationFailure struct {
+	KeywordLocation         string
+	AbsoluteKeywordLocation string
+	InstanceLocation        string
+	Message                 string
+	Line                    int           `json:",omitempty"`
+	Column                  int           `json:",omitempty"`
+	Offset                  int64         `json:",omitempty"`
+	Title                   string        `json:",omitempty"`
+	Description             string        `json:",omitempty"`
+	Explanation             string        `json:",omitempty"`
+	Allowed                 []interface{} `json:",omitempty"`
+	Example                 string        `json:",omitempty"`
+	Suggestion              string        `json:",omitempty"`
+	Causes                  []*ValidationFailure
+}
+
+func (o *ValidationFailure) addCauses(err *ValidationFailure) *ValidationFailure {
+	if err.Message == "" {
+		o.Causes = append(o.Causes, err.Causes...)
+	} else {
+		o.Causes = append(o.Causes, err)
+	}
+	return o
+}
+
+func (o *ValidationFailure) Leaves() []*ValidationFailure {
+	if len(o.Causes) == 0 {
+		return []*ValidationFailure{o}
+	}
+	leaves := make([]*ValidationFailure, 0)
+	for _, c := range o.Causes {
+		leaves = append(leaves, c.Leaves()...)
+	}
+	return leaves
+}
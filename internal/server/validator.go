@@ -0,0 +1,119 @@
+package server
+
+import (
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"json-schema-validation/lib/tkt"
+	"strings"
+)
+
+type Document struct {
+	Value     interface{}
+	positions map[string]Position
+}
+
+// Position returns the source position of the value at pointer, falling back to the
+// closest enclosing value when the pointer itself was not seen in the source
+// (a missing property, a filled in default).
+func (o *Document) Position(pointer string) (Position, bool) {
+	for {
+		if p, ok := o.positions[pointer]; ok {
+			return p, true
+		}
+		i := strings.LastIndexByte(pointer, '/')
+		if i < 0 {
+			return Position{}, false
+		}
+		pointer = pointer[:i]
+	}
+}
+
+type Validator struct {
+	schema        *jsonschema.Schema
+	schemaVersion string
+	schemaHash    string
+	sanitizer     *tkt.Sanitizer
+	guids         tkt.IdGenerator
+}
+
+func (o *Validator) Schema() *jsonschema.Schema {
+	return o.schema
+}
+
+func (o *Validator) SchemaVersion() string {
+	return o.schemaVersion
+}
+
+func (o *Validator) SchemaHash() string {
+	return o.schemaHash
+}
+
+// Sanitizer redacts payloads as the schema asks with x-pii, for logs and audits.
+func (o *Validator) Sanitizer() *tkt.Sanitizer {
+	return o.sanitizer
+}
+
+// SetPiiHashKey sets the HMAC key of the values the schema marks with x-pii hash. Without
+// a key, the default, these values are dropped.
+func (o *Validator) SetPiiHashKey(key []byte) {
+	o.sanitizer = newPiiSanitizer(o.schema, key)
+}
+
+// SetLenient has AssignTransmissionGUID give the payloads that omit transmissionGUID one
+// made by the generator. A nil generator turns it off.
+func (o *Validator) SetLenient(guids tkt.IdGenerator) {
+	o.guids = guids
+}
+
+// AssignTransmissionGUID sets a new transmissionGUID on a payload that has none, or a null
+// one, when the validator is lenient, and returns it.
+func (o *Validator) AssignTransmissionGUID(doc *Document) (string, bool) {
+	m, ok := doc.Value.(map[string]interface{})
+	if o.guids == nil || !ok || m["transmissionGUID"] != nil {
+		return "", false
+	}
+	guid := o.guids.NewId()
+	m["transmissionGUID"] = guid
+	return guid, true
+}
+
+// Validate validates the document as it was sent. Only an accepted document is normalized,
+// so that normalizing never changes the verdict.
+func (o *Validator) Validate(doc *Document, normalize bool) *ValidationFailure {
+	err := o.schema.Validate(doc.Value)
+	if err == nil {
+		if normalize {
+			doc.Value = Normalize(o.schema, doc.Value)
+		}
+		return nil
+	}
+	if ve, ok := err.(*jsonschema.ValidationError); ok {
+		failure := newValidationFailure(ve, doc)
+		o.redact(failure)
+		return failure
+	}
+	return &ValidationFailure{AbsoluteKeywordLocation: o.schema.Location, Message: err.Error()}
+}
+
+func newValidationFailure(err *jsonschema.ValidationError, doc *Document) *ValidationFailure {
+	failure := &ValidationFailure{
+		KeywordLocation:         err.KeywordLocation,
+		AbsoluteKeywordLocation: err.AbsoluteKeywordLocation,
+		InstanceLocation:        err.InstanceLocation,
+		Message:                 err.Message,
+	}
+	if p, ok := doc.Position(err.InstanceLocation); ok {
+		failure.Line = p.Line
+		failure.Column = p.Column
+		failure.Offset = p.Offset
+	}
+	for _, c := range err.Causes {
+		failure.Causes = append(failure.Causes, newValidationFailure(c, doc))
+	}
+	return failure
+}
+
+func NewValidator() *Validator {
+	version, hash := schemaIdentity()
+	schema := compileSchema()
+	return &Validator{schema: schema, schemaVersion: version, schemaHash: hash, sanitizer: newPiiSanitizer(schema, nil)}
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"github.com/santhosh-tekuri/jsonschema/v5"
+	"strings"
+	"testing"
+)
+
+func testValidator(t *testing.T, schemaText string) *Validator {
+	compiler := jsonschema.NewCompiler()
+	if err := compiler.AddResource("test.json", strings.NewReader(schemaText)); err != nil {
+		t.Fatal(err)
+	}
+	schema, err := compiler.Compile("test.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &Validator{schema: schema}
+}
+
+func TestValidateNormalizeKeepsVerdict(t *testing.T) {
+	validator := testValidator(t, `{"properties": {"gender": {"enum": ["male", "female"]}}}`)
+	for _, value := range []string{"MALE", " male"} {
+		if validator.Validate(&Document{Value: map[string]interface{}{"gender": value}}, false) == nil {
+			t.Fatalf("%q accepted without normalization", value)
+		}
+		if validator.Validate(&Document{Value: map[string]interface{}{"gender": value}}, true) == nil {
+			t.Errorf("%q rejected without normalization but accepted with it", value)
+		}
+	}
+}
+
+func TestValidateNormalizesAcceptedDocument(t *testing.T) {
+	validator := NewValidator()
+	doc := &Document{Value: map[string]interface{}{"senderName": "  sender  "}}
+	if failure := validator.Validate(doc, true); failure != nil {
+		t.Fatalf("rejected: %s", failure.Message)
+	}
+	if v := doc.Value.(map[string]interface{})["senderName"]; v != "sender" {
+		t.Errorf("senderName = %q, want it trimmed", v)
+	}
+}
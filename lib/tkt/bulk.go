@@ -0,0 +1,202 @@
+package tkt
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
+)
+
+// bulkRows is the most rows a multi-row insert carries. Bigger statements barely cut the
+// round trips further and cost more to bind, quadratically so with modernc.org/sqlite.
+const bulkRows = 50
+
+// copyDriver is the driver whose statements starting with COPY stream rows, lib/pq.
+const copyDriver = "postgres"
+
+func (o *TxCtx) InsertEntities(schema string, entities interface{}, autoId bool) int64 {
+	count, err := o.InsertEntitiesContext(o.ctx, schema, entities, autoId)
+	CheckErr(err)
+	return count
+}
+
+// InsertEntitiesContext inserts a slice of entities of the same struct type and returns the
+// number of rows inserted. Postgres through lib/pq streams them with COPY, other databases
+// get multi-row inserts of up to bulkRows rows. With autoId the first field is left for the
+// database to generate, as in InsertEntity.
+func (o *TxCtx) InsertEntitiesContext(ctx context.Context, schema string, entities interface{}, autoId bool) (int64, error) {
+	rows := reflect.ValueOf(entities)
+	if rows.Kind() != reflect.Slice {
+		return 0, errors.New("InsertEntities expects a slice, not " + rows.Type().String())
+	}
+	if rows.Len() == 0 {
+		return 0, nil
+	}
+	offset := 0
+	if autoId {
+		offset = 1
+	}
+	objectType := rows.Type().Elem()
+	width := len(mappingOf(objectType).columns) - offset
+	if width <= 0 {
+		return 0, errors.New("InsertEntities has no columns to insert for " + objectType.String())
+	}
+	table := o.tableName(schema, objectType.Name())
+	columns := forSelect(o.dialect, objectType, nil, offset)
+	if *o.databaseConfig.DatabaseDriver == copyDriver {
+		return o.copyEntities(ctx, table, columns, rows, offset)
+	}
+	return o.insertValues(ctx, table, columns, rows, offset, width)
+}
+
+// insertValues inserts the rows with multi-row inserts of up to bulkRows rows of width
+// values, fewer when the dialect limits the parameters of a statement.
+func (o *TxCtx) insertValues(ctx context.Context, table string, columns string, rows reflect.Value, offset int, width int) (int64, error) {
+	batch := bulkRows
+	if batch*width > o.dialect.MaxParameters() {
+		batch = o.dialect.MaxParameters() / width
+	}
+	var stmt *sql.Stmt
+	defer func() {
+		if stmt != nil {
+			stmt.Close()
+		}
+	}()
+	count := int64(0)
+	for start := 0; start < rows.Len(); start += batch {
+		end := start + batch
+		if end > rows.Len() {
+			end = rows.Len()
+		}
+		if stmt == nil || end-start < batch {
+			if stmt != nil {
+				stmt.Close()
+			}
+			var err error
+			stmt, err = o.tx.PrepareContext(ctx, "insert into "+table+" ("+columns+") values "+o.valuesList(end-start, width))
+			if err != nil {
+				return count, err
+			}
+		}
+		args := make([]interface{}, 0, (end-start)*width)
+		for i := start; i < end; i++ {
+			row, err := structArgs(rows.Index(i).Interface(), offset)
+			if err != nil {
+				return count, err
+			}
+			args = append(args, row...)
+		}
+		r, err := stmt.ExecContext(ctx, args...)
+		if err != nil {
+			return count, err
+		}
+		n, _ := r.RowsAffected()
+		count += n
+	}
+	return count, nil
+}
+
+// valuesList returns the placeholders of a multi-row insert of rows rows of width values.
+func (o *TxCtx) valuesList(rows int, width int) string {
+	buffer := bytes.NewBufferString("")
+	n := 0
+	for i := 0; i < rows; i++ {
+		if i > 0 {
+			buffer.WriteString(", ")
+		}
+		buffer.WriteString("(")
+		for j := 0; j < width; j++ {
+			if j > 0 {
+				buffer.WriteString(", ")
+			}
+			n++
+			buffer.WriteString(o.dialect.Placeholder(n))
+		}
+		buffer.WriteString(")")
+	}
+	return buffer.String()
+}
+
+func (o *TxCtx) copyEntities(ctx context.Context, table string, columns string, rows reflect.Value, offset int) (int64, error) {
+	stmt, err := o.tx.PrepareContext(ctx, "copy "+table+" ("+columns+") from stdin")
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+	for i := 0; i < rows.Len(); i++ {
+		row, err := structArgs(rows.Index(i).Interface(), offset)
+		if err != nil {
+			return 0, err
+		}
+		if _, err := stmt.ExecContext(ctx, row...); err != nil {
+			return 0, err
+		}
+	}
+	r, err := stmt.ExecContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	return r.RowsAffected()
+}
+
+func (o *TxCtx) UpsertEntity(schema string, entity interface{}, keys ...string) int64 {
+	count, err := o.UpsertEntityContext(o.ctx, schema, entity, keys...)
+	CheckErr(err)
+	return count
+}
+
+// UpsertEntityContext inserts the entity or, when a row with the same key columns exists,
+// updates its other columns, and returns the number of rows affected. The key defaults to
+// the id column.
+func (o *TxCtx) UpsertEntityContext(ctx context.Context, schema string, entity interface{}, keys ...string) (int64, error) {
+	objectType := reflect.TypeOf(entity)
+	mapping := mappingOf(objectType)
+	if len(keys) == 0 {
+		id, err := mapping.idColumn()
+		if err != nil {
+			return 0, err
+		}
+		keys = []string{mapping.columns[id].name}
+	}
+	key := schema + "." + objectType.Name() + "(" + strings.Join(keys, ",") + ")"
+	stmt, ok := o.upsMap[key]
+	if !ok {
+		isKey := make(map[string]bool)
+		quotedKeys := make([]string, len(keys))
+		for i, k := range keys {
+			quotedKeys[i] = quoteFolded(o.dialect, k)
+			isKey[quotedKeys[i]] = true
+		}
+		updates := make([]string, 0)
+		for _, name := range mapping.columnNames(0) {
+			column := quoteFolded(o.dialect, name)
+			if !isKey[column] {
+				updates = append(updates, column+" = excluded."+column)
+			}
+		}
+		sentence := "insert into " + o.tableName(schema, objectType.Name()) + ForInsertDialect(o.dialect, entity, 0) +
+			" on conflict (" + strings.Join(quotedKeys, ", ") + ")"
+		if len(updates) == 0 {
+			sentence += " do nothing"
+		} else {
+			sentence += " do update set " + strings.Join(updates, ", ")
+		}
+		var err error
+		stmt, err = o.tx.PrepareContext(ctx, sentence)
+		if err != nil {
+			return 0, err
+		}
+		o.upsMap[key] = stmt
+	}
+	args, err := structArgs(entity, 0)
+	if err != nil {
+		return 0, err
+	}
+	r, err := stmt.ExecContext(ctx, args...)
+	if err != nil {
+		return 0, err
+	}
+	return r.RowsAffected()
+}
@@ -0,0 +1,139 @@
+package tkt
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// postgresDatasourceEnv names a Postgres database the benchmarks may create tables in;
+// the COPY benchmarks are skipped without it.
+const postgresDatasourceEnv = "TKT_POSTGRES_DATASOURCE"
+
+type BulkRate struct {
+	Id     int64
+	Tier   string
+	MinAge int
+	MaxAge int
+	Amount string
+}
+
+type NoColumns struct {
+	hidden int
+}
+
+type OnlyId struct {
+	Id int64
+}
+
+func TestInsertEntitiesWithoutColumns(t *testing.T) {
+	defer ClosePools()
+	config := sqliteConfig(t, "bulk.db", 2)
+	_, err := ExecuteTransactionalContext(context.Background(), config, func(txCtx *TxCtx) (interface{}, error) {
+		if _, err := txCtx.InsertEntitiesContext(txCtx.Context(), "", []NoColumns{{}}, false); err == nil ||
+			!strings.Contains(err.Error(), "no columns") {
+			t.Errorf("no columns: %v", err)
+		}
+		if _, err := txCtx.InsertEntitiesContext(txCtx.Context(), "", []OnlyId{{}}, true); err == nil ||
+			!strings.Contains(err.Error(), "no columns") {
+			t.Errorf("only the generated id: %v", err)
+		}
+		return nil, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func bulkRates(n int) []BulkRate {
+	rates := make([]BulkRate, n)
+	for i := range rates {
+		rates[i] = BulkRate{Id: int64(i + 1), Tier: "EE", MinAge: i % 60, MaxAge: i%60 + 4, Amount: fmt.Sprintf("%d.25", i)}
+	}
+	return rates
+}
+
+// benchmarkInsert inserts rows with insert, in a table truncated before each run.
+func benchmarkInsert(b *testing.B, config DatabaseConfig, rows int, insert func(txCtx *TxCtx, rates reflect.Value) (int64, error)) {
+	defer ClosePools()
+	rates := reflect.ValueOf(bulkRates(rows))
+	ExecuteTransactional(config, func(txCtx *TxCtx, args ...interface{}) interface{} {
+		txCtx.ExecSql("drop table if exists BulkRate")
+		txCtx.ExecSql("create table BulkRate (Id bigint primary key, Tier text, MinAge integer, MaxAge integer, Amount text)")
+		return nil
+	})
+	b.ReportAllocs()
+	b.ResetTimer()
+	start := time.Now()
+	for i := 0; i < b.N; i++ {
+		_, err := ExecuteTransactionalContext(context.Background(), config, func(txCtx *TxCtx) (interface{}, error) {
+			if _, err := txCtx.ExecSqlContext(txCtx.Context(), "delete from BulkRate"); err != nil {
+				return nil, err
+			}
+			n, err := insert(txCtx, rates)
+			if err == nil && n != int64(rows) {
+				err = fmt.Errorf("%d rows inserted, want %d", n, rows)
+			}
+			return nil, err
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ReportMetric(float64(time.Since(start).Nanoseconds())/float64(b.N*rows), "ns/row")
+}
+
+// insertLoop is the baseline, inserting the entities one by one.
+func insertLoop(txCtx *TxCtx, rates reflect.Value) (int64, error) {
+	for i := 0; i < rates.Len(); i++ {
+		if _, err := txCtx.InsertEntityContext(txCtx.Context(), "", rates.Index(i).Interface(), false); err != nil {
+			return int64(i), err
+		}
+	}
+	return int64(rates.Len()), nil
+}
+
+func insertValues(txCtx *TxCtx, rates reflect.Value) (int64, error) {
+	dialect := txCtx.Dialect()
+	return txCtx.insertValues(txCtx.Context(), quoteFolded(dialect, "BulkRate"), forSelect(dialect, rates.Type().Elem(), nil, 0),
+		rates, 0, len(mappingOf(rates.Type().Elem()).columns))
+}
+
+func insertCopy(txCtx *TxCtx, rates reflect.Value) (int64, error) {
+	dialect := txCtx.Dialect()
+	return txCtx.copyEntities(txCtx.Context(), quoteFolded(dialect, "BulkRate"), forSelect(dialect, rates.Type().Elem(), nil, 0),
+		rates, 0)
+}
+
+// BenchmarkInsertEntities compares COPY and multi-row VALUES with a loop of InsertEntity
+// on Postgres, when TKT_POSTGRES_DATASOURCE is set, and VALUES with the loop on SQLite.
+func BenchmarkInsertEntities(b *testing.B) {
+	type method struct {
+		name   string
+		insert func(*TxCtx, reflect.Value) (int64, error)
+	}
+	for _, rows := range []int{100, 10000} {
+		for _, method := range []method{{"loop", insertLoop}, {"values", insertValues}} {
+			insert := method.insert
+			b.Run(fmt.Sprintf("sqlite/%s/%d", method.name, rows), func(b *testing.B) {
+				benchmarkInsert(b, sqliteConfig(b, "bulk.db", 2), rows, insert)
+			})
+		}
+		datasource := os.Getenv(postgresDatasourceEnv)
+		driver := copyDriver
+		config := DatabaseConfig{DatabaseDriver: &driver, DatasourceName: &datasource}
+		for _, method := range []method{{"loop", insertLoop}, {"values", insertValues}, {"copy", insertCopy}} {
+			insert := method.insert
+			b.Run(fmt.Sprintf("postgres/%s/%d", method.name, rows), func(b *testing.B) {
+				if datasource == "" {
+					b.Skip(postgresDatasourceEnv + " is not set")
+				}
+				benchmarkInsert(b, config, rows, insert)
+			})
+		}
+	}
+}
@@ -0,0 +1,283 @@
+package tkt
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type RoundingMode int
+
+const (
+	// RoundHalfUp rounds to the nearest neighbour, ties away from zero: 2.5 to 3, -2.5 to -3.
+	RoundHalfUp RoundingMode = iota
+	// RoundHalfEven rounds to the nearest neighbour, ties to the even one: 2.5 to 2, 3.5 to 4.
+	RoundHalfEven
+	// RoundDown truncates towards zero: 2.9 to 2, -2.9 to -2.
+	RoundDown
+)
+
+// maxDecimalExponent bounds the exponent accepted by ParseDecimal, so that 1e999999999
+// cannot make it allocate gigabytes.
+const maxDecimalExponent = 10000
+
+var decimalRegexp = regexp.MustCompile(`^([+-]?)([0-9]*)(?:\.([0-9]*))?(?:[eE]([+-]?[0-9]+))?$`)
+
+var bigTen = big.NewInt(10)
+
+// Decimal is an exact decimal number of arbitrary precision: an integer scaled down by a
+// power of ten. Sums, differences and products are exact; quotients and scale reductions
+// round as asked. Decimals are values, the operations return new ones, and the zero value
+// is 0.
+type Decimal struct {
+	unscaled *big.Int
+	scale    int
+}
+
+// NewDecimal returns unscaled / 10^scale.
+func NewDecimal(unscaled int64, scale int) Decimal {
+	if scale < 0 {
+		panic("Negative decimal scale")
+	}
+	return Decimal{unscaled: big.NewInt(unscaled), scale: scale}
+}
+
+// ParseDecimal reads a decimal in plain or exponent notation, such as "5", "-0.29" or
+// "1.5e3", keeping the digits after the point as its scale.
+func ParseDecimal(s string) (Decimal, error) {
+	m := decimalRegexp.FindStringSubmatch(s)
+	if m == nil || m[2]+m[3] == "" {
+		return Decimal{}, fmt.Errorf("Invalid decimal %q", s)
+	}
+	unscaled, _ := new(big.Int).SetString(m[2]+m[3], 10)
+	if m[1] == "-" {
+		unscaled.Neg(unscaled)
+	}
+	scale := len(m[3])
+	if m[4] != "" {
+		exponent, err := strconv.Atoi(m[4])
+		if err != nil || exponent > maxDecimalExponent || exponent < -maxDecimalExponent {
+			return Decimal{}, fmt.Errorf("Decimal exponent out of range in %q", s)
+		}
+		scale -= exponent
+	}
+	if scale < 0 {
+		unscaled.Mul(unscaled, pow10(-scale))
+		scale = 0
+	}
+	return Decimal{unscaled: unscaled, scale: scale}, nil
+}
+
+// DecimalFromFloat64 returns the shortest decimal that reads back as f, so that 0.29 is
+// 0.29 and not the binary approximation below it.
+func DecimalFromFloat64(f float64) Decimal {
+	d, err := ParseDecimal(strconv.FormatFloat(f, 'f', -1, 64))
+	CheckErr(err)
+	return d
+}
+
+func (o Decimal) int() *big.Int {
+	if o.unscaled == nil {
+		return new(big.Int)
+	}
+	return o.unscaled
+}
+
+func (o Decimal) Scale() int {
+	return o.scale
+}
+
+// Sign returns -1, 0 or 1.
+func (o Decimal) Sign() int {
+	return o.int().Sign()
+}
+
+func (o Decimal) IsZero() bool {
+	return o.Sign() == 0
+}
+
+// Cmp returns -1, 0 or 1 as o is less than, equal to or greater than v, whatever their
+// scales: 1.5 equals 1.50.
+func (o Decimal) Cmp(v Decimal) int {
+	a, b := align(o, v)
+	return a.Cmp(b)
+}
+
+func (o Decimal) Neg() Decimal {
+	return Decimal{unscaled: new(big.Int).Neg(o.int()), scale: o.scale}
+}
+
+func (o Decimal) Abs() Decimal {
+	return Decimal{unscaled: new(big.Int).Abs(o.int()), scale: o.scale}
+}
+
+// Add returns o + v, at the larger scale of both.
+func (o Decimal) Add(v Decimal) Decimal {
+	a, b := align(o, v)
+	return Decimal{unscaled: a.Add(a, b), scale: maxInt(o.scale, v.scale)}
+}
+
+// Sub returns o - v, at the larger scale of both.
+func (o Decimal) Sub(v Decimal) Decimal {
+	a, b := align(o, v)
+	return Decimal{unscaled: a.Sub(a, b), scale: maxInt(o.scale, v.scale)}
+}
+
+// Mul returns o * v, at the sum of their scales.
+func (o Decimal) Mul(v Decimal) Decimal {
+	return Decimal{unscaled: new(big.Int).Mul(o.int(), v.int()), scale: o.scale + v.scale}
+}
+
+// Div returns o / v at the given scale, rounded with mode, or an error when v is zero or
+// the scale is negative.
+func (o Decimal) Div(v Decimal, scale int, mode RoundingMode) (Decimal, error) {
+	if v.IsZero() {
+		return Decimal{}, errors.New("Decimal division by zero")
+	}
+	if scale < 0 {
+		return Decimal{}, fmt.Errorf("Negative decimal scale %d", scale)
+	}
+	// o / v = (a / 10^sa) / (b / 10^sb), so at scale s the result is a * 10^(s+sb-sa) / b.
+	n := new(big.Int).Set(o.int())
+	d := new(big.Int).Set(v.int())
+	if shift := scale + v.scale - o.scale; shift >= 0 {
+		n.Mul(n, pow10(shift))
+	} else {
+		d.Mul(d, pow10(-shift))
+	}
+	return Decimal{unscaled: roundQuo(n, d, mode), scale: scale}, nil
+}
+
+// Round returns the decimal at the given scale: exactly when the scale grows, rounded with
+// mode when it shrinks.
+func (o Decimal) Round(scale int, mode RoundingMode) Decimal {
+	if scale < 0 {
+		panic("Negative decimal scale")
+	}
+	if scale >= o.scale {
+		return Decimal{unscaled: new(big.Int).Mul(o.int(), pow10(scale-o.scale)), scale: scale}
+	}
+	return Decimal{unscaled: roundQuo(o.int(), pow10(o.scale-scale), mode), scale: scale}
+}
+
+// Float64 returns the nearest float64.
+func (o Decimal) Float64() float64 {
+	f, _ := strconv.ParseFloat(o.String(), 64)
+	return f
+}
+
+// String returns the decimal in plain notation with scale digits after the point.
+func (o Decimal) String() string {
+	digits := new(big.Int).Abs(o.int()).String()
+	buf := bytes.Buffer{}
+	if o.Sign() < 0 {
+		buf.WriteByte('-')
+	}
+	if len(digits) <= o.scale {
+		digits = strings.Repeat("0", o.scale-len(digits)+1) + digits
+	}
+	buf.WriteString(digits[:len(digits)-o.scale])
+	if o.scale > 0 {
+		buf.WriteByte('.')
+		buf.WriteString(digits[len(digits)-o.scale:])
+	}
+	return buf.String()
+}
+
+// MarshalJSON writes the decimal as a JSON number with all its digits.
+func (o Decimal) MarshalJSON() ([]byte, error) {
+	return []byte(o.String()), nil
+}
+
+// UnmarshalJSON reads a JSON number or a string holding one. A null leaves the decimal as
+// it is.
+func (o *Decimal) UnmarshalJSON(b []byte) error {
+	s := string(b)
+	if s == "null" {
+		return nil
+	}
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		s = s[1 : len(s)-1]
+	}
+	d, err := ParseDecimal(s)
+	if err != nil {
+		return err
+	}
+	*o = d
+	return nil
+}
+
+// Value stores the decimal as its plain notation, which numeric columns take exactly.
+func (o Decimal) Value() (driver.Value, error) {
+	return o.String(), nil
+}
+
+func (o *Decimal) Scan(src interface{}) error {
+	var d Decimal
+	var err error
+	switch v := src.(type) {
+	case string:
+		d, err = ParseDecimal(v)
+	case []byte:
+		d, err = ParseDecimal(string(v))
+	case int64:
+		d = NewDecimal(v, 0)
+	case float64:
+		d = DecimalFromFloat64(v)
+	default:
+		err = fmt.Errorf("Cannot scan %T into a Decimal", src)
+	}
+	if err != nil {
+		return err
+	}
+	*o = d
+	return nil
+}
+
+// align returns the unscaled values of both decimals at the larger scale of the two, as
+// new integers.
+func align(a Decimal, b Decimal) (*big.Int, *big.Int) {
+	x := new(big.Int).Set(a.int())
+	y := new(big.Int).Set(b.int())
+	if a.scale < b.scale {
+		x.Mul(x, pow10(b.scale-a.scale))
+	} else if b.scale < a.scale {
+		y.Mul(y, pow10(a.scale-b.scale))
+	}
+	return x, y
+}
+
+// roundQuo returns n / d rounded with mode.
+func roundQuo(n *big.Int, d *big.Int, mode RoundingMode) *big.Int {
+	q, r := new(big.Int).QuoRem(n, d, new(big.Int))
+	if r.Sign() == 0 || mode == RoundDown {
+		return q
+	}
+	// The remainder is at least half of the divisor when 2|r| >= |d|.
+	twice := new(big.Int).Abs(r)
+	c := twice.Lsh(twice, 1).Cmp(new(big.Int).Abs(d))
+	if c > 0 || (c == 0 && (mode == RoundHalfUp || q.Bit(0) == 1)) {
+		if (n.Sign() < 0) != (d.Sign() < 0) {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(bigTen, big.NewInt(int64(n)), nil)
+}
+
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,194 @@
+package tkt
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func parseFixed(s string) *Fixed {
+	f := &Fixed{}
+	f.Parse(s)
+	return f
+}
+
+func TestFixed(t *testing.T) {
+	five := parseFixed("5")
+	if five.String() != "5" || five.Scale() != 0 {
+		t.Errorf("Parse(\"5\"): %s at scale %d", five.String(), five.Scale())
+	}
+	if s := FixedWithValue(2, 0.29).String(); s != "0.29" {
+		t.Errorf("0.29 became %s", s)
+	}
+	if s := FixedWithValue(2, 1.005).String(); s != "1.00" {
+		t.Errorf("1.005 truncated to %s", s)
+	}
+	if s := FixedWithValue(2, -0.5).String(); s != "-0.50" {
+		t.Errorf("-0.5 printed as %s", s)
+	}
+	if s := parseFixed("-0.05").String(); s != "-0.05" {
+		t.Errorf("-0.05 printed as %s", s)
+	}
+
+	big := parseFixed("9223372036854775.807")
+	big.Mult(*parseFixed("1000.000"))
+	if s := big.String(); s != "9223372036854775807.000" {
+		t.Errorf("product %s", s)
+	}
+	big.Mult(*parseFixed("10.000"))
+	if s := big.String(); s != "92233720368547758070.000" {
+		t.Errorf("product past int64 %s", s)
+	}
+	third := parseFixed("1.00")
+	third.Div(*parseFixed("3.00"))
+	if s := third.String(); s != "0.33" {
+		t.Errorf("quotient %s", s)
+	}
+	product := parseFixed("-1.25")
+	product.Mult(*parseFixed("0.99"))
+	if s := product.String(); s != "-1.23" {
+		t.Errorf("product %s, want -1.23 truncated", s)
+	}
+
+	data, err := json.Marshal(FixedWithValue(3, 2.5))
+	if err != nil || string(data) != `"2.500"` {
+		t.Errorf("JSON %s, %v", data, err)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("adding different scales was accepted")
+			}
+		}()
+		a := parseFixed("1.0")
+		a.Add(*parseFixed("1.00"))
+	}()
+}
+
+func TestDecimalString(t *testing.T) {
+	tests := []struct {
+		d    Decimal
+		want string
+	}{
+		{NewDecimal(-5, 2), "-0.05"},
+		{NewDecimal(-123, 1), "-12.3"},
+		{NewDecimal(-7, 0), "-7"},
+		{NewDecimal(5, 3), "0.005"},
+		{NewDecimal(0, 2), "0.00"},
+		{Decimal{}, "0"},
+	}
+	for _, test := range tests {
+		if got := test.d.String(); got != test.want {
+			t.Errorf("got %s, want %s", got, test.want)
+		}
+	}
+}
+
+func TestDecimalRound(t *testing.T) {
+	tests := []struct {
+		value                  string
+		halfUp, halfEven, down string
+	}{
+		{"2.5", "3", "2", "2"},
+		{"-2.5", "-3", "-2", "-2"},
+		{"-1.5", "-2", "-2", "-1"},
+		{"-0.5", "-1", "0", "0"},
+		{"-2.51", "-3", "-3", "-2"},
+		{"-2.49", "-2", "-2", "-2"},
+		{"3.5", "4", "4", "3"},
+	}
+	for _, test := range tests {
+		d, err := ParseDecimal(test.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for mode, want := range map[RoundingMode]string{RoundHalfUp: test.halfUp, RoundHalfEven: test.halfEven, RoundDown: test.down} {
+			if got := d.Round(0, mode).String(); got != want {
+				t.Errorf("%s in mode %d: %s, want %s", test.value, mode, got, want)
+			}
+		}
+	}
+	if got := NewDecimal(-125, 2).Round(1, RoundHalfEven).String(); got != "-1.2" {
+		t.Errorf("-1.25 to even: %s", got)
+	}
+	if got := NewDecimal(15, 1).Round(3, RoundDown).String(); got != "1.500" {
+		t.Errorf("1.5 at scale 3: %s", got)
+	}
+}
+
+func TestParseDecimal(t *testing.T) {
+	valid := map[string]string{
+		"5":       "5",
+		"-0.29":   "-0.29",
+		"+.5":     "0.5",
+		"5.":      "5",
+		"1.5e3":   "1500",
+		"1.50E-1": "0.150",
+		"-2e-3":   "-0.002",
+	}
+	for s, want := range valid {
+		if d, err := ParseDecimal(s); err != nil || d.String() != want {
+			t.Errorf("%s: got %s, %v, want %s", s, d, err, want)
+		}
+	}
+	if d, err := ParseDecimal("1e10000"); err != nil || d.Scale() != 0 || len(d.String()) != 10001 {
+		t.Errorf("1e10000: %d digits at scale %d, %v", len(d.String()), d.Scale(), err)
+	}
+	if d, err := ParseDecimal("2e-10000"); err != nil || d.Scale() != 10000 || d.Sign() != 1 {
+		t.Errorf("2e-10000: scale %d, %v", d.Scale(), err)
+	}
+	for _, s := range []string{"", ".", "-", "1..2", "abc", "1e", "1e+", "0x10", " 1", "1e10001", "1e-10001", "1e99999999999999999999"} {
+		if d, err := ParseDecimal(s); err == nil {
+			t.Errorf("%q read as %s", s, d)
+		}
+	}
+}
+
+func TestDecimalArithmetic(t *testing.T) {
+	a, _ := ParseDecimal("1.5")
+	b, _ := ParseDecimal("0.25")
+	if got := a.Add(b).String(); got != "1.75" {
+		t.Errorf("sum %s", got)
+	}
+	if got := b.Sub(a).String(); got != "-1.25" {
+		t.Errorf("difference %s", got)
+	}
+	if got := a.Mul(b).String(); got != "0.375" {
+		t.Errorf("product %s", got)
+	}
+	if a.Cmp(NewDecimal(150, 2)) != 0 || a.Cmp(b) != 1 || b.Cmp(a) != -1 {
+		t.Error("comparison depends on the scale")
+	}
+	if a.Neg().String() != "-1.5" || a.Neg().Abs().String() != "1.5" || a.Neg().Sign() != -1 || !(Decimal{}).IsZero() {
+		t.Error("sign helpers")
+	}
+	if a.String() != "1.5" {
+		t.Errorf("an operation changed its operand to %s", a)
+	}
+}
+
+func TestDecimalScan(t *testing.T) {
+	tests := []struct {
+		src  interface{}
+		want string
+	}{
+		{"12.50", "12.50"},
+		{[]byte("-0.001"), "-0.001"},
+		{int64(42), "42"},
+		{0.29, "0.29"},
+	}
+	for _, test := range tests {
+		var d Decimal
+		if err := d.Scan(test.src); err != nil || d.String() != test.want {
+			t.Errorf("%#v: %s, %v", test.src, d, err)
+		}
+	}
+	d := NewDecimal(1, 0)
+	for _, src := range []interface{}{true, "x1", []byte("")} {
+		if err := d.Scan(src); err == nil || d.String() != "1" {
+			t.Errorf("%#v scanned as %s", src, d)
+		}
+	}
+	if v, err := NewDecimal(-250, 2).Value(); err != nil || v != "-2.50" {
+		t.Errorf("value %#v, %v", v, err)
+	}
+}
@@ -0,0 +1,58 @@
+package tkt
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestDecimalUnmarshalNullLeavesTheValue(t *testing.T) {
+	var v struct {
+		Amount  Decimal
+		Pointer *Decimal
+		Fixed   Fixed
+	}
+	v.Amount = NewDecimal(125, 2)
+	v.Fixed.Parse("3.5")
+	if err := json.Unmarshal([]byte(`{"Amount": null, "Pointer": null, "Fixed": null}`), &v); err != nil {
+		t.Fatal(err)
+	}
+	if v.Amount.String() != "1.25" || v.Pointer != nil || v.Fixed.String() != "3.5" {
+		t.Errorf("amount %s, pointer %v, fixed %s", v.Amount, v.Pointer, v.Fixed.String())
+	}
+	if err := json.Unmarshal([]byte(`{"Amount": "2.50"}`), &v); err != nil || v.Amount.String() != "2.50" {
+		t.Errorf("amount %s, %v", v.Amount, err)
+	}
+}
+
+func TestDecimalDiv(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		scale int
+		mode  RoundingMode
+		want  string
+	}{
+		{"10", "3", 2, RoundHalfUp, "3.33"},
+		{"2", "3", 2, RoundHalfUp, "0.67"},
+		{"2", "3", 2, RoundDown, "0.66"},
+		{"-1", "8", 2, RoundHalfEven, "-0.12"},
+		{"1.5", "0.5", 0, RoundHalfUp, "3"},
+	}
+	for _, test := range tests {
+		a, _ := ParseDecimal(test.a)
+		b, _ := ParseDecimal(test.b)
+		got, err := a.Div(b, test.scale, test.mode)
+		if err != nil || got.String() != test.want {
+			t.Errorf("%s / %s: %s, %v, want %s", test.a, test.b, got, err, test.want)
+		}
+	}
+}
+
+func TestDecimalDivRefusesNegativeScaleAndZero(t *testing.T) {
+	one := NewDecimal(1, 0)
+	if d, err := one.Div(NewDecimal(3, 0), -1, RoundHalfUp); err == nil {
+		t.Errorf("negative scale: %s", d)
+	}
+	if d, err := one.Div(Decimal{}, 2, RoundHalfUp); err == nil {
+		t.Errorf("division by zero: %s", d)
+	}
+}
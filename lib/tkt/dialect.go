@@ -0,0 +1,210 @@
+package tkt
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Dialect tells the SQL builders how a database spells what differs between databases.
+type Dialect interface {
+	Name() string
+	// Placeholder returns the n-th statement parameter, counted from 1.
+	Placeholder(n int) string
+	// QuoteIdentifier quotes a name, qualified with dots or not, keeping its case, and
+	// panics when a part is empty or holds control characters.
+	QuoteIdentifier(name string) string
+	// FoldIdentifier returns the name the database gives an object created with the name
+	// unquoted, to quote names that stand for such objects.
+	FoldIdentifier(name string) string
+	// SupportsSchemas tells whether tables are qualified by schemas made with create schema.
+	SupportsSchemas() bool
+	// SupportsReturning tells whether insert ... returning gives generated ids; otherwise
+	// they are read with LastInsertId.
+	SupportsReturning() bool
+	// NextValSql returns the query of the next value of a sequence, quoting its name, or ""
+	// when the database has no sequences.
+	NextValSql(sequence string) string
+	// MaxParameters returns the most parameters a statement may have.
+	MaxParameters() int
+	// TimestampType returns the column type of instants, read back as time.Time.
+	TimestampType() string
+	// LockSql returns the statements taking and releasing a session lock on the key given
+	// as first parameter, or "" when the database needs none.
+	LockSql() (lock string, unlock string)
+	// TableExistsSql returns the query of whether a table, qualified by a schema or not,
+	// exists, quoting its name.
+	TableExistsSql(table string) string
+}
+
+type PostgresDialect struct{}
+
+func (PostgresDialect) Name() string {
+	return "postgres"
+}
+
+func (PostgresDialect) Placeholder(n int) string {
+	return fmt.Sprintf("$%d", n)
+}
+
+func (PostgresDialect) QuoteIdentifier(name string) string {
+	return quoteIdentifier(name)
+}
+
+// FoldIdentifier lowers the case of the name, as Postgres does with unquoted names.
+func (PostgresDialect) FoldIdentifier(name string) string {
+	return strings.ToLower(name)
+}
+
+func (PostgresDialect) SupportsSchemas() bool {
+	return true
+}
+
+func (PostgresDialect) SupportsReturning() bool {
+	return true
+}
+
+func (PostgresDialect) NextValSql(sequence string) string {
+	return "select nextval('" + strings.ReplaceAll(quoteFolded(PostgresDialect{}, sequence), "'", "''") + "')"
+}
+
+func (PostgresDialect) MaxParameters() int {
+	return 65535
+}
+
+func (PostgresDialect) TimestampType() string {
+	return "timestamptz"
+}
+
+func (PostgresDialect) LockSql() (string, string) {
+	return "select pg_advisory_lock($1)", "select pg_advisory_unlock($1)"
+}
+
+func (PostgresDialect) TableExistsSql(table string) string {
+	return "select to_regclass(" + quoteLiteral(quoteIdentifier(table)) + ") is not null"
+}
+
+// SqliteDialect serves the pure Go modernc.org/sqlite driver, registered as "sqlite" by
+// the applications that import it. The only schema is main, and sequences are kept in
+// memory.
+type SqliteDialect struct{}
+
+func (SqliteDialect) Name() string {
+	return "sqlite"
+}
+
+func (SqliteDialect) Placeholder(n int) string {
+	return fmt.Sprintf("?%d", n)
+}
+
+func (SqliteDialect) QuoteIdentifier(name string) string {
+	return quoteIdentifier(name)
+}
+
+// FoldIdentifier keeps the name, as SQLite matches names regardless of case.
+func (SqliteDialect) FoldIdentifier(name string) string {
+	return name
+}
+
+func (SqliteDialect) SupportsSchemas() bool {
+	return false
+}
+
+func (SqliteDialect) SupportsReturning() bool {
+	return false
+}
+
+func (SqliteDialect) NextValSql(sequence string) string {
+	return ""
+}
+
+// MaxParameters is the limit of SQLite 3.32 and later.
+func (SqliteDialect) MaxParameters() int {
+	return 32766
+}
+
+// TimestampType is one of the declared types for which the driver parses times back.
+func (SqliteDialect) TimestampType() string {
+	return "timestamp"
+}
+
+func (SqliteDialect) LockSql() (string, string) {
+	return "", ""
+}
+
+// TableExistsSql looks the table up in the catalog of its schema, main when unqualified.
+func (SqliteDialect) TableExistsSql(table string) string {
+	schema := "main"
+	if i := strings.LastIndexByte(table, '.'); i >= 0 {
+		schema, table = table[:i], table[i+1:]
+	}
+	return "select count(*) > 0 from " + quoteIdentifier(schema) + ".sqlite_master where type = 'table' and name = " +
+		quoteLiteral(table) + " collate nocase"
+}
+
+// quoteIdentifier quotes each dot separated part of a name as standard SQL does.
+func quoteIdentifier(name string) string {
+	CheckErr(ValidateIdentifier(name))
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = `"` + strings.ReplaceAll(part, `"`, `""`) + `"`
+	}
+	return strings.Join(parts, ".")
+}
+
+// quoteLiteral quotes a string constant.
+func quoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+// quoteFolded quotes a name standing for an object created with it unquoted, as the
+// tables and columns of entities and their sequences are.
+func quoteFolded(dialect Dialect, name string) string {
+	return dialect.QuoteIdentifier(dialect.FoldIdentifier(name))
+}
+
+// ValidateIdentifier fails when a dot separated part of a name is empty, is not UTF-8 or
+// holds control characters, none of which quoting makes safe.
+func ValidateIdentifier(name string) error {
+	for _, part := range strings.Split(name, ".") {
+		if part == "" {
+			return fmt.Errorf("invalid identifier %q: empty part", name)
+		}
+		if !utf8.ValidString(part) {
+			return fmt.Errorf("invalid identifier %q: not UTF-8", name)
+		}
+		for _, r := range part {
+			if unicode.IsControl(r) {
+				return fmt.Errorf("invalid identifier %q: control character", name)
+			}
+		}
+	}
+	return nil
+}
+
+var dialects = map[string]Dialect{
+	"postgres": PostgresDialect{},
+	"pgx":      PostgresDialect{},
+	"sqlite":   SqliteDialect{},
+}
+var dialectsMux = sync.RWMutex{}
+
+// RegisterDialect sets the dialect of a database/sql driver name.
+func RegisterDialect(driver string, dialect Dialect) {
+	dialectsMux.Lock()
+	defer dialectsMux.Unlock()
+	dialects[driver] = dialect
+}
+
+// DialectOf returns the dialect of the configured driver.
+func DialectOf(config DatabaseConfig) Dialect {
+	dialectsMux.RLock()
+	defer dialectsMux.RUnlock()
+	dialect, ok := dialects[*config.DatabaseDriver]
+	if !ok {
+		panic("No SQL dialect for driver " + *config.DatabaseDriver)
+	}
+	return dialect
+}
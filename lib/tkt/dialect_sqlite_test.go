@@ -0,0 +1,109 @@
+package tkt
+
+import (
+	_ "modernc.org/sqlite"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type Widget struct {
+	Id      int64
+	Name    string
+	Created time.Time
+}
+
+func TestDialects(t *testing.T) {
+	tests := []struct {
+		dialect     Dialect
+		placeholder string
+		schemas     bool
+		returning   bool
+		timestamp   string
+		lock        string
+	}{
+		{PostgresDialect{}, "$3", true, true, "timestamptz", "select pg_advisory_lock($1)"},
+		{SqliteDialect{}, "?3", false, false, "timestamp", ""},
+	}
+	for _, test := range tests {
+		d := test.dialect
+		lock, _ := d.LockSql()
+		if d.Placeholder(3) != test.placeholder || d.SupportsSchemas() != test.schemas || d.SupportsReturning() != test.returning ||
+			d.TimestampType() != test.timestamp || lock != test.lock {
+			t.Errorf("%s: unexpected %s %v %v %s %q", d.Name(), d.Placeholder(3), d.SupportsSchemas(), d.SupportsReturning(), d.TimestampType(), lock)
+		}
+	}
+	if (SqliteDialect{}).NextValSql("Widget") != "" {
+		t.Error("SQLite has sequences")
+	}
+}
+
+func TestDialectOf(t *testing.T) {
+	for driver, want := range map[string]Dialect{"postgres": PostgresDialect{}, "pgx": PostgresDialect{}, "sqlite": SqliteDialect{}} {
+		driver := driver
+		if got := DialectOf(DatabaseConfig{DatabaseDriver: &driver}); got != want {
+			t.Errorf("%s: got %s", driver, got.Name())
+		}
+	}
+	driver := "tktdialect"
+	func() {
+		defer func() {
+			if r := recover(); r != "No SQL dialect for driver tktdialect" {
+				t.Errorf("recovered %v", r)
+			}
+		}()
+		DialectOf(DatabaseConfig{DatabaseDriver: &driver})
+	}()
+	RegisterDialect(driver, SqliteDialect{})
+	defer func() {
+		dialectsMux.Lock()
+		defer dialectsMux.Unlock()
+		delete(dialects, driver)
+	}()
+	if got := DialectOf(DatabaseConfig{DatabaseDriver: &driver}); got != (SqliteDialect{}) {
+		t.Errorf("registered dialect not found, got %s", got.Name())
+	}
+}
+
+func TestSqliteEntities(t *testing.T) {
+	defer ClosePools()
+	driver := "sqlite"
+	datasource := "file:" + filepath.Join(t.TempDir(), "entities.db")
+	config := DatabaseConfig{DatabaseDriver: &driver, DatasourceName: &datasource}
+	created := time.Date(2024, 5, 17, 10, 30, 0, 0, time.UTC)
+
+	ids := ExecuteTransactional(config, func(txCtx *TxCtx, args ...interface{}) interface{} {
+		txCtx.ExecSql("create table Widget (Id integer primary key autoincrement, Name text not null, Created timestamp not null)")
+		first := txCtx.InsertEntity("main", Widget{Name: "first", Created: created}, true)
+		second := txCtx.InsertEntity("main", Widget{Name: "second", Created: created}, true)
+		return []int64{first, second}
+	}).([]int64)
+	if !reflect.DeepEqual(ids, []int64{1, 2}) {
+		t.Fatalf("generated ids %v", ids)
+	}
+
+	got := ExecuteTransactional(config, func(txCtx *TxCtx, args ...interface{}) interface{} {
+		txCtx.UpdateEntity("main", Widget{Id: 1, Name: "renamed", Created: created.Add(time.Hour)})
+		txCtx.DeleteEntity("main", Widget{Id: 2})
+		txCtx.InsertEntity("main", Widget{Id: 7, Name: "third", Created: created}, false)
+		return txCtx.QueryStruct(Widget{}, "select Id, Name, Created from Widget order by Id")
+	}).([]Widget)
+	if len(got) != 2 || got[0].Id != 1 || got[0].Name != "renamed" || !got[0].Created.Equal(created.Add(time.Hour)) ||
+		got[1].Id != 7 || got[1].Name != "third" || !got[1].Created.Equal(created) {
+		t.Errorf("got %+v", got)
+	}
+}
+
+func TestTableExistsSql(t *testing.T) {
+	tests := map[string]string{
+		`select to_regclass('"audit"."Schema''s"') is not null`:                                                     PostgresDialect{}.TableExistsSql("audit.Schema's"),
+		`select count(*) > 0 from "main".sqlite_master where type = 'table' and name = 'Migration' collate nocase`:  SqliteDialect{}.TableExistsSql("Migration"),
+		`select count(*) > 0 from "other".sqlite_master where type = 'table' and name = 'Migration' collate nocase`: SqliteDialect{}.TableExistsSql("other.Migration"),
+	}
+	for want, got := range tests {
+		if got != want {
+			t.Errorf("got %s, want %s", got, want)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package tkt
+
+import (
+	"testing"
+)
+
+func TestQuoteIdentifier(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"select", `"select"`},
+		{"order", `"order"`},
+		{`a"b`, `"a""b"`},
+		{`""`, `""""""`},
+		{"audit.ValidationAudit", `"audit"."ValidationAudit"`},
+		{"MixedCase", `"MixedCase"`},
+		{"Group By", `"Group By"`},
+		{"año", `"año"`},
+	}
+	for _, dialect := range []Dialect{PostgresDialect{}, SqliteDialect{}} {
+		for _, test := range tests {
+			if got := dialect.QuoteIdentifier(test.name); got != test.want {
+				t.Errorf("%s: %q quoted as %s, want %s", dialect.Name(), test.name, got, test.want)
+			}
+		}
+	}
+}
+
+func TestQuoteIdentifierRejectsUnsafeNames(t *testing.T) {
+	for _, dialect := range []Dialect{PostgresDialect{}, SqliteDialect{}} {
+		for _, name := range []string{"", "a.", ".a", "a..b", "a\nb", "a\x00b", "\xff"} {
+			func() {
+				defer func() {
+					if recover() == nil {
+						t.Errorf("%s: %q quoted", dialect.Name(), name)
+					}
+				}()
+				dialect.QuoteIdentifier(name)
+			}()
+		}
+	}
+}
+
+func TestFoldIdentifier(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		name    string
+		want    string
+	}{
+		{PostgresDialect{}, "ValidationAudit", `"validationaudit"`},
+		{PostgresDialect{}, "audit.Order", `"audit"."order"`},
+		{PostgresDialect{}, `A"B`, `"a""b"`},
+		{SqliteDialect{}, "ValidationAudit", `"ValidationAudit"`},
+		{SqliteDialect{}, "main.Order", `"main"."Order"`},
+	}
+	for _, test := range tests {
+		if got := quoteFolded(test.dialect, test.name); got != test.want {
+			t.Errorf("%s: %q folded as %s, want %s", test.dialect.Name(), test.name, got, test.want)
+		}
+	}
+}
+
+func TestNextValSqlFoldsTheSequence(t *testing.T) {
+	if got, want := (PostgresDialect{}).NextValSql("Orderseq"), `select nextval('"orderseq"')`; got != want {
+		t.Errorf("%s, want %s", got, want)
+	}
+	if got, want := (PostgresDialect{}).NextValSql("it's"), `select nextval('"it''s"')`; got != want {
+		t.Errorf("%s, want %s", got, want)
+	}
+}
+
+type Order struct {
+	Id     int64
+	Select string
+	Group  string `sql:"group by"`
+	Quoted string `sql:"a\"b"`
+}
+
+func TestEntitiesWithReservedAndQuotedNames(t *testing.T) {
+	defer ClosePools()
+	config := sqliteConfig(t, "entities.db", 2)
+	ExecuteTransactional(config, func(txCtx *TxCtx, args ...interface{}) interface{} {
+		txCtx.ExecSql(`create table "Order" (Id integer primary key, "Select" text, "group by" text, "a""b" text)`)
+		txCtx.InsertEntity("", Order{Id: 1, Select: "s", Group: "g", Quoted: "q"}, false)
+		txCtx.UpdateEntity("main", Order{Id: 1, Select: "s2", Group: "g2", Quoted: "q2"})
+		found := txCtx.FindStruct(Order{}, "select "+ForSelectDialect(txCtx.Dialect(), Order{}, 0, "o")+
+			` from "Order" o where Id = 1`).(*Order)
+		if *found != (Order{Id: 1, Select: "s2", Group: "g2", Quoted: "q2"}) {
+			t.Errorf("found %+v", *found)
+		}
+		txCtx.DeleteEntity("", *found)
+		return nil
+	})
+}
@@ -0,0 +1,202 @@
+package tkt
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+)
+
+// fakeDriverName is a database/sql driver serving the fakeDB named by the data source.
+const fakeDriverName = "tktfake"
+
+var fakeDBs sync.Map
+
+func init() {
+	sql.Register(fakeDriverName, fakeDriver{})
+	RegisterDialect(fakeDriverName, PostgresDialect{})
+}
+
+// fakeDB records what reaches the driver and answers queries with canned rows or errors.
+type fakeDB struct {
+	mux        sync.Mutex
+	log        []string
+	rows       map[string]*fakeResult
+	errs       map[string]error
+	openStmts  int
+	commits    int
+	rollbacks  int
+	beginCount int
+}
+
+type fakeResult struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+// newFakeDB returns a fake database and the configuration opening it, for the test only.
+func newFakeDB(t *testing.T) (*fakeDB, DatabaseConfig) {
+	db := &fakeDB{rows: make(map[string]*fakeResult), errs: make(map[string]error)}
+	name := t.Name()
+	fakeDBs.Store(name, db)
+	t.Cleanup(func() { fakeDBs.Delete(name) })
+	driverName := fakeDriverName
+	sequenceManager := "inMemory"
+	return db, DatabaseConfig{DatabaseDriver: &driverName, DatasourceName: &name, SequenceManager: &sequenceManager}
+}
+
+func (o *fakeDB) returns(query string, columns []string, values ...[]driver.Value) {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	o.rows[query] = &fakeResult{columns: columns, values: values}
+}
+
+func (o *fakeDB) fails(query string, err error) {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	o.errs[query] = err
+}
+
+func (o *fakeDB) statements() []string {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	return append([]string(nil), o.log...)
+}
+
+func (o *fakeDB) counts() (commits int, rollbacks int, openStmts int) {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	return o.commits, o.rollbacks, o.openStmts
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	db, ok := fakeDBs.Load(name)
+	if !ok {
+		return nil, fmt.Errorf("no fake database %s", name)
+	}
+	return &fakeConn{db: db.(*fakeDB)}, nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (o *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	o.db.mux.Lock()
+	defer o.db.mux.Unlock()
+	if err := o.db.errs["prepare "+query]; err != nil {
+		return nil, err
+	}
+	o.db.openStmts++
+	return &fakeStmt{db: o.db, query: query}, nil
+}
+
+func (o *fakeConn) Close() error {
+	return nil
+}
+
+func (o *fakeConn) Begin() (driver.Tx, error) {
+	return o.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+func (o *fakeConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	o.db.mux.Lock()
+	defer o.db.mux.Unlock()
+	if err := o.db.errs["begin"]; err != nil {
+		return nil, err
+	}
+	o.db.beginCount++
+	return o, nil
+}
+
+func (o *fakeConn) Commit() error {
+	o.db.mux.Lock()
+	defer o.db.mux.Unlock()
+	if err := o.db.errs["commit"]; err != nil {
+		return err
+	}
+	o.db.commits++
+	return nil
+}
+
+func (o *fakeConn) Rollback() error {
+	o.db.mux.Lock()
+	defer o.db.mux.Unlock()
+	o.db.rollbacks++
+	return nil
+}
+
+type fakeStmt struct {
+	db     *fakeDB
+	query  string
+	closed bool
+}
+
+func (o *fakeStmt) Close() error {
+	o.db.mux.Lock()
+	defer o.db.mux.Unlock()
+	if !o.closed {
+		o.closed = true
+		o.db.openStmts--
+	}
+	return nil
+}
+
+func (o *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (o *fakeStmt) record(args []driver.Value) error {
+	o.db.mux.Lock()
+	defer o.db.mux.Unlock()
+	o.db.log = append(o.db.log, fmt.Sprint(o.query, " ", args))
+	return o.db.errs[o.query]
+}
+
+func (o *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	if err := o.record(args); err != nil {
+		return nil, err
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (o *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	if err := o.record(args); err != nil {
+		return nil, err
+	}
+	o.db.mux.Lock()
+	defer o.db.mux.Unlock()
+	result, ok := o.db.rows[o.query]
+	if !ok {
+		return nil, errors.New("no rows for " + o.query)
+	}
+	return &fakeRows{result: result}, nil
+}
+
+type fakeRows struct {
+	result *fakeResult
+	next   int
+}
+
+func (o *fakeRows) Columns() []string {
+	return o.result.columns
+}
+
+func (o *fakeRows) Close() error {
+	return nil
+}
+
+func (o *fakeRows) Next(dest []driver.Value) error {
+	if o.next == len(o.result.values) {
+		return io.EOF
+	}
+	copy(dest, o.result.values[o.next])
+	o.next++
+	return nil
+}
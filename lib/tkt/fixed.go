@@ -2,15 +2,13 @@ package tkt
 
 import (
 	"bytes"
-	"fmt"
-	"math"
-	"strings"
 )
 
+// Fixed is a mutable decimal of a fixed scale whose products and quotients are truncated
+// to that scale. It is kept for existing callers; new code should use Decimal.
 type Fixed struct {
-	value  int64
-	scale  int
-	factor int
+	value Decimal
+	scale int
 }
 
 func (o *Fixed) Scale() int {
@@ -18,61 +16,49 @@ func (o *Fixed) Scale() int {
 }
 
 func (o *Fixed) Float64() float64 {
-	return float64(o.value) / float64(o.factor)
+	return o.value.Float64()
+}
+
+func (o *Fixed) Decimal() Decimal {
+	return o.value.Round(o.scale, RoundDown)
 }
 
 func (o *Fixed) SetFloat64(f float64) *Fixed {
-	v := f * float64(o.factor)
-	o.value = int64(v)
+	o.value = DecimalFromFloat64(f).Round(o.scale, RoundDown)
 	return o
 }
 
 func (o *Fixed) Add(v Fixed) {
 	o.checkScale(v)
-	o.value += v.value
+	o.value = o.value.Add(v.value)
 }
 func (o *Fixed) Sub(v Fixed) {
 	o.checkScale(v)
-	o.value -= v.value
+	o.value = o.value.Sub(v.value)
 }
 
 func (o *Fixed) Mult(v Fixed) {
 	o.checkScale(v)
-	r := o.value * v.value
-	o.value = r / int64(o.factor)
+	o.value = o.value.Mul(v.value).Round(o.scale, RoundDown)
 }
 
 func (o *Fixed) Div(v Fixed) {
 	o.checkScale(v)
-	r := float64(o.value) / float64(v.value)
-	o.value = int64(r * float64(o.factor))
+	value, err := o.value.Div(v.value, o.scale, RoundDown)
+	CheckErr(err)
+	o.value = value
 }
 
+// Parse reads s, taking the number of digits after its point, if any, as the scale.
 func (o *Fixed) Parse(s string) {
-	parts := strings.Split(s, ".")
-	o.scale = len(parts[1])
-	o.factor = int(math.Pow(10, float64(o.scale)))
-	o.value = ParseInt(parts[0] + parts[1])
+	d, err := ParseDecimal(s)
+	CheckErr(err)
+	o.value = d
+	o.scale = d.Scale()
 }
 
 func (o *Fixed) String() string {
-	s := fmt.Sprintf("%d", o.value)
-	buf := bytes.Buffer{}
-	n := 0
-	for i := len(s); i < o.scale; i++ {
-		buf.WriteByte('0')
-		n++
-	}
-	if len(s)+n == o.scale {
-		buf.WriteByte('0')
-	}
-	buf.WriteString(s)
-	s = buf.String()
-	buf.Reset()
-	buf.WriteString(s[0 : len(s)-o.scale])
-	buf.WriteByte('.')
-	buf.WriteString(s[len(s)-o.scale:])
-	return buf.String()
+	return o.Decimal().String()
 }
 
 func (o *Fixed) checkScale(v Fixed) {
@@ -89,20 +75,27 @@ func (o *Fixed) MarshalJSON() ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
+// UnmarshalJSON reads a JSON number or a string holding one. A null leaves the value as it
+// is.
 func (o *Fixed) UnmarshalJSON(b []byte) error {
-	s := string(b[1 : len(b)-1])
-	o.Parse(s)
+	if string(b) == "null" {
+		return nil
+	}
+	var d Decimal
+	if err := d.UnmarshalJSON(b); err != nil {
+		return err
+	}
+	o.value = d
+	o.scale = d.Scale()
 	return nil
 }
 
 func NewFixed(scale int) *Fixed {
-	factor := math.Pow(10, float64(scale))
-	return &Fixed{value: 0, scale: scale, factor: int(factor)}
+	return &Fixed{value: NewDecimal(0, scale), scale: scale}
 }
 
 func FixedWithValue(scale int, value float64) *Fixed {
-	factor := math.Pow(10, float64(scale))
-	f := Fixed{value: 0, scale: scale, factor: int(factor)}
+	f := NewFixed(scale)
 	f.SetFloat64(value)
-	return &f
+	return f
 }
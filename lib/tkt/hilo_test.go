@@ -0,0 +1,66 @@
+package tkt
+
+import (
+	_ "modernc.org/sqlite"
+	"path/filepath"
+	"testing"
+)
+
+func hiLoTestConfig(datasource string, blockSize int) DatabaseConfig {
+	driver, manager, maxOpenConns := "sqlite", "hiLo", 4
+	return DatabaseConfig{DatabaseDriver: &driver, DatasourceName: &datasource, MaxOpenConns: &maxOpenConns,
+		SequenceManager: &manager, SequenceBlockSize: &blockSize}
+}
+
+func drawIds(config DatabaseConfig, name string, n int) []int64 {
+	ids := make([]int64, n)
+	for i := range ids {
+		ids[i] = ExecuteTransactional(config, func(txCtx *TxCtx, args ...interface{}) interface{} {
+			return *txCtx.NextId(name)
+		}).(int64)
+	}
+	return ids
+}
+
+func TestHiLoSequenceManager(t *testing.T) {
+	defer ClosePools()
+	file := filepath.Join(t.TempDir(), "blocks.db")
+	config := hiLoTestConfig("file:"+file+"?_pragma=busy_timeout(10000)", 10)
+	ExecuteTransactional(config, func(txCtx *TxCtx, args ...interface{}) interface{} {
+		txCtx.ExecSql("create table Item (id bigint primary key)")
+		for i := 1; i <= 5; i++ {
+			txCtx.ExecSql("insert into Item (id) values (?1)", i)
+		}
+		return nil
+	})
+
+	ids := drawIds(config, "Item", 25)
+	for i, id := range ids {
+		if id != int64(6+i) {
+			t.Fatalf("ids %v, want 6 to 30", ids)
+		}
+	}
+
+	// Another process starts after the blocks reserved by the first one.
+	restarted := hiLoTestConfig("file:"+file+"?_pragma=busy_timeout(10000)&_pragma=synchronous(1)", 10)
+	first := drawIds(restarted, "Item", 1)[0]
+	if first <= ids[len(ids)-1] || (first-6)%10 != 0 {
+		t.Errorf("the restarted sequence began at %d", first)
+	}
+	if next := drawIds(config, "Item", 1)[0]; next != 31 {
+		t.Errorf("the first process went on at %d, want 31", next)
+	}
+}
+
+func TestSequenceBlockSize(t *testing.T) {
+	if n := (&DatabaseConfig{}).blockSize(); n != 100 {
+		t.Errorf("default block size %d", n)
+	}
+	driver, datasource, zero := "sqlite", "file:x.db", 0
+	defer func() {
+		if recover() == nil {
+			t.Error("a block size of 0 was accepted")
+		}
+	}()
+	(&DatabaseConfig{DatabaseDriver: &driver, DatasourceName: &datasource, SequenceBlockSize: &zero}).Validate()
+}
@@ -0,0 +1,185 @@
+package tkt
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// IdGenerator makes unique string identifiers locally, without asking a database or
+// another service.
+type IdGenerator interface {
+	NewId() string
+}
+
+const (
+	UuidV4 = "uuidv4"
+	UuidV7 = "uuidv7"
+	Ulid   = "ulid"
+)
+
+var idGenerators = map[string]IdGenerator{
+	UuidV4: NewUuidV4Generator(),
+	UuidV7: NewUuidV7Generator(),
+	Ulid:   NewUlidGenerator(),
+}
+var idGeneratorsMux = sync.RWMutex{}
+
+// RegisterIdGenerator makes a generator available by name, to DatabaseConfig.IdGenerator
+// among others.
+func RegisterIdGenerator(name string, generator IdGenerator) {
+	idGeneratorsMux.Lock()
+	defer idGeneratorsMux.Unlock()
+	idGenerators[name] = generator
+}
+
+// IdGeneratorOf returns the generator registered with the name.
+func IdGeneratorOf(name string) (IdGenerator, bool) {
+	idGeneratorsMux.RLock()
+	defer idGeneratorsMux.RUnlock()
+	generator, ok := idGenerators[name]
+	return generator, ok
+}
+
+// UuidV4Generator makes random UUIDs.
+type UuidV4Generator struct{}
+
+func (o *UuidV4Generator) NewId() string {
+	var uuid [16]byte
+	randomBytes(uuid[:])
+	return formatUuid(uuid, 4)
+}
+
+func NewUuidV4Generator() *UuidV4Generator {
+	return &UuidV4Generator{}
+}
+
+// UuidV7Generator makes UUIDs that start with the Unix time in milliseconds, so they sort
+// in creation order. Within a millisecond the 12 bits after the version count up from a
+// random value, borrowing the next millisecond when they run out.
+type UuidV7Generator struct {
+	lastMs  int64
+	counter uint16
+	mux     sync.Mutex
+}
+
+func (o *UuidV7Generator) NewId() string {
+	var uuid [16]byte
+	randomBytes(uuid[6:])
+	o.mux.Lock()
+	ms := time.Now().UnixMilli()
+	if ms > o.lastMs {
+		o.lastMs = ms
+		o.counter = binary.BigEndian.Uint16(uuid[6:]) & 0x7ff
+	} else {
+		o.counter++
+		if o.counter > 0xfff {
+			o.lastMs++
+			o.counter = 0
+		}
+	}
+	ms, counter := o.lastMs, o.counter
+	o.mux.Unlock()
+	putMillis(uuid[:], ms)
+	binary.BigEndian.PutUint16(uuid[6:], counter)
+	return formatUuid(uuid, 7)
+}
+
+func NewUuidV7Generator() *UuidV7Generator {
+	return &UuidV7Generator{}
+}
+
+// formatUuid sets the version and RFC 9562 variant bits and returns the canonical form.
+func formatUuid(uuid [16]byte, version byte) string {
+	uuid[6] = uuid[6]&0x0f | version<<4
+	uuid[8] = uuid[8]&0x3f | 0x80
+	var buffer [36]byte
+	hex.Encode(buffer[0:8], uuid[0:4])
+	buffer[8] = '-'
+	hex.Encode(buffer[9:13], uuid[4:6])
+	buffer[13] = '-'
+	hex.Encode(buffer[14:18], uuid[6:8])
+	buffer[18] = '-'
+	hex.Encode(buffer[19:23], uuid[8:10])
+	buffer[23] = '-'
+	hex.Encode(buffer[24:], uuid[10:])
+	return string(buffer[:])
+}
+
+const crockfordBase32 = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// UlidGenerator makes ULIDs: 48 bits of Unix time in milliseconds and 80 random bits, in 26
+// characters of Crockford's base 32. Within a millisecond the random part is incremented,
+// so that the identifiers made by one generator sort in creation order.
+type UlidGenerator struct {
+	last [16]byte
+	mux  sync.Mutex
+}
+
+func (o *UlidGenerator) NewId() string {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	ms := time.Now().UnixMilli()
+	if ms > lastMillis(o.last[:]) {
+		putMillis(o.last[:], ms)
+		randomBytes(o.last[6:])
+	} else if !increment(o.last[6:]) {
+		putMillis(o.last[:], lastMillis(o.last[:])+1)
+	}
+	var buffer [26]byte
+	// 128 bits in 26 groups of 5, the first group holding the 3 bits left at the top.
+	for i := 25; i >= 0; i-- {
+		bit := 125 - 5*i
+		buffer[i] = crockfordBase32[bitsAt(o.last[:], bit)]
+	}
+	return string(buffer[:])
+}
+
+func NewUlidGenerator() *UlidGenerator {
+	return &UlidGenerator{}
+}
+
+// bitsAt returns the 5 bits of the big endian id that end bit bits from its low end.
+func bitsAt(id []byte, bit int) byte {
+	value := 0
+	for i := bit + 4; i >= bit; i-- {
+		value <<= 1
+		if i < 128 && id[15-i/8]&(1<<(i%8)) != 0 {
+			value |= 1
+		}
+	}
+	return byte(value)
+}
+
+// increment adds one to the big endian number, reporting false when it wraps around.
+func increment(number []byte) bool {
+	for i := len(number) - 1; i >= 0; i-- {
+		number[i]++
+		if number[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func putMillis(id []byte, ms int64) {
+	for i := 5; i >= 0; i-- {
+		id[i] = byte(ms)
+		ms >>= 8
+	}
+}
+
+func lastMillis(id []byte) int64 {
+	ms := int64(0)
+	for i := 0; i < 6; i++ {
+		ms = ms<<8 | int64(id[i])
+	}
+	return ms
+}
+
+func randomBytes(b []byte) {
+	_, err := rand.Read(b)
+	CheckErr(err)
+}
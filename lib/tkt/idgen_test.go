@@ -0,0 +1,180 @@
+package tkt
+
+import (
+	"encoding/hex"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+var uuidRegexp = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-([0-9a-f])[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+// uuidBytes returns the 16 bytes of a canonical UUID.
+func uuidBytes(t *testing.T, uuid string) []byte {
+	b, err := hex.DecodeString(strings.ReplaceAll(uuid, "-", ""))
+	if err != nil || len(b) != 16 {
+		t.Fatalf("%s: %v", uuid, err)
+	}
+	return b
+}
+
+// uuidV7Parts returns the millisecond timestamp and the counter of a UUIDv7.
+func uuidV7Parts(t *testing.T, uuid string) (int64, int) {
+	b := uuidBytes(t, uuid)
+	return lastMillis(b), int(b[6]&0x0f)<<8 | int(b[7])
+}
+
+// crockford encodes the 48-bit timestamp of a ULID in its first 10 characters.
+func crockford(ms int64) string {
+	var buffer [10]byte
+	for i := range buffer {
+		buffer[i] = crockfordBase32[ms>>(45-5*i)&31]
+	}
+	return string(buffer[:])
+}
+
+func TestUuidVersionAndVariant(t *testing.T) {
+	for version, generator := range map[string]IdGenerator{"4": NewUuidV4Generator(), "7": NewUuidV7Generator()} {
+		seen := make(map[string]bool)
+		for i := 0; i < 1000; i++ {
+			id := generator.NewId()
+			m := uuidRegexp.FindStringSubmatch(id)
+			if m == nil || m[1] != version {
+				t.Fatalf("%s is not a version %s UUID", id, version)
+			}
+			if seen[id] {
+				t.Fatalf("%s made twice", id)
+			}
+			seen[id] = true
+		}
+	}
+}
+
+func TestUuidV7SortsWithinAMillisecond(t *testing.T) {
+	now := time.Now().UnixMilli()
+	ms, _ := uuidV7Parts(t, NewUuidV7Generator().NewId())
+	if ms < now || ms > time.Now().UnixMilli() {
+		t.Errorf("timestamp %d, want about %d", ms, now)
+	}
+
+	future := now + 1000000
+	generator := &UuidV7Generator{lastMs: future, counter: 10}
+	ids := make([]string, 1000)
+	for i := range ids {
+		ids[i] = generator.NewId()
+		if ms, counter := uuidV7Parts(t, ids[i]); ms != future || counter != 11+i {
+			t.Fatalf("id %d: timestamp %d, counter %d", i, ms, counter)
+		}
+	}
+	if !sort.StringsAreSorted(ids) {
+		t.Error("ids of one millisecond are not in creation order")
+	}
+}
+
+func TestUuidV7BorrowsTheNextMillisecond(t *testing.T) {
+	future := time.Now().UnixMilli() + 1000000
+	generator := &UuidV7Generator{lastMs: future, counter: 0xffe}
+	ids := []string{generator.NewId(), generator.NewId(), generator.NewId()}
+	want := []struct {
+		ms      int64
+		counter int
+	}{{future, 0xfff}, {future + 1, 0}, {future + 1, 1}}
+	for i, id := range ids {
+		if ms, counter := uuidV7Parts(t, id); ms != want[i].ms || counter != want[i].counter {
+			t.Errorf("%s: timestamp %d, counter %x, want %d, %x", id, ms, counter, want[i].ms, want[i].counter)
+		}
+	}
+	if !sort.StringsAreSorted(ids) {
+		t.Errorf("%v not in creation order", ids)
+	}
+}
+
+func TestUlidCrockfordEncoding(t *testing.T) {
+	// The example of the ULID specification.
+	if got := crockford(1469922850259); got != "01ARZ3NDEK" {
+		t.Fatalf("test encoder gives %s", got)
+	}
+	now := time.Now().UnixMilli()
+	id := NewUlidGenerator().NewId()
+	if len(id) != 26 || strings.Trim(id, crockfordBase32) != "" {
+		t.Fatalf("%s is not a ULID", id)
+	}
+	if id[:10] < crockford(now) || id[:10] > crockford(time.Now().UnixMilli()) {
+		t.Errorf("%s does not start with about %s", id, crockford(now))
+	}
+
+	future := now + 1000000
+	generator := &UlidGenerator{}
+	putMillis(generator.last[:], future)
+	for i := 6; i < 16; i++ {
+		generator.last[i] = 0xff
+	}
+	generator.last[15] = 0xfd
+	ids := []string{generator.NewId(), generator.NewId(), generator.NewId()}
+	want := []string{
+		crockford(future) + "ZZZZZZZZZZZZZZZY",
+		crockford(future) + "ZZZZZZZZZZZZZZZZ",
+		crockford(future+1) + "0000000000000000",
+	}
+	for i := range want {
+		if ids[i] != want[i] {
+			t.Errorf("id %d: %s, want %s", i, ids[i], want[i])
+		}
+	}
+}
+
+func TestUlidSortsWithinAMillisecond(t *testing.T) {
+	future := time.Now().UnixMilli() + 1000000
+	generator := &UlidGenerator{}
+	putMillis(generator.last[:], future)
+	ids := make([]string, 1000)
+	for i := range ids {
+		ids[i] = generator.NewId()
+		if !strings.HasPrefix(ids[i], crockford(future)) {
+			t.Fatalf("%s left the millisecond", ids[i])
+		}
+	}
+	if !sort.StringsAreSorted(ids) {
+		t.Error("ids of one millisecond are not in creation order")
+	}
+	ids = make([]string, 10000)
+	generator = NewUlidGenerator()
+	for i := range ids {
+		ids[i] = generator.NewId()
+	}
+	if !sort.StringsAreSorted(ids) {
+		t.Error("ids are not in creation order")
+	}
+}
+
+type counterGenerator struct {
+	n int
+}
+
+func (o *counterGenerator) NewId() string {
+	o.n++
+	return strconv.Itoa(o.n)
+}
+
+func TestRegisterIdGenerator(t *testing.T) {
+	for _, name := range []string{UuidV4, UuidV7, Ulid} {
+		if _, ok := IdGeneratorOf(name); !ok {
+			t.Errorf("%s not registered", name)
+		}
+	}
+	if _, ok := IdGeneratorOf("counter"); ok {
+		t.Fatal("counter registered")
+	}
+	RegisterIdGenerator("counter", &counterGenerator{})
+	defer func() {
+		idGeneratorsMux.Lock()
+		defer idGeneratorsMux.Unlock()
+		delete(idGenerators, "counter")
+	}()
+	if generator, ok := IdGeneratorOf("counter"); !ok || generator.NewId() != "1" {
+		t.Error("counter not found")
+	}
+}
@@ -1,80 +1,306 @@
 package tkt
 
 import (
+	"compress/gzip"
 	"fmt"
 	"io"
 	"log"
 	"os"
-	"runtime/debug"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 var defaultLogger = log.New(os.Stdout, ":", log.Ldate|log.Lshortfile)
 
+const rotatedTimeFormat = "2006-01-02T15-04-05.000"
+
+// rotationRetry is how long writes go on to the current file after a failed rotation before
+// rotating is tried again.
+const rotationRetry = time.Minute
+
+// renameFile is os.Rename, replaced in tests.
+var renameFile = os.Rename
+
+// LogWriter writes to FileName and rotates it when it reaches MaxSize bytes and/or every
+// RotateEvery, renaming it to FileName.<timestamp>, gzipped when Compress is set. MaxFiles
+// and MaxAge bound the rotated files kept; zero values disable each rule.
 type LogWriter struct {
 	io.Writer
-	FileName    string
-	MaxSize     int
-	MaxFiles    int
-	inizialized bool
-	fileNumber  int
-	totalBytes  int
-	file        *os.File
-	mux         *sync.Mutex
-	excludes    []string
+	FileName     string
+	MaxSize      int
+	MaxFiles     int
+	MaxAge       time.Duration
+	RotateEvery  time.Duration
+	Compress     bool
+	inizialized  bool
+	totalBytes   int
+	nextRotation time.Time
+	lastStamp    string
+	lastSeq      int
+	retryAt      time.Time
+	file         *os.File
+	mux          *sync.Mutex
+	millMux      sync.Mutex
 }
 
 func (o *LogWriter) Write(p []byte) (n int, err error) {
 	if !o.inizialized {
-		o.mux = &sync.Mutex{}
 		o.initialize()
 	}
-	s1 := string(p)
-	s2 := string(debug.Stack())
-	for _, e := range o.excludes {
-		if !strings.HasPrefix(s1, "error:") && strings.Contains(s2, e) {
-			return
-		}
-	}
 	o.mux.Lock()
 	defer o.mux.Unlock()
-	w, err := o.file.Write(p)
-	if err != nil {
-		return w, err
+	now := time.Now()
+	if !now.Before(o.retryAt) && (o.RotateEvery > 0 && !now.Before(o.nextRotation) ||
+		o.MaxSize > 0 && o.totalBytes > 0 && o.totalBytes+len(p) > o.MaxSize) {
+		if err := o.rotate(now); err != nil {
+			o.retryAt = now.Add(rotationRetry)
+			fmt.Fprintf(os.Stderr, "log rotation: %s: %v\n", o.FileName, err)
+		}
 	}
+	w, err := o.file.Write(p)
 	o.totalBytes += w
-	if o.totalBytes >= o.MaxSize {
-		o.file.Close()
-		o.fileNumber++
-		if o.fileNumber == o.MaxFiles {
-			o.fileNumber = 1
-		}
-		o.createFile()
+	return w, err
+}
+
+// Rotate closes the current file, renames it and opens a new one.
+func (o *LogWriter) Rotate() error {
+	if !o.inizialized {
+		o.initialize()
 	}
-	return w, nil
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	return o.rotate(time.Now())
 }
 
-func (o *LogWriter) initialize() {
+func (o *LogWriter) Close() error {
+	if !o.inizialized {
+		return nil
+	}
 	o.mux.Lock()
 	defer o.mux.Unlock()
+	return o.file.Close()
+}
+
+func (o *LogWriter) initialize() {
+	initMux.Lock()
+	defer initMux.Unlock()
 	if o.inizialized {
 		return
 	}
-	o.fileNumber = 1
-	o.createFile()
+	o.mux = &sync.Mutex{}
+	CheckErr(o.openFile(time.Now()))
 	o.inizialized = true
 }
 
-func (o *LogWriter) createFile() {
-	var err error
-	name := fmt.Sprintf(o.FileName+".%d", o.fileNumber)
+var initMux sync.Mutex
 
-	o.file, err = os.Create(name)
-	CheckErr(err)
-	CheckErr(os.Chmod(name, 0644))
+// openFile opens FileName for appending, so that a restarted process continues the file it
+// was writing, with its size and age.
+func (o *LogWriter) openFile(now time.Time) error {
+	file, err := os.OpenFile(o.FileName, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	o.file = file
+	o.totalBytes = int(info.Size())
+	openedAt := now
+	if info.Size() > 0 {
+		openedAt = info.ModTime()
+	}
+	if o.RotateEvery > 0 {
+		o.nextRotation = openedAt.Truncate(o.RotateEvery).Add(o.RotateEvery)
+	}
+	return nil
+}
+
+// rotate renames the current file and opens a new one. When that fails, writing goes on to
+// the file it left open, so that a failed rotation does not lose the log.
+func (o *LogWriter) rotate(now time.Time) error {
+	if err := o.file.Close(); err != nil {
+		return o.reopen(o.FileName, err)
+	}
+	name := o.rotatedName(now)
+	if err := renameFile(o.FileName, name); err != nil {
+		return o.reopen(o.FileName, err)
+	}
+	if err := o.openFile(now); err != nil {
+		return o.reopen(name, err)
+	}
+	go o.mill(name)
+	return nil
+}
+
+// reopen opens name for appending after a failed rotation and returns the rotation error.
+func (o *LogWriter) reopen(name string, cause error) error {
+	file, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("%w; reopening %s: %v", cause, name, err)
+	}
+	o.file = file
+	return cause
+}
+
+// rotatedName names by rotation time; rotations within the same millisecond get an
+// increasing suffix, so names sort in rotation order even after older ones are removed.
+func (o *LogWriter) rotatedName(now time.Time) string {
+	stamp := now.Format(rotatedTimeFormat)
+	if stamp == o.lastStamp {
+		o.lastSeq++
+	} else {
+		o.lastStamp, o.lastSeq = stamp, 0
+	}
+	for {
+		name := o.FileName + "." + stamp
+		if o.lastSeq > 0 {
+			name = fmt.Sprintf("%s-%d", name, o.lastSeq)
+		}
+		if !fileOrGzipExists(name) {
+			return name
+		}
+		o.lastSeq++
+	}
+}
 
-	o.totalBytes = 0
+func fileOrGzipExists(name string) bool {
+	_, err := os.Stat(name)
+	if err == nil {
+		return true
+	}
+	_, err = os.Stat(name + ".gz")
+	return err == nil
+}
+
+// mill compresses a rotated file and applies retention, off the writing goroutine.
+func (o *LogWriter) mill(name string) {
+	o.millMux.Lock()
+	defer o.millMux.Unlock()
+	if o.Compress {
+		if err := gzipFile(name); err != nil && !os.IsNotExist(err) {
+			fmt.Fprintf(os.Stderr, "log rotation: compressing %s: %v\n", name, err)
+		}
+	}
+	if err := o.removeExpired(time.Now()); err != nil {
+		fmt.Fprintf(os.Stderr, "log rotation: retention of %s: %v\n", o.FileName, err)
+	}
+}
+
+type rotatedFile struct {
+	name string
+	time time.Time
+	seq  int
+}
+
+// rotatedFiles lists the rotated files of FileName, newest first.
+func (o *LogWriter) rotatedFiles() ([]rotatedFile, error) {
+	dir, base := filepath.Split(o.FileName)
+	if dir == "" {
+		dir = "."
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	prefix := base + "."
+	var files []rotatedFile
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || !strings.HasPrefix(name, prefix) || strings.HasSuffix(name, ".tmp") {
+			continue
+		}
+		stamp := strings.TrimSuffix(name[len(prefix):], ".gz")
+		seq := 0
+		if len(stamp) > len(rotatedTimeFormat) {
+			if seq, err = strconv.Atoi(strings.TrimPrefix(stamp[len(rotatedTimeFormat):], "-")); err != nil {
+				continue
+			}
+			stamp = stamp[:len(rotatedTimeFormat)]
+		}
+		t, err := time.ParseInLocation(rotatedTimeFormat, stamp, time.Local)
+		if err != nil {
+			continue
+		}
+		files = append(files, rotatedFile{name: filepath.Join(dir, name), time: t, seq: seq})
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].time.Equal(files[j].time) {
+			return files[i].seq > files[j].seq
+		}
+		return files[i].time.After(files[j].time)
+	})
+	return files, nil
+}
+
+// Files lists the files written so far, oldest first: the rotated ones, possibly gzipped,
+// followed by FileName when it exists.
+func (o *LogWriter) Files() ([]string, error) {
+	rotated, err := o.rotatedFiles()
+	if err != nil {
+		return nil, err
+	}
+	files := make([]string, 0, len(rotated)+1)
+	for i := len(rotated) - 1; i >= 0; i-- {
+		files = append(files, rotated[i].name)
+	}
+	if _, err := os.Stat(o.FileName); err == nil {
+		files = append(files, o.FileName)
+	}
+	return files, nil
+}
+
+func (o *LogWriter) removeExpired(now time.Time) error {
+	if o.MaxFiles <= 0 && o.MaxAge <= 0 {
+		return nil
+	}
+	files, err := o.rotatedFiles()
+	if err != nil {
+		return err
+	}
+	for i, f := range files {
+		if o.MaxFiles > 0 && i >= o.MaxFiles || o.MaxAge > 0 && now.Sub(f.time) > o.MaxAge {
+			if err := os.Remove(f.name); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func gzipFile(name string) error {
+	in, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	tmp := name + ".gz.tmp"
+	out, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	if _, err = io.Copy(zw, in); err == nil {
+		err = zw.Close()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(tmp, name+".gz")
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Remove(name)
 }
 
 type NullWriter struct {
@@ -86,12 +312,42 @@ func (o *NullWriter) Write(p []byte) (n int, err error) {
 }
 
 type LoggersConfig struct {
-	FileName     *string  `json:"fileName"`
-	MaxSize      *int     `json:"maxSize"`
-	MaxFiles     *int     `json:"maxFiles"`
-	LogToConsole *bool    `json:"logToConsole"`
-	Tags         []string `json:"tags"`
-	Excludes     []string `json:"excludes"`
+	FileName     *string         `json:"fileName"`
+	MaxSize      *int            `json:"maxSize"`
+	MaxFiles     *int            `json:"maxFiles"`
+	LogToConsole *bool           `json:"logToConsole"`
+	Tags         []string        `json:"tags"`
+	Excludes     []string        `json:"excludes"`
+	Format       *string         `json:"format"`
+	Level        *string         `json:"level"`
+	MaxAge       *string         `json:"maxAge"`
+	RotateEvery  *string         `json:"rotateEvery"`
+	Compress     *bool           `json:"compress"`
+	Rules        []LogRuleConfig `json:"rules"`
+	Sinks        []LogSinkConfig `json:"sinks"`
+}
+
+func parseOptionalDuration(s *string) time.Duration {
+	if s == nil {
+		return 0
+	}
+	d, err := time.ParseDuration(*s)
+	CheckErr(err)
+	return d
+}
+
+func validateFormat(format *string) {
+	if format != nil && !InStringList(*format, []string{FormatText, FormatJson, FormatLogfmt}) {
+		panic("Invalid format " + *format)
+	}
+}
+
+func validateLevel(level *string) {
+	if level != nil {
+		if _, ok := ParseLevel(*level); !ok {
+			panic("Invalid level " + *level)
+		}
+	}
 }
 
 func (o *LoggersConfig) Validate() {
@@ -113,31 +369,201 @@ func (o *LoggersConfig) Validate() {
 	if o.Excludes == nil {
 		panic("Missing excludes")
 	}
+	validateFormat(o.Format)
+	validateLevel(o.Level)
+	parseOptionalDuration(o.MaxAge)
+	parseOptionalDuration(o.RotateEvery)
+	for i := range o.Rules {
+		o.Rules[i].Validate()
+	}
+	for i := range o.Sinks {
+		o.Sinks[i].Validate()
+	}
+}
+
+// LogRuleConfig configures a LogRule; action is include (the default) or exclude.
+type LogRuleConfig struct {
+	Action   *string  `json:"action"`
+	Tags     []string `json:"tags"`
+	Levels   []string `json:"levels"`
+	Message  *string  `json:"message"`
+	Packages []string `json:"packages"`
+	Stack    []string `json:"stack"`
+}
+
+func (o *LogRuleConfig) Validate() {
+	if o.Action != nil && !InStringList(*o.Action, []string{"include", "exclude"}) {
+		panic("Invalid action " + *o.Action)
+	}
+	for i := range o.Levels {
+		validateLevel(&o.Levels[i])
+	}
+	if o.Message != nil {
+		regexp.MustCompile(*o.Message)
+	}
+}
+
+func (o *LogRuleConfig) Rule() LogRule {
+	rule := LogRule{Exclude: o.Action != nil && *o.Action == "exclude", Tags: o.Tags, Packages: o.Packages, Stack: o.Stack}
+	for _, name := range o.Levels {
+		level, _ := ParseLevel(name)
+		rule.Levels = append(rule.Levels, level)
+	}
+	if o.Message != nil {
+		rule.Message = regexp.MustCompile(*o.Message)
+	}
+	return rule
+}
+
+// excludeRules returns the rules of the legacy excludes: records whose call stack contains
+// one of them are dropped, except errors.
+func excludeRules(excludes []string) []LogRule {
+	if len(excludes) == 0 {
+		return nil
+	}
+	return []LogRule{{Tags: []string{"error"}}, {Levels: []Level{LevelError}}, {Exclude: true, Stack: excludes}}
+}
+
+func logRules(configs []LogRuleConfig) []LogRule {
+	rules := make([]LogRule, len(configs))
+	for i := range configs {
+		rules[i] = configs[i].Rule()
+	}
+	return rules
+}
+
+// LogSinkConfig configures an additional sink: a file, with the rotation settings of
+// LoggersConfig, the console, or udp to a syslog listener at address.
+type LogSinkConfig struct {
+	Type        *string         `json:"type"`
+	FileName    *string         `json:"fileName"`
+	MaxSize     *int            `json:"maxSize"`
+	MaxFiles    *int            `json:"maxFiles"`
+	MaxAge      *string         `json:"maxAge"`
+	RotateEvery *string         `json:"rotateEvery"`
+	Compress    *bool           `json:"compress"`
+	Address     *string         `json:"address"`
+	Format      *string         `json:"format"`
+	Level       *string         `json:"level"`
+	Rules       []LogRuleConfig `json:"rules"`
+}
+
+func (o *LogSinkConfig) Validate() {
+	if o.Type == nil {
+		panic("Missing sink type")
+	}
+	switch *o.Type {
+	case "file":
+		if o.FileName == nil {
+			panic("Missing sink fileName")
+		}
+		parseOptionalDuration(o.MaxAge)
+		parseOptionalDuration(o.RotateEvery)
+	case "console":
+	case "udp":
+		if o.Address == nil {
+			panic("Missing sink address")
+		}
+	default:
+		panic("Invalid sink type " + *o.Type)
+	}
+	validateFormat(o.Format)
+	validateLevel(o.Level)
+	for i := range o.Rules {
+		o.Rules[i].Validate()
+	}
+}
+
+func (o *LogSinkConfig) sink(defaultFormat string) LogSink {
+	format := defaultFormat
+	if o.Format != nil {
+		format = *o.Format
+	}
+	switch *o.Type {
+	case "file":
+		return NewWriterSink(newLogWriter(*o.FileName, o.MaxSize, o.MaxFiles, o.MaxAge, o.RotateEvery, o.Compress), format)
+	case "udp":
+		sink, err := NewUdpSink(*o.Address, format)
+		CheckErr(err)
+		return sink
+	default:
+		return NewConsoleSink(format)
+	}
+}
+
+func newLogWriter(fileName string, maxSize *int, maxFiles *int, maxAge *string, rotateEvery *string, compress *bool) *LogWriter {
+	w := &LogWriter{FileName: fileName, MaxAge: parseOptionalDuration(maxAge), RotateEvery: parseOptionalDuration(rotateEvery)}
+	if maxSize != nil {
+		w.MaxSize = *maxSize
+	}
+	if maxFiles != nil {
+		w.MaxFiles = *maxFiles
+	}
+	if compress != nil {
+		w.Compress = *compress
+	}
+	return w
 }
 
 type Loggers struct {
 	config     *LoggersConfig
 	output     io.Writer
 	logWriter  *LogWriter
+	pipeline   *LogPipeline
 	loggerMap  map[string]*log.Logger
 	nullLogger *log.Logger
 }
 
+// Config builds the pipeline of the loggers. Excludes are kept as rules, after the
+// configured ones; see excludeRules.
 func (o *Loggers) Config(config LoggersConfig) {
-	w := &LogWriter{FileName: *config.FileName, MaxFiles: *config.MaxFiles, MaxSize: *config.MaxSize, excludes: config.Excludes}
+	format := FormatText
+	if config.Format != nil {
+		format = *config.Format
+	}
+	level := LevelDebug
+	if config.Level != nil {
+		level, _ = ParseLevel(*config.Level)
+	}
+
+	rules := append(logRules(config.Rules), excludeRules(config.Excludes)...)
+	pipeline := NewLogPipeline(rules...)
+
+	w := newLogWriter(*config.FileName, config.MaxSize, config.MaxFiles, config.MaxAge, config.RotateEvery, config.Compress)
+	pipeline.AddSink(NewWriterSink(w, format), LevelDebug)
 	if *config.LogToConsole {
-		o.output = io.MultiWriter(w, os.Stdout)
-		log.SetOutput(o.output)
-	} else {
-		o.output = w
-		log.SetOutput(w)
+		pipeline.AddSink(NewConsoleSink(format), LevelDebug)
+	}
+	for i := range config.Sinks {
+		sinkLevel := LevelDebug
+		if config.Sinks[i].Level != nil {
+			sinkLevel, _ = ParseLevel(*config.Sinks[i].Level)
+		}
+		pipeline.AddSink(config.Sinks[i].sink(format), sinkLevel, logRules(config.Sinks[i].Rules)...)
 	}
 	o.logWriter = w
+	o.pipeline = pipeline
+	structuredLogger = NewPipelineLogger(pipeline, level)
+
+	o.output = &structuredWriter{logger: structuredLogger, level: LevelInfo}
+	log.SetOutput(o.output)
+	log.SetFlags(log.Lshortfile)
 
 	o.loggerMap = make(map[string]*log.Logger)
+	if config.Level != nil {
+		for _, name := range levelNames {
+			if l, _ := ParseLevel(name); structuredLogger.Enabled(l) {
+				o.loggerMap[name] = structuredLogger.WithTag(name).StdLogger(l)
+			}
+		}
+	}
 	for i := range config.Tags {
 		prefix := config.Tags[i]
-		o.loggerMap[prefix] = log.New(o.output, prefix+": ", log.Ldate|log.Ltime|log.Lshortfile)
+		if _, ok := o.loggerMap[prefix]; ok {
+			continue
+		}
+		l, _ := ParseLevel(prefix)
+		o.loggerMap[prefix] = structuredLogger.WithTag(prefix).StdLogger(l)
 	}
 	nullWriter := NullWriter{}
 	o.nullLogger = log.New(&nullWriter, "", 0)
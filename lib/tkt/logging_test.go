@@ -0,0 +1,47 @@
+package tkt
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLogWriterKeepsWritingWhenRotationFails(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	w := &LogWriter{FileName: name, MaxSize: 10}
+	defer w.Close()
+	if _, err := w.Write([]byte("first\n")); err != nil {
+		t.Fatal(err)
+	}
+	renameFile = func(string, string) error { return errors.New("rename refused") }
+	defer func() { renameFile = os.Rename }()
+	if err := w.Rotate(); err == nil {
+		t.Error("Rotate succeeded with a failing rename")
+	}
+	for _, line := range []string{"second\n", "third\n"} {
+		if n, err := w.Write([]byte(line)); err != nil || n != len(line) {
+			t.Fatalf("Write after a failed rotation: %d, %v", n, err)
+		}
+	}
+	if data, _ := os.ReadFile(name); string(data) != "first\nsecond\nthird\n" {
+		t.Errorf("log file %q", data)
+	}
+
+	renameFile = os.Rename
+	w.retryAt = time.Time{}
+	if _, err := w.Write([]byte("fourth\n")); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(name); string(data) != "fourth\n" {
+		t.Errorf("log file after rotation %q", data)
+	}
+	files, _ := w.Files()
+	if len(files) != 2 {
+		t.Fatalf("files %v, want one rotated and the current one", files)
+	}
+	if data, _ := os.ReadFile(files[0]); string(data) != "first\nsecond\nthird\n" {
+		t.Errorf("rotated file %q", data)
+	}
+}
@@ -0,0 +1,323 @@
+package tkt
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+type LogRecord struct {
+	Time    time.Time
+	Level   Level
+	Tag     string
+	Message string
+	Caller  string
+	Fields  []Field
+	pkg     *string
+	stack   *string
+}
+
+var tktPackage = reflect.TypeOf(LogRecord{}).PkgPath()
+
+var loggingFrames = []string{"log.", "runtime.", tktPackage + ".(*StructuredLogger)", tktPackage + ".(*structuredWriter)",
+	tktPackage + ".(*LogPipeline)", tktPackage + ".(*LogRecord)", tktPackage + ".(*LogRule)", tktPackage + ".allowedBy"}
+
+// CallerPackage returns the import path of the package that logged the record. It walks the
+// stack, so it is only valid while the record is dispatched and only computed when a rule
+// asks for it; frames are resolved once per program counter.
+func (o *LogRecord) CallerPackage() string {
+	if o.pkg != nil {
+		return *o.pkg
+	}
+	pkg := ""
+	pcs := make([]uintptr, 32)
+	for _, pc := range pcs[:runtime.Callers(2, pcs)] {
+		frame := resolveFrame(pc)
+		if !frame.logging {
+			pkg = frame.pkg
+			break
+		}
+	}
+	o.pkg = &pkg
+	return pkg
+}
+
+// Stack returns the call stack of the record as debug.Stack prints it, a function and its
+// file and line per frame, without the goroutine header and arguments. Like CallerPackage
+// it is only valid while the record is dispatched and only computed when a rule asks.
+func (o *LogRecord) Stack() string {
+	if o.stack != nil {
+		return *o.stack
+	}
+	b := strings.Builder{}
+	pcs := make([]uintptr, 64)
+	for _, pc := range pcs[:runtime.Callers(2, pcs)] {
+		b.WriteString(resolveFrame(pc).text)
+	}
+	stack := b.String()
+	o.stack = &stack
+	return stack
+}
+
+type frameInfo struct {
+	pkg     string
+	logging bool
+	text    string
+}
+
+var frameCache sync.Map
+
+func resolveFrame(pc uintptr) frameInfo {
+	if info, ok := frameCache.Load(pc); ok {
+		return info.(frameInfo)
+	}
+	info := frameInfo{logging: true}
+	if fn := runtime.FuncForPC(pc - 1); fn != nil {
+		info = frameInfo{pkg: functionPackage(fn.Name()), logging: isLoggingFrame(fn.Name())}
+	}
+	// A program counter stands for several frames when calls were inlined.
+	text := strings.Builder{}
+	frames := runtime.CallersFrames([]uintptr{pc})
+	for more := true; more; {
+		var frame runtime.Frame
+		frame, more = frames.Next()
+		fmt.Fprintf(&text, "%s()\n\t%s:%d\n", frame.Function, frame.File, frame.Line)
+	}
+	info.text = text.String()
+	frameCache.Store(pc, info)
+	return info
+}
+
+func isLoggingFrame(function string) bool {
+	for _, prefix := range loggingFrames {
+		if strings.HasPrefix(function, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func functionPackage(function string) string {
+	slash := strings.LastIndexByte(function, '/')
+	if dot := strings.IndexByte(function[slash+1:], '.'); dot >= 0 {
+		return function[:slash+1+dot]
+	}
+	return function
+}
+
+// LogRule matches records on every criterion it sets: tag, level, message pattern, caller
+// package, by import path or path prefix, and substrings of the call stack. A matching rule
+// includes the record, or drops it when Exclude is set.
+type LogRule struct {
+	Exclude  bool
+	Tags     []string
+	Levels   []Level
+	Message  *regexp.Regexp
+	Packages []string
+	// Stack matches records whose call stack, see LogRecord.Stack, contains one of the
+	// strings, as the legacy excludes did.
+	Stack []string
+}
+
+func (o *LogRule) Matches(record *LogRecord) bool {
+	if len(o.Tags) > 0 && !InStringList(record.Tag, o.Tags) {
+		return false
+	}
+	if len(o.Levels) > 0 && !inLevelList(record.Level, o.Levels) {
+		return false
+	}
+	if o.Message != nil && !o.Message.MatchString(record.Message) {
+		return false
+	}
+	if len(o.Packages) > 0 && !inPackages(record.CallerPackage(), o.Packages) {
+		return false
+	}
+	if len(o.Stack) > 0 && !containsAny(record.Stack(), o.Stack) {
+		return false
+	}
+	return true
+}
+
+func inPackages(pkg string, packages []string) bool {
+	for _, p := range packages {
+		if pkg == p || strings.HasPrefix(pkg, p+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func containsAny(s string, substrings []string) bool {
+	for _, sub := range substrings {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
+
+func inLevelList(level Level, levels []Level) bool {
+	for _, l := range levels {
+		if l == level {
+			return true
+		}
+	}
+	return false
+}
+
+// allowedBy applies rules in order; the first matching rule decides, and records no rule
+// matches are allowed.
+func allowedBy(rules []LogRule, record *LogRecord) bool {
+	for i := range rules {
+		if rules[i].Matches(record) {
+			return !rules[i].Exclude
+		}
+	}
+	return true
+}
+
+type LogSink interface {
+	WriteRecord(record *LogRecord) error
+}
+
+type sinkEntry struct {
+	sink  LogSink
+	level Level
+	rules []LogRule
+}
+
+// LogPipeline filters records with its rules and writes the ones allowed to every sink
+// whose level and own rules allow them too.
+type LogPipeline struct {
+	rules []LogRule
+	sinks []*sinkEntry
+	// level is the lowest level of the sinks; records below it are dropped before any rule
+	// walks the stack for them.
+	level Level
+}
+
+func NewLogPipeline(rules ...LogRule) *LogPipeline {
+	return &LogPipeline{rules: rules}
+}
+
+func (o *LogPipeline) AddSink(sink LogSink, level Level, rules ...LogRule) {
+	if len(o.sinks) == 0 || level < o.level {
+		o.level = level
+	}
+	o.sinks = append(o.sinks, &sinkEntry{sink: sink, level: level, rules: rules})
+}
+
+func (o *LogPipeline) Dispatch(record *LogRecord) {
+	if len(o.sinks) == 0 || record.Level < o.level || !allowedBy(o.rules, record) {
+		return
+	}
+	for _, s := range o.sinks {
+		if record.Level < s.level || !allowedBy(s.rules, record) {
+			continue
+		}
+		if err := s.sink.WriteRecord(record); err != nil {
+			fmt.Fprintf(os.Stderr, "log sink %T: %v\n", s.sink, err)
+		}
+	}
+}
+
+// Close closes the sinks that can be closed.
+func (o *LogPipeline) Close() error {
+	var result error
+	for _, s := range o.sinks {
+		if c, ok := s.sink.(io.Closer); ok {
+			if err := c.Close(); err != nil && result == nil {
+				result = err
+			}
+		}
+	}
+	return result
+}
+
+// WriterSink writes formatted records to a writer, such as a LogWriter or the console.
+type WriterSink struct {
+	writer io.Writer
+	format string
+	buf    bytes.Buffer
+	mux    sync.Mutex
+}
+
+func (o *WriterSink) WriteRecord(record *LogRecord) error {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	o.buf.Reset()
+	FormatRecord(&o.buf, o.format, record)
+	_, err := o.writer.Write(o.buf.Bytes())
+	return err
+}
+
+func (o *WriterSink) Close() error {
+	if c, ok := o.writer.(io.Closer); ok && o.writer != os.Stdout && o.writer != os.Stderr {
+		return c.Close()
+	}
+	return nil
+}
+
+func NewWriterSink(w io.Writer, format string) *WriterSink {
+	return &WriterSink{writer: w, format: format}
+}
+
+func NewConsoleSink(format string) *WriterSink {
+	return NewWriterSink(os.Stdout, format)
+}
+
+const syslogFacilityLocal0 = 16
+
+var syslogSeverities = map[Level]int{LevelDebug: 7, LevelInfo: 6, LevelWarn: 4, LevelError: 3}
+
+// UdpSink sends each record as an RFC 5424 syslog datagram, usually to a local listener.
+// Delivery is best effort: datagrams nobody receives are lost.
+type UdpSink struct {
+	conn     net.Conn
+	format   string
+	hostname string
+	appName  string
+	pid      int
+	buf      bytes.Buffer
+	mux      sync.Mutex
+}
+
+func (o *UdpSink) WriteRecord(record *LogRecord) error {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	o.buf.Reset()
+	fmt.Fprintf(&o.buf, "<%d>1 %s %s %s %d - - ", syslogFacilityLocal0*8+syslogSeverities[record.Level],
+		record.Time.Format(time.RFC3339Nano), o.hostname, o.appName, o.pid)
+	FormatRecord(&o.buf, o.format, record)
+	_, err := o.conn.Write(bytes.TrimRight(o.buf.Bytes(), "\n"))
+	return err
+}
+
+func (o *UdpSink) Close() error {
+	return o.conn.Close()
+}
+
+func NewUdpSink(address string, format string) (*UdpSink, error) {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "-"
+	}
+	appName := "-"
+	if len(os.Args) > 0 {
+		appName = strings.TrimSuffix(filepath.Base(os.Args[0]), ".exe")
+	}
+	return &UdpSink{conn: conn, format: format, hostname: hostname, appName: appName, pid: os.Getpid()}, nil
+}
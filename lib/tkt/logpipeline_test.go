@@ -0,0 +1,124 @@
+package tkt
+
+import (
+	"io"
+	"regexp"
+	"runtime/debug"
+	"testing"
+	"time"
+)
+
+type recordingSink struct {
+	messages []string
+}
+
+func (o *recordingSink) WriteRecord(record *LogRecord) error {
+	o.messages = append(o.messages, record.Message)
+	return nil
+}
+
+func excludedCaller(pipeline *LogPipeline, record *LogRecord) {
+	pipeline.Dispatch(record)
+}
+
+func TestExcludesMatchTheStack(t *testing.T) {
+	sink := &recordingSink{}
+	pipeline := NewLogPipeline(excludeRules([]string{"excludedCaller", "logpipeline_test.go:999"})...)
+	pipeline.AddSink(sink, LevelDebug)
+	excludedCaller(pipeline, &LogRecord{Level: LevelInfo, Tag: "info", Message: "dropped"})
+	excludedCaller(pipeline, &LogRecord{Level: LevelError, Tag: "info", Message: "error level"})
+	excludedCaller(pipeline, &LogRecord{Level: LevelInfo, Tag: "error", Message: "error tag"})
+	pipeline.Dispatch(&LogRecord{Level: LevelInfo, Tag: "info", Message: "kept"})
+	want := []string{"error level", "error tag", "kept"}
+	if len(sink.messages) != len(want) {
+		t.Fatalf("messages %v, want %v", sink.messages, want)
+	}
+	for i := range want {
+		if sink.messages[i] != want[i] {
+			t.Errorf("messages %v, want %v", sink.messages, want)
+		}
+	}
+}
+
+func TestExcludesMatchFilePaths(t *testing.T) {
+	sink := &recordingSink{}
+	pipeline := NewLogPipeline(excludeRules([]string{"lib/tkt/logpipeline_test.go"})...)
+	pipeline.AddSink(sink, LevelDebug)
+	pipeline.Dispatch(&LogRecord{Level: LevelInfo, Tag: "info", Message: "dropped"})
+	if len(sink.messages) != 0 {
+		t.Errorf("messages %v", sink.messages)
+	}
+}
+
+func TestStackCapturedOnlyForStackRules(t *testing.T) {
+	tests := []struct {
+		name   string
+		rules  []LogRule
+		record *LogRecord
+		stack  bool
+	}{
+		{"no rules", nil, &LogRecord{Level: LevelInfo, Tag: "info"}, false},
+		{"tag and package rules", []LogRule{{Exclude: true, Tags: []string{"sql"}}, {Packages: []string{"net/http"}}},
+			&LogRecord{Level: LevelInfo, Tag: "info"}, false},
+		{"stack rule after its other criteria", []LogRule{{Exclude: true, Tags: []string{"sql"}, Stack: []string{"net/http"}}},
+			&LogRecord{Level: LevelInfo, Tag: "info"}, false},
+		{"excludes on errors", excludeRules([]string{"net/http"}), &LogRecord{Level: LevelError, Tag: "info"}, false},
+		{"excludes below every sink", excludeRules([]string{"net/http"}), &LogRecord{Level: LevelDebug, Tag: "info"}, false},
+		{"excludes", excludeRules([]string{"net/http"}), &LogRecord{Level: LevelInfo, Tag: "info"}, true},
+	}
+	for _, test := range tests {
+		pipeline := NewLogPipeline(test.rules...)
+		pipeline.AddSink(&recordingSink{}, LevelWarn)
+		pipeline.AddSink(&recordingSink{}, LevelInfo)
+		pipeline.Dispatch(test.record)
+		if captured := test.record.stack != nil; captured != test.stack {
+			t.Errorf("%s: stack captured %t, want %t", test.name, captured, test.stack)
+		}
+	}
+}
+
+// BenchmarkStackExcludes compares the legacy excludes, which matched debug.Stack of every
+// record, with the pipeline without a stack rule and with the excludes as a stack rule.
+func BenchmarkStackExcludes(b *testing.B) {
+	excludes := []string{"net/http"}
+	b.Run("debug.Stack", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if containsAny(string(debug.Stack()), excludes) {
+				b.Fatal("excluded")
+			}
+		}
+	})
+	rules := map[string][]LogRule{"no stack rule": nil, "stack rule": excludeRules(excludes)}
+	for _, name := range []string{"no stack rule", "stack rule"} {
+		b.Run(name, func(b *testing.B) {
+			pipeline := NewLogPipeline(rules[name]...)
+			pipeline.AddSink(&recordingSink{}, LevelDebug)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				pipeline.Dispatch(&LogRecord{Level: LevelInfo, Tag: "info", Message: "request completed"})
+			}
+		})
+	}
+}
+
+func BenchmarkLogPipeline(b *testing.B) {
+	rules := map[string][]LogRule{
+		"none":     nil,
+		"tags":     {{Exclude: true, Tags: []string{"sql"}}, {Exclude: true, Message: regexp.MustCompile(`^heartbeat`)}},
+		"packages": {{Exclude: true, Packages: []string{"net/http"}}},
+		"excludes": excludeRules([]string{"net/http"}),
+	}
+	for _, name := range []string{"none", "tags", "packages", "excludes"} {
+		b.Run(name, func(b *testing.B) {
+			pipeline := NewLogPipeline(rules[name]...)
+			pipeline.AddSink(NewWriterSink(io.Discard, FormatText), LevelDebug)
+			pipeline.AddSink(NewWriterSink(io.Discard, FormatJson), LevelWarn)
+			fields := []Field{{Key: "requestId", Value: "0190f5c2"}, {Key: "status", Value: 200}}
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				pipeline.Dispatch(&LogRecord{Time: time.Now(), Level: LevelInfo, Tag: "info", Message: "request completed", Fields: fields})
+			}
+		})
+	}
+}
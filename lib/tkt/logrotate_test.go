@@ -0,0 +1,184 @@
+package tkt
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitFor polls until done returns true, since rotated files are compressed and removed
+// in the background.
+func waitFor(t *testing.T, what string, done func() bool) {
+	t.Helper()
+	for deadline := time.Now().Add(5 * time.Second); !done(); {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// settleMill waits for the background compression and retention of w to finish.
+func settleMill(w *LogWriter) {
+	time.Sleep(10 * time.Millisecond)
+	w.millMux.Lock()
+	w.millMux.Unlock()
+}
+
+func readLog(t *testing.T, name string) string {
+	t.Helper()
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var r io.Reader = f
+	if strings.HasSuffix(name, ".gz") {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r = zr
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func rotatedContents(t *testing.T, w *LogWriter) []string {
+	t.Helper()
+	files, err := w.rotatedFiles()
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := make([]string, len(files))
+	for i, f := range files {
+		result[i] = readLog(t, f.name)
+	}
+	return result
+}
+
+func writeLines(t *testing.T, w io.Writer, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLogWriterRotatesBySize(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	w := &LogWriter{FileName: name, MaxSize: 10}
+	defer settleMill(w)
+	defer w.Close()
+	writeLines(t, w, "aaaaaa\n", "bbbbbb\n", "cccccc\n")
+	if got, want := rotatedContents(t, w), []string{"bbbbbb\n", "aaaaaa\n"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rotated %q, want %q", got, want)
+	}
+	if got := readLog(t, name); got != "cccccc\n" {
+		t.Errorf("current %q", got)
+	}
+}
+
+func TestLogWriterContinuesExistingFile(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(name, []byte("before\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w := &LogWriter{FileName: name, MaxSize: 10}
+	defer settleMill(w)
+	defer w.Close()
+	writeLines(t, w, "after\n")
+	if got, want := rotatedContents(t, w), []string{"before\n"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rotated %q, want %q", got, want)
+	}
+	if got := readLog(t, name); got != "after\n" {
+		t.Errorf("current %q", got)
+	}
+}
+
+func TestLogWriterRotatesByTime(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	w := &LogWriter{FileName: name, RotateEvery: time.Hour}
+	defer settleMill(w)
+	defer w.Close()
+	writeLines(t, w, "a\n", "b\n")
+	if files, _ := w.rotatedFiles(); len(files) != 0 {
+		t.Fatalf("rotated %v within the hour", files)
+	}
+	if !w.nextRotation.After(time.Now()) || w.nextRotation.Sub(w.nextRotation.Truncate(time.Hour)) != 0 {
+		t.Errorf("next rotation %v, want the next full hour", w.nextRotation)
+	}
+	w.nextRotation = time.Now().Add(-time.Second)
+	writeLines(t, w, "c\n")
+	if got, want := rotatedContents(t, w), []string{"a\nb\n"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rotated %q, want %q", got, want)
+	}
+	if !w.nextRotation.After(time.Now()) {
+		t.Errorf("next rotation %v not moved on", w.nextRotation)
+	}
+}
+
+func TestLogWriterCompressesAndKeepsMaxFiles(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "app.log")
+	w := &LogWriter{FileName: name, MaxFiles: 2, Compress: true}
+	defer settleMill(w)
+	defer w.Close()
+	for _, line := range []string{"one\n", "two\n", "three\n"} {
+		writeLines(t, w, line)
+		if err := w.Rotate(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitFor(t, "two compressed files", func() bool {
+		files, _ := w.rotatedFiles()
+		for _, f := range files {
+			if !strings.HasSuffix(f.name, ".gz") {
+				return false
+			}
+		}
+		return len(files) == 2
+	})
+	settleMill(w)
+	if got, want := rotatedContents(t, w), []string{"three\n", "two\n"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rotated %q, want %q", got, want)
+	}
+}
+
+func TestLogWriterRemovesExpiredFiles(t *testing.T) {
+	dir := t.TempDir()
+	name := filepath.Join(dir, "app.log")
+	old := name + "." + time.Now().Add(-48*time.Hour).Format(rotatedTimeFormat)
+	if err := os.WriteFile(old, []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	unrelated := filepath.Join(dir, "app.log.backup")
+	if err := os.WriteFile(unrelated, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	w := &LogWriter{FileName: name, MaxAge: 24 * time.Hour}
+	defer settleMill(w)
+	defer w.Close()
+	writeLines(t, w, "new\n")
+	if err := w.Rotate(); err != nil {
+		t.Fatal(err)
+	}
+	waitFor(t, "the expired file to be removed", func() bool {
+		_, err := os.Stat(old)
+		return os.IsNotExist(err)
+	})
+	if got, want := rotatedContents(t, w), []string{"new\n"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("rotated %q, want %q", got, want)
+	}
+	if _, err := os.Stat(unrelated); err != nil {
+		t.Errorf("unrelated file: %v", err)
+	}
+}
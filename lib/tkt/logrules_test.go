@@ -0,0 +1,152 @@
+package tkt
+
+import (
+	"bytes"
+	"net"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+type messageSink struct {
+	messages []string
+	closed   bool
+}
+
+func (o *messageSink) WriteRecord(record *LogRecord) error {
+	o.messages = append(o.messages, record.Message)
+	return nil
+}
+
+func (o *messageSink) Close() error {
+	o.closed = true
+	return nil
+}
+
+func dispatchAll(pipeline *LogPipeline, records []*LogRecord) {
+	for _, r := range records {
+		pipeline.Dispatch(r)
+	}
+}
+
+var ruleRecords = []*LogRecord{
+	{Level: LevelDebug, Tag: "sql", Message: "select 1"},
+	{Level: LevelInfo, Tag: "info", Message: "heartbeat ok"},
+	{Level: LevelWarn, Tag: "info", Message: "slow request"},
+	{Level: LevelError, Tag: "sql", Message: "deadlock"},
+}
+
+func TestLogRules(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []LogRule
+		want  []string
+	}{
+		{"no rules", nil, []string{"select 1", "heartbeat ok", "slow request", "deadlock"}},
+		{"tag", []LogRule{{Exclude: true, Tags: []string{"sql"}}}, []string{"heartbeat ok", "slow request"}},
+		{"level", []LogRule{{Exclude: true, Levels: []Level{LevelDebug, LevelInfo}}}, []string{"slow request", "deadlock"}},
+		{"message", []LogRule{{Exclude: true, Message: regexp.MustCompile(`^heartbeat`)}}, []string{"select 1", "slow request", "deadlock"}},
+		{"every criterion", []LogRule{{Exclude: true, Tags: []string{"sql"}, Levels: []Level{LevelDebug}}}, []string{"heartbeat ok", "slow request", "deadlock"}},
+		{"first match decides", []LogRule{{Levels: []Level{LevelError}}, {Exclude: true, Tags: []string{"sql"}}}, []string{"heartbeat ok", "slow request", "deadlock"}},
+		{"package", []LogRule{{Exclude: true, Packages: []string{"json-schema-validation/lib"}}}, nil},
+		{"other package", []LogRule{{Exclude: true, Packages: []string{"json-schema-validation/li"}}}, []string{"select 1", "heartbeat ok", "slow request", "deadlock"}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sink := &messageSink{}
+			pipeline := NewLogPipeline(test.rules...)
+			pipeline.AddSink(sink, LevelDebug)
+			dispatchAll(pipeline, ruleRecords)
+			if !reflect.DeepEqual(sink.messages, test.want) {
+				t.Errorf("messages %q, want %q", sink.messages, test.want)
+			}
+		})
+	}
+}
+
+func TestLogSinkLevelsAndRules(t *testing.T) {
+	all, warnings, noSql := &messageSink{}, &messageSink{}, &messageSink{}
+	pipeline := NewLogPipeline(LogRule{Exclude: true, Message: regexp.MustCompile(`^heartbeat`)})
+	pipeline.AddSink(all, LevelDebug)
+	pipeline.AddSink(warnings, LevelWarn)
+	pipeline.AddSink(noSql, LevelDebug, LogRule{Exclude: true, Tags: []string{"sql"}})
+	dispatchAll(pipeline, ruleRecords)
+	for _, c := range []struct {
+		sink *messageSink
+		want []string
+	}{
+		{all, []string{"select 1", "slow request", "deadlock"}},
+		{warnings, []string{"slow request", "deadlock"}},
+		{noSql, []string{"slow request"}},
+	} {
+		if !reflect.DeepEqual(c.sink.messages, c.want) {
+			t.Errorf("messages %q, want %q", c.sink.messages, c.want)
+		}
+	}
+	if err := pipeline.Close(); err != nil || !all.closed || !warnings.closed {
+		t.Errorf("Close: %v, closed %t %t", err, all.closed, warnings.closed)
+	}
+}
+
+func TestLogRuleConfig(t *testing.T) {
+	exclude, message := "exclude", "^heart"
+	config := LogRuleConfig{Action: &exclude, Tags: []string{"sql"}, Levels: []string{"warning", "error"}, Message: &message, Packages: []string{"net/http"}}
+	config.Validate()
+	rule := config.Rule()
+	if !rule.Exclude || !reflect.DeepEqual(rule.Tags, []string{"sql"}) || !reflect.DeepEqual(rule.Levels, []Level{LevelWarn, LevelError}) ||
+		rule.Message.String() != "^heart" || !reflect.DeepEqual(rule.Packages, []string{"net/http"}) {
+		t.Errorf("rule %+v", rule)
+	}
+	if (&LogRuleConfig{}).Rule().Exclude {
+		t.Error("a rule without action excludes")
+	}
+	invalid := "drop"
+	defer func() {
+		if recover() == nil {
+			t.Error("Validate accepted action drop")
+		}
+	}()
+	(&LogRuleConfig{Action: &invalid}).Validate()
+}
+
+func TestUdpSink(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	sink, err := NewUdpSink(conn.LocalAddr().String(), FormatLogfmt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close()
+	at := time.Date(2024, 1, 31, 10, 0, 0, 0, time.UTC)
+	if err := sink.WriteRecord(&LogRecord{Time: at, Level: LevelWarn, Message: "slow"}); err != nil {
+		t.Fatal(err)
+	}
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 1024)
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	datagram := string(buf[:n])
+	if !strings.HasPrefix(datagram, "<132>1 2024-01-31T10:00:00Z ") || !strings.HasSuffix(datagram, " - - time=2024-01-31T10:00:00Z level=warn msg=slow") {
+		t.Errorf("datagram %q", datagram)
+	}
+}
+
+func TestWriterSinkFormats(t *testing.T) {
+	buf := &bytes.Buffer{}
+	sink := NewWriterSink(buf, FormatJson)
+	at := time.Date(2024, 1, 31, 10, 0, 0, 0, time.UTC)
+	if err := sink.WriteRecord(&LogRecord{Time: at, Level: LevelInfo, Tag: "audit", Message: "saved", Fields: []Field{{"id", 7}}}); err != nil {
+		t.Fatal(err)
+	}
+	want := `{"time":"2024-01-31T10:00:00Z","level":"info","msg":"saved","tag":"audit","id":7}` + "\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,269 @@
+package tkt
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+var jsonRawMessageType = reflect.TypeOf(json.RawMessage{})
+var valuerType = reflect.TypeOf((*driver.Valuer)(nil)).Elem()
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+var mappings sync.Map
+
+// structMapping is the list of columns of a struct type, shared by the select, insert,
+// update and scan helpers so that they always agree on count and order.
+//
+// Embedded and nested structs, by value or by pointer, contribute their own fields, and a
+// column name given twice, regardless of case, is rejected: tag one of the fields with
+// another name.
+// Fields tagged sql:"-" and unexported fields are ignored. Slices other than []byte, maps,
+// json.RawMessage and fields tagged with the json option, as in sql:"payload,json", are
+// stored as JSON. Pointers and sql.Null* types are nullable.
+type structMapping struct {
+	structType reflect.Type
+	columns    []columnMapping
+}
+
+type columnMapping struct {
+	name  string
+	field reflect.StructField
+	index []int
+	json  bool
+	// nullable is set for columns behind a struct pointer, which are NULL when it is nil.
+	nullable bool
+}
+
+// mappingOf returns the cached mapping of a struct type or a pointer to one.
+func mappingOf(structType reflect.Type) *structMapping {
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if mapping, ok := mappings.Load(structType); ok {
+		return mapping.(*structMapping)
+	}
+	if structType.Kind() != reflect.Struct {
+		panic("Not a struct: " + structType.String())
+	}
+	mapping := &structMapping{structType: structType}
+	mapping.addFields(structType, nil, false)
+	mapping.checkDuplicates()
+	actual, _ := mappings.LoadOrStore(structType, mapping)
+	return actual.(*structMapping)
+}
+
+func (o *structMapping) addFields(structType reflect.Type, index []int, nullable bool) {
+	for i := 0; i < structType.NumField(); i++ {
+		f := structType.Field(i)
+		name, options := parseSqlTag(f)
+		if name == "-" || (f.PkgPath != "" && !f.Anonymous) {
+			continue
+		}
+		path := append(append(make([]int, 0, len(index)+1), index...), i)
+		isJson := options["json"] || isJsonType(f.Type)
+		instanceType := f.Type
+		if instanceType.Kind() == reflect.Ptr {
+			instanceType = instanceType.Elem()
+		}
+		if !isJson && instanceType.Kind() == reflect.Struct && !isValueType(instanceType) {
+			o.addFields(instanceType, path, nullable || f.Type.Kind() == reflect.Ptr)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		o.columns = append(o.columns, columnMapping{name: name, field: f, index: path, json: isJson, nullable: nullable})
+	}
+}
+
+// checkDuplicates panics when two fields map to the same column, as a nested struct
+// repeating a name of its parent does.
+func (o *structMapping) checkDuplicates() {
+	seen := make(map[string]columnMapping)
+	for _, c := range o.columns {
+		key := strings.ToLower(c.name)
+		if other, ok := seen[key]; ok {
+			panic(fmt.Sprintf("Duplicate column %s in %s: fields %s and %s", c.name, o.structType.String(),
+				o.fieldPath(other.index), o.fieldPath(c.index)))
+		}
+		seen[key] = c
+	}
+}
+
+// fieldPath returns the dotted field names of an index, as in Home.Street.
+func (o *structMapping) fieldPath(index []int) string {
+	names := make([]string, 0, len(index))
+	t := o.structType
+	for _, i := range index {
+		f := t.Field(i)
+		names = append(names, f.Name)
+		t = f.Type
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+	}
+	return strings.Join(names, ".")
+}
+
+// parseSqlTag returns the column name and the options of the sql tag of a field.
+func parseSqlTag(field reflect.StructField) (string, map[string]bool) {
+	parts := strings.Split(field.Tag.Get("sql"), ",")
+	options := make(map[string]bool)
+	for _, option := range parts[1:] {
+		options[strings.TrimSpace(option)] = true
+	}
+	return strings.TrimSpace(parts[0]), options
+}
+
+func isJsonType(t reflect.Type) bool {
+	kind := t.Kind()
+	return kind == reflect.Map || (kind == reflect.Slice && t != byteArrayType)
+}
+
+// isValueType reports whether values of the type go to the driver as they are, rather than
+// being mapped field by field.
+func isValueType(t reflect.Type) bool {
+	return t == timeType || t.Implements(valuerType) || reflect.PtrTo(t).Implements(valuerType) ||
+		reflect.PtrTo(t).Implements(scannerType)
+}
+
+// columnNames returns the columns from offset on.
+func (o *structMapping) columnNames(offset int) []string {
+	names := make([]string, 0, len(o.columns))
+	for _, c := range o.columns[offset:] {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+// idColumn returns the position of the column of the field named Id, or else of the column
+// named id.
+func (o *structMapping) idColumn() (int, error) {
+	for i, c := range o.columns {
+		if len(c.index) == 1 && c.field.Name == "Id" {
+			return i, nil
+		}
+	}
+	for i, c := range o.columns {
+		if strings.EqualFold(c.name, "id") {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("Id field not found for %s", o.structType.Name())
+}
+
+// args returns the values of the columns from offset on as statement arguments.
+func (o *structMapping) args(object reflect.Value, offset int) ([]interface{}, error) {
+	if object.Kind() == reflect.Ptr {
+		object = object.Elem()
+	}
+	args := make([]interface{}, 0, len(o.columns)-offset)
+	for _, c := range o.columns[offset:] {
+		arg, err := c.value(object)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+	return args, nil
+}
+
+// resolve returns the field of the column in the object. Nil struct pointers on the way are
+// allocated, or else reported as no field.
+func (o *columnMapping) resolve(object reflect.Value, allocate bool) (reflect.Value, bool) {
+	v := object
+	for _, i := range o.index {
+		if v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !allocate {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(i)
+	}
+	return v, true
+}
+
+func (o *columnMapping) value(object reflect.Value) (interface{}, error) {
+	v, ok := o.resolve(object, false)
+	if !ok {
+		return nil, nil
+	}
+	if !o.json {
+		return v.Interface(), nil
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		if v.IsNil() {
+			return nil, nil
+		}
+	}
+	if v.Type() == jsonRawMessageType {
+		return string(v.Bytes()), nil
+	}
+	data, err := json.Marshal(v.Interface())
+	if err != nil {
+		return nil, fmt.Errorf("Column %s: %w", o.name, err)
+	}
+	return string(data), nil
+}
+
+// scanTargets returns the scan destinations of the first n columns.
+func (o *structMapping) scanTargets(n int) ([]interface{}, error) {
+	if n > len(o.columns) {
+		return nil, errors.New("Result set column count greater than struct field count")
+	}
+	targets := make([]interface{}, n)
+	for i := range targets {
+		c := &o.columns[i]
+		if c.json {
+			targets[i] = new([]byte)
+		} else if c.nullable && c.field.Type.Kind() != reflect.Ptr {
+			targets[i] = reflect.New(reflect.PtrTo(c.field.Type)).Interface()
+		} else {
+			targets[i] = reflect.New(c.field.Type).Interface()
+		}
+	}
+	return targets, nil
+}
+
+// assign copies scanned targets into the object. Nil struct pointers on the way to a column
+// are only allocated for values other than NULL or zero.
+func (o *structMapping) assign(object reflect.Value, targets []interface{}) error {
+	for i, target := range targets {
+		c := &o.columns[i]
+		scanned := reflect.ValueOf(target).Elem()
+		if c.json || (c.nullable && c.field.Type.Kind() != reflect.Ptr) {
+			if scanned.IsNil() {
+				continue
+			}
+			if !c.json {
+				scanned = scanned.Elem()
+			}
+		}
+		v, ok := c.resolve(object, !scanned.IsZero())
+		if !ok {
+			continue
+		}
+		if !c.json {
+			v.Set(scanned)
+		} else if c.field.Type == jsonRawMessageType {
+			v.SetBytes(scanned.Bytes())
+		} else if err := json.Unmarshal(scanned.Bytes(), v.Addr().Interface()); err != nil {
+			return fmt.Errorf("Column %s: %w", c.name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+package tkt
+
+import (
+	"database/sql"
+	"encoding/json"
+	_ "modernc.org/sqlite"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+type Audited struct {
+	CreatedBy string
+	UpdatedAt *time.Time
+}
+
+type Address struct {
+	Street string
+	City   string
+}
+
+type Preferences struct {
+	Language string `json:"language"`
+}
+
+type Customer struct {
+	Id int64
+	Audited
+	Name    string
+	Ignored string `sql:"-"`
+	secret  string
+	Tags    []string
+	Attrs   map[string]int
+	Raw     json.RawMessage
+	Prefs   Preferences `sql:"prefs,json"`
+	Home    *Address
+	Note    sql.NullString
+	Photo   []byte
+}
+
+var customerColumns = []string{"Id", "CreatedBy", "UpdatedAt", "Name", "Tags", "Attrs", "Raw", "prefs", "Street", "City", "Note", "Photo"}
+
+func TestStructMappingColumns(t *testing.T) {
+	mapping := mappingOf(reflect.TypeOf(Customer{}))
+	if mappingOf(reflect.TypeOf(&Customer{})) != mapping {
+		t.Error("the mapping of a pointer is not the cached one")
+	}
+	if got := mapping.columnNames(0); !reflect.DeepEqual(got, customerColumns) {
+		t.Fatalf("columns %q, want %q", got, customerColumns)
+	}
+	jsonColumns := make([]string, 0)
+	nullable := make([]string, 0)
+	for _, c := range mapping.columns {
+		if c.json {
+			jsonColumns = append(jsonColumns, c.name)
+		}
+		if c.nullable {
+			nullable = append(nullable, c.name)
+		}
+	}
+	if want := []string{"Tags", "Attrs", "Raw", "prefs"}; !reflect.DeepEqual(jsonColumns, want) {
+		t.Errorf("json columns %q, want %q", jsonColumns, want)
+	}
+	if want := []string{"Street", "City"}; !reflect.DeepEqual(nullable, want) {
+		t.Errorf("columns behind a pointer %q, want %q", nullable, want)
+	}
+
+	n := len(customerColumns)
+	d := SqliteDialect{}
+	if got := strings.Count(ForSelectDialect(d, Customer{}, 0), `"`); got != 2*n {
+		t.Errorf("select lists %d columns, want %d", got/2, n)
+	}
+	if got := strings.Count(ForInsertDialect(d, Customer{}, 1), "?"); got != n-1 {
+		t.Errorf("insert has %d placeholders, want %d", got, n-1)
+	}
+	if got := strings.Count(ForUpdateDialect(d, Customer{}, 1, 2), "?"); got != n-1 {
+		t.Errorf("update has %d placeholders, want %d", got, n-1)
+	}
+	args, err := structArgs(Customer{}, 1)
+	if err != nil || len(args) != n-1 {
+		t.Errorf("%d arguments, %v, want %d", len(args), err, n-1)
+	}
+	if targets, err := mapping.scanTargets(n); err != nil || len(targets) != n {
+		t.Errorf("%d scan targets, %v", len(targets), err)
+	}
+	if _, err := mapping.scanTargets(n + 1); err == nil {
+		t.Error("more columns than fields accepted")
+	}
+}
+
+func TestStructMappingArgs(t *testing.T) {
+	empty, err := structArgs(Customer{Id: 1}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, name := range customerColumns {
+		switch name {
+		case "Tags", "Attrs", "Raw", "Street", "City":
+			if empty[i] != nil {
+				t.Errorf("%s: got %#v, want NULL", name, empty[i])
+			}
+		}
+	}
+	full, err := structArgs(Customer{Tags: []string{"a", "b"}, Attrs: map[string]int{"x": 1}, Raw: json.RawMessage(`{"k": [1]}`),
+		Prefs: Preferences{"es"}, Home: &Address{"Main St", "Springfield"}}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"Tags": `["a","b"]`, "Attrs": `{"x":1}`, "Raw": `{"k": [1]}`, "prefs": `{"language":"es"}`,
+		"Street": "Main St", "City": "Springfield"}
+	for i, name := range customerColumns {
+		if w, ok := want[name]; ok && full[i] != w {
+			t.Errorf("%s: got %#v, want %#v", name, full[i], w)
+		}
+	}
+	if _, err := structArgs(struct{ Bad map[string]interface{} }{map[string]interface{}{"f": func() {}}}, 0); err == nil {
+		t.Error("a value JSON cannot encode was accepted")
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("the mapping of a string was built")
+			}
+		}()
+		mappingOf(reflect.TypeOf(""))
+	}()
+}
+
+type Branch struct {
+	Name     string
+	Customer *Customer
+	Billing  Address
+	Shipping Address `sql:"-"`
+}
+
+type Retagged struct {
+	Id  int64
+	Key int64 `sql:"id"`
+}
+
+func TestStructMappingRejectsDuplicateColumns(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		want  string
+	}{
+		{Branch{}, "Duplicate column Name in tkt.Branch: fields Name and Customer.Name"},
+		{struct {
+			Home Address
+			Work *Address
+		}{}, "Duplicate column Street in struct { Home tkt.Address; Work *tkt.Address }: fields Home.Street and Work.Street"},
+		{Retagged{}, "Duplicate column id in tkt.Retagged: fields Id and Key"},
+	}
+	for _, test := range tests {
+		func() {
+			defer func() {
+				if r := recover(); r != test.want {
+					t.Errorf("got %v, want %s", r, test.want)
+				}
+			}()
+			mappingOf(reflect.TypeOf(test.value))
+		}()
+	}
+	renamed := struct {
+		Home Address
+		Work struct {
+			Street string `sql:"WorkStreet"`
+			City   string `sql:"WorkCity"`
+		}
+	}{}
+	if got := mappingOf(reflect.TypeOf(renamed)).columnNames(0); !reflect.DeepEqual(got, []string{"Street", "City", "WorkStreet", "WorkCity"}) {
+		t.Errorf("columns %q", got)
+	}
+}
+
+func TestStructMappingRoundTrip(t *testing.T) {
+	defer ClosePools()
+	driver := "sqlite"
+	datasource := "file:" + filepath.Join(t.TempDir(), "mapping.db")
+	config := DatabaseConfig{DatabaseDriver: &driver, DatasourceName: &datasource}
+	updated := time.Date(2024, 5, 17, 10, 30, 0, 0, time.UTC)
+	full := Customer{Id: 1, Audited: Audited{"admin", &updated}, Name: "Jane", Ignored: "x", secret: "y",
+		Tags: []string{"a", "b"}, Attrs: map[string]int{"x": 1}, Raw: json.RawMessage(`{"k":[1]}`), Prefs: Preferences{"es"},
+		Home: &Address{"Main St", "Springfield"}, Note: sql.NullString{String: "vip", Valid: true}, Photo: []byte{1, 2}}
+	bare := Customer{Id: 2, Name: "John"}
+
+	got := ExecuteTransactional(config, func(txCtx *TxCtx, args ...interface{}) interface{} {
+		txCtx.ExecSql(`create table Customer (Id integer primary key, CreatedBy text, UpdatedAt timestamp, Name text, Tags text,
+			Attrs text, Raw text, prefs text, Street text, City text, Note text, Photo blob)`)
+		txCtx.InsertEntity("main", full, false)
+		txCtx.InsertEntity("main", bare, false)
+		return txCtx.QueryStruct(Customer{}, "select "+ForSelectDialect(txCtx.Dialect(), Customer{}, 0)+" from Customer order by Id")
+	}).([]Customer)
+	if len(got) != 2 {
+		t.Fatalf("got %+v", got)
+	}
+	if got[0].UpdatedAt == nil || !got[0].UpdatedAt.Equal(updated) {
+		t.Errorf("UpdatedAt %v, want %v", got[0].UpdatedAt, updated)
+	}
+	got[0].UpdatedAt = full.UpdatedAt
+	full.Ignored, full.secret = "", ""
+	if !reflect.DeepEqual(got[0], full) {
+		t.Errorf("got %+v, want %+v", got[0], full)
+	}
+	if !reflect.DeepEqual(got[1], bare) {
+		t.Errorf("got %+v, want %+v", got[1], bare)
+	}
+}
@@ -0,0 +1,316 @@
+package tkt
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Migration is a versioned change of a database schema. Up and Down are SQL scripts, Down
+// being empty when the migration cannot be rolled back.
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+type MigrationStatus struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt time.Time
+	// Modified tells that the up script changed since it was applied.
+	Modified bool
+	// Missing tells that the migration was applied but its files are gone.
+	Missing bool
+}
+
+var migrationFileRegexp = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// LoadMigrations reads the migrations of a directory, usually embedded, from files named
+// <version>_<name>.up.sql and <version>_<name>.down.sql, ordered by version.
+func LoadMigrations(fsys fs.FS, dir string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dir)
+	if err != nil {
+		return nil, err
+	}
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		m := migrationFileRegexp.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || m == nil {
+			continue
+		}
+		version, err := strconv.ParseInt(m[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration version %s: %w", entry.Name(), err)
+		}
+		data, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		migration, ok := byVersion[version]
+		if !ok {
+			migration = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = migration
+		} else if migration.Name != m[2] {
+			return nil, fmt.Errorf("migration %d is named both %s and %s", version, migration.Name, m[2])
+		}
+		if m[3] == "up" {
+			migration.Up = string(data)
+		} else {
+			migration.Down = string(data)
+		}
+	}
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, migration := range byVersion {
+		if migration.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", migration.Version, migration.Name)
+		}
+		sum := sha256.Sum256([]byte(migration.Up))
+		migration.Checksum = hex.EncodeToString(sum[:])
+		migrations = append(migrations, *migration)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// Migrator applies migrations to a database and records them, with the checksum of their
+// up script, in a table of its own. On Postgres, runs are serialized across processes with
+// an advisory lock derived from the table name. Scripts may refer to variables as ${name}.
+type Migrator struct {
+	db         *sql.DB
+	dialect    Dialect
+	table      string
+	migrations []Migration
+	variables  map[string]string
+}
+
+type appliedMigration struct {
+	Version   int64
+	Name      string
+	Checksum  string
+	AppliedAt time.Time
+}
+
+// SetDialect sets the dialect of the database, Postgres by default.
+func (o *Migrator) SetDialect(dialect Dialect) *Migrator {
+	o.dialect = dialect
+	return o
+}
+
+func (o *Migrator) SetVariables(variables map[string]string) *Migrator {
+	o.variables = variables
+	return o
+}
+
+// Up applies, in order and each in its own transaction, the pending migrations up to the
+// target version, all of them when target is 0, and returns them.
+func (o *Migrator) Up(ctx context.Context, target int64) ([]Migration, error) {
+	var done []Migration
+	err := o.locked(ctx, func(conn *sql.Conn, applied map[int64]appliedMigration) error {
+		if err := o.checkModified(applied); err != nil {
+			return err
+		}
+		for _, migration := range o.migrations {
+			if target > 0 && migration.Version > target {
+				break
+			}
+			if _, ok := applied[migration.Version]; ok {
+				continue
+			}
+			err := o.run(ctx, conn, migration.Up, "insert into "+o.quote(o.table)+" ("+o.quote("Version")+", "+o.quote("Name")+", "+o.quote("Checksum")+", "+
+				o.quote("AppliedAt")+") values ("+o.dialect.Placeholder(1)+", "+o.dialect.Placeholder(2)+", "+
+				o.dialect.Placeholder(3)+", "+o.dialect.Placeholder(4)+")",
+				migration.Version, migration.Name, migration.Checksum, time.Now().UTC())
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down rolls back the given number of applied migrations, latest first, and returns them.
+func (o *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := o.locked(ctx, func(conn *sql.Conn, applied map[int64]appliedMigration) error {
+		if err := o.checkModified(applied); err != nil {
+			return err
+		}
+		for i := len(o.migrations) - 1; i >= 0 && len(done) < steps; i-- {
+			migration := o.migrations[i]
+			if _, ok := applied[migration.Version]; !ok {
+				continue
+			}
+			if migration.Down == "" {
+				return fmt.Errorf("migration %d_%s has no down script", migration.Version, migration.Name)
+			}
+			err := o.run(ctx, conn, migration.Down, "delete from "+o.quote(o.table)+" where "+o.quote("Version")+" = "+o.dialect.Placeholder(1), migration.Version)
+			if err != nil {
+				return fmt.Errorf("migration %d_%s: %w", migration.Version, migration.Name, err)
+			}
+			done = append(done, migration)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Status returns the known and applied migrations, ordered by version. It only reads,
+// neither taking the lock nor creating the migrations table, all migrations being pending
+// when the table is missing.
+func (o *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	conn, err := o.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	var exists bool
+	if err := conn.QueryRowContext(ctx, o.dialect.TableExistsSql(o.table)).Scan(&exists); err != nil {
+		return nil, err
+	}
+	applied := make(map[int64]appliedMigration)
+	if exists {
+		if applied, err = o.applied(ctx, conn); err != nil {
+			return nil, err
+		}
+	}
+	var result []MigrationStatus
+	known := make(map[int64]bool)
+	for _, migration := range o.migrations {
+		known[migration.Version] = true
+		status := MigrationStatus{Version: migration.Version, Name: migration.Name}
+		if row, ok := applied[migration.Version]; ok {
+			status.Applied, status.AppliedAt, status.Modified = true, row.AppliedAt, row.Checksum != migration.Checksum
+		}
+		result = append(result, status)
+	}
+	for version, row := range applied {
+		if !known[version] {
+			result = append(result, MigrationStatus{Version: version, Name: row.Name, Applied: true, AppliedAt: row.AppliedAt, Missing: true})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Version < result[j].Version
+	})
+	return result, nil
+}
+
+// locked runs the delegate holding the advisory lock on a dedicated connection, once the
+// migrations table is created.
+func (o *Migrator) locked(ctx context.Context, delegate func(conn *sql.Conn, applied map[int64]appliedMigration) error) error {
+	conn, err := o.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if lock, unlock := o.dialect.LockSql(); lock != "" {
+		h := fnv.New64a()
+		h.Write([]byte(o.table))
+		key := int64(h.Sum64())
+		if _, err := conn.ExecContext(ctx, lock, key); err != nil {
+			return err
+		}
+		defer conn.ExecContext(context.Background(), unlock, key)
+	}
+	if err := o.createTable(ctx, conn); err != nil {
+		return err
+	}
+	applied, err := o.applied(ctx, conn)
+	if err != nil {
+		return err
+	}
+	return delegate(conn, applied)
+}
+
+// checkModified fails when the up script of an applied migration changed since.
+func (o *Migrator) checkModified(applied map[int64]appliedMigration) error {
+	for _, migration := range o.migrations {
+		if row, ok := applied[migration.Version]; ok && row.Checksum != migration.Checksum {
+			return fmt.Errorf("migration %d_%s was modified after being applied", migration.Version, migration.Name)
+		}
+	}
+	return nil
+}
+
+func (o *Migrator) createTable(ctx context.Context, conn *sql.Conn) error {
+	if i := strings.LastIndexByte(o.table, '.'); i > 0 && o.dialect.SupportsSchemas() {
+		if _, err := conn.ExecContext(ctx, "create schema if not exists "+o.quote(o.table[:i])); err != nil {
+			return err
+		}
+	}
+	_, err := conn.ExecContext(ctx, `create table if not exists `+o.quote(o.table)+` (
+	`+o.quote("Version")+` bigint primary key,
+	`+o.quote("Name")+` text not null,
+	`+o.quote("Checksum")+` text not null,
+	`+o.quote("AppliedAt")+` `+o.dialect.TimestampType()+` not null
+)`)
+	return err
+}
+
+func (o *Migrator) applied(ctx context.Context, conn *sql.Conn) (map[int64]appliedMigration, error) {
+	rows, err := conn.QueryContext(ctx, "select "+o.quote("Version")+", "+o.quote("Name")+", "+o.quote("Checksum")+", "+
+		o.quote("AppliedAt")+" from "+o.quote(o.table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	applied := make(map[int64]appliedMigration)
+	for rows.Next() {
+		row := appliedMigration{}
+		if err := rows.Scan(&row.Version, &row.Name, &row.Checksum, &row.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied[row.Version] = row
+	}
+	return applied, rows.Err()
+}
+
+// run executes a script and the statement recording it in one transaction.
+func (o *Migrator) run(ctx context.Context, conn *sql.Conn, script string, record string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := tx.ExecContext(ctx, o.expand(script)); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (o *Migrator) quote(name string) string {
+	return o.dialect.QuoteIdentifier(name)
+}
+
+func (o *Migrator) expand(script string) string {
+	for name, value := range o.variables {
+		script = strings.ReplaceAll(script, "${"+name+"}", value)
+	}
+	return script
+}
+
+// NewMigrator returns a migrator recording the migrations in table, which may be
+// qualified by a schema, created when missing if the dialect has schemas.
+func NewMigrator(db *sql.DB, table string, migrations []Migration) *Migrator {
+	return &Migrator{db: db, dialect: PostgresDialect{}, table: table, migrations: migrations}
+}
@@ -0,0 +1,89 @@
+package tkt
+
+import (
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+func TestLoadMigrations(t *testing.T) {
+	fsys := fstest.MapFS{
+		"db/0010_add_index.up.sql":    {Data: []byte("create index i on t (a)")},
+		"db/0002_create_t.up.sql":     {Data: []byte("create table t (a int)")},
+		"db/0002_create_t.down.sql":   {Data: []byte("drop table t")},
+		"db/0010_add_index.down.sql":  {Data: []byte("drop index i")},
+		"db/0011_seed.up.sql":         {Data: []byte("insert into t values (1)")},
+		"db/README.md":                {Data: []byte("not a migration")},
+		"db/0003_notes.up.txt":        {Data: []byte("not a migration either")},
+		"db/0004_old.sql/placeholder": {Data: []byte("a directory")},
+	}
+	migrations, err := LoadMigrations(fsys, "db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make([]string, 0)
+	for _, m := range migrations {
+		got = append(got, m.Name+" "+m.Up+" / "+m.Down)
+	}
+	want := []string{
+		"create_t create table t (a int) / drop table t",
+		"add_index create index i on t (a) / drop index i",
+		"seed insert into t values (1) / ",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if migrations[0].Version != 2 || migrations[1].Version != 10 || migrations[2].Version != 11 {
+		t.Errorf("versions %d %d %d", migrations[0].Version, migrations[1].Version, migrations[2].Version)
+	}
+	// sha256 of the up script
+	if migrations[0].Checksum != "b22c91af1e06d3d324e7e6d1206512f884fd38534dc37cab59c70332d0976c9c" {
+		t.Errorf("checksum %s", migrations[0].Checksum)
+	}
+	if migrations[0].Checksum == migrations[2].Checksum {
+		t.Error("different scripts have the same checksum")
+	}
+}
+
+func TestLoadMigrationsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		fsys fstest.MapFS
+		want string
+	}{
+		{"no up script", fstest.MapFS{"db/0001_a.down.sql": {Data: []byte("drop table a")}}, "migration 1_a has no up script"},
+		{"two names", fstest.MapFS{
+			"db/0001_a.up.sql":   {Data: []byte("create table a (x int)")},
+			"db/0001_b.down.sql": {Data: []byte("drop table b")},
+		}, "migration 1 is named both"},
+		{"no directory", fstest.MapFS{}, "open db"},
+	}
+	for _, test := range tests {
+		_, err := LoadMigrations(test.fsys, "db")
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: got %v, want %q", test.name, err, test.want)
+		}
+	}
+}
+
+func TestMigratorChecksAndExpands(t *testing.T) {
+	migrations := []Migration{
+		{Version: 1, Name: "a", Up: "create table ${schema}.a (x int)", Checksum: "one"},
+		{Version: 2, Name: "b", Up: "create table ${schema}.b (${column} int)", Checksum: "two"},
+	}
+	migrator := NewMigrator(nil, "audit.migrations", migrations).SetVariables(map[string]string{"schema": "audit", "column": "y"})
+	if got := migrator.expand(migrations[1].Up); got != "create table audit.b (y int)" {
+		t.Errorf("expanded to %q", got)
+	}
+	if got := migrator.expand("select '${unknown}'"); got != "select '${unknown}'" {
+		t.Errorf("expanded an unknown variable to %q", got)
+	}
+	applied := map[int64]appliedMigration{1: {Version: 1, Name: "a", Checksum: "one"}, 3: {Version: 3, Name: "gone", Checksum: "three"}}
+	if err := migrator.checkModified(applied); err != nil {
+		t.Errorf("unmodified migrations rejected: %v", err)
+	}
+	applied[2] = appliedMigration{Version: 2, Name: "b", Checksum: "changed"}
+	if err := migrator.checkModified(applied); err == nil || err.Error() != "migration 2_b was modified after being applied" {
+		t.Errorf("got %v", err)
+	}
+}
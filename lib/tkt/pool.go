@@ -0,0 +1,113 @@
+package tkt
+
+import (
+	"database/sql"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// poolKey identifies a pool by the settings it was opened with; unset limits are -1.
+type poolKey struct {
+	driver          string
+	datasource      string
+	maxIdleConns    int
+	maxOpenConns    int
+	maxConnLifetime int
+}
+
+func newPoolKey(config DatabaseConfig) poolKey {
+	optional := func(v *int) int {
+		if v == nil {
+			return -1
+		}
+		return *v
+	}
+	return poolKey{driver: *config.DatabaseDriver, datasource: *config.DatasourceName,
+		maxIdleConns: optional(config.MaxIdleConns), maxOpenConns: optional(config.MaxOpenConns),
+		maxConnLifetime: optional(config.MaxConnLifetime)}
+}
+
+type PoolStats struct {
+	Driver     string
+	Datasource string
+	sql.DBStats
+}
+
+type poolRegistry struct {
+	mux   sync.Mutex
+	pools map[poolKey]*sql.DB
+}
+
+var pools = &poolRegistry{pools: make(map[poolKey]*sql.DB)}
+
+func (o *poolRegistry) resolve(config DatabaseConfig) (*sql.DB, error) {
+	key := newPoolKey(config)
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	db, ok := o.pools[key]
+	if !ok {
+		var err error
+		db, err = openDB(config)
+		if err != nil {
+			return nil, err
+		}
+		o.pools[key] = db
+	}
+	return db, nil
+}
+
+// SharedDB returns the pool of the configuration, opening it on first use. The pool lives
+// until ClosePools and must not be closed by the caller.
+func SharedDB(config DatabaseConfig) *sql.DB {
+	db, err := pools.resolve(config)
+	CheckErr(err)
+	return db
+}
+
+// ClosePools closes every shared pool, returning the first error.
+func ClosePools() error {
+	pools.mux.Lock()
+	defer pools.mux.Unlock()
+	var result error
+	for key, db := range pools.pools {
+		if err := db.Close(); err != nil && result == nil {
+			result = err
+		}
+		delete(pools.pools, key)
+	}
+	return result
+}
+
+// CurrentPoolStats returns the statistics of the shared pools, the passwords of their
+// datasources hidden.
+func CurrentPoolStats() []PoolStats {
+	pools.mux.Lock()
+	defer pools.mux.Unlock()
+	result := make([]PoolStats, 0, len(pools.pools))
+	for key, db := range pools.pools {
+		result = append(result, PoolStats{Driver: key.driver, Datasource: redactDatasource(key.datasource), DBStats: db.Stats()})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Driver != result[j].Driver {
+			return result[i].Driver < result[j].Driver
+		}
+		return result[i].Datasource < result[j].Datasource
+	})
+	return result
+}
+
+func PoolStatsHandler(w http.ResponseWriter, r *http.Request) {
+	JsonResponse(CurrentPoolStats(), w)
+}
+
+var passwordRegexp = regexp.MustCompile(`(?i)(password\s*=\s*)('[^']*'|\S+)`)
+
+func redactDatasource(datasource string) string {
+	if u, err := url.Parse(datasource); err == nil && u.Scheme != "" && u.User != nil {
+		return u.Redacted()
+	}
+	return passwordRegexp.ReplaceAllString(datasource, "${1}xxxxx")
+}
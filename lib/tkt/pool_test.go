@@ -0,0 +1,114 @@
+package tkt
+
+import (
+	"context"
+	"fmt"
+	_ "modernc.org/sqlite"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func sqliteConfig(t testing.TB, name string, maxOpenConns int) DatabaseConfig {
+	driver := "sqlite"
+	datasource := "file:" + filepath.Join(t.TempDir(), name)
+	return DatabaseConfig{DatabaseDriver: &driver, DatasourceName: &datasource, MaxOpenConns: &maxOpenConns}
+}
+
+// hammer runs f from n goroutines at once.
+func hammer(n int, f func(i int)) {
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			f(i)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+}
+
+func query(t *testing.T, config DatabaseConfig) {
+	_, err := ExecuteTransactionalContext(context.Background(), config, func(txCtx *TxCtx) (interface{}, error) {
+		var one int
+		_, err := txCtx.QuerySingletonContext(txCtx.Context(), "select 1", []interface{}{&one})
+		return one, err
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSharedDBSameKeyConcurrently(t *testing.T) {
+	defer ClosePools()
+	config := sqliteConfig(t, "shared.db", 3)
+	dbs := make([]interface{}, 64)
+	hammer(len(dbs), func(i int) {
+		dbs[i] = SharedDB(config)
+		query(t, config)
+	})
+	for i := range dbs {
+		if dbs[i] != dbs[0] {
+			t.Fatalf("get %d returned another pool", i)
+		}
+	}
+	stats := CurrentPoolStats()
+	if len(stats) != 1 {
+		t.Fatalf("%d pools, want 1", len(stats))
+	}
+	if stats[0].MaxOpenConnections != 3 || stats[0].OpenConnections > 3 || stats[0].InUse != 0 {
+		t.Errorf("connections not bounded or not released: %+v", stats[0].DBStats)
+	}
+}
+
+func TestSharedDBDifferentKeysConcurrently(t *testing.T) {
+	defer ClosePools()
+	configs := make([]DatabaseConfig, 4)
+	for i := range configs {
+		configs[i] = sqliteConfig(t, fmt.Sprintf("db%d.db", i), 2)
+	}
+	hammer(64, func(i int) {
+		config := configs[i%len(configs)]
+		SharedDB(config)
+		query(t, config)
+	})
+	stats := CurrentPoolStats()
+	if len(stats) != len(configs) {
+		t.Fatalf("%d pools, want %d", len(stats), len(configs))
+	}
+	for _, s := range stats {
+		if s.OpenConnections > 2 || s.InUse != 0 {
+			t.Errorf("%s: connections not bounded or not released: %+v", s.Datasource, s.DBStats)
+		}
+	}
+}
+
+func TestInMemorySequencesWithOneConnection(t *testing.T) {
+	defer ClosePools()
+	config := sqliteConfig(t, "sequences.db", 1)
+	manager := "inMemory"
+	config.SequenceManager = &manager
+	ExecuteTransactional(config, func(txCtx *TxCtx, args ...interface{}) interface{} {
+		txCtx.ExecSql("create table Item (id bigint primary key)")
+		txCtx.ExecSql("insert into Item (id) values (41)")
+		return nil
+	})
+	done := make(chan int64, 1)
+	go func() {
+		done <- ExecuteTransactional(config, func(txCtx *TxCtx, args ...interface{}) interface{} {
+			return *txCtx.NextId("Item")
+		}).(int64)
+	}()
+	select {
+	case id := <-done:
+		if id != 42 {
+			t.Errorf("id %d, want 42", id)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("the sequence waits for a second connection")
+	}
+}
@@ -0,0 +1,104 @@
+package tkt
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// Querier runs queries: *sql.DB, *sql.Tx, *sql.Conn and *TxCtx, which prepares each query
+// once per transaction.
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// Query returns the rows of the query mapped to T, a struct or a pointer to one. The query
+// selects a column for each mapped field of T, in order.
+func Query[T any](ctx context.Context, q Querier, query string, args ...interface{}) ([]T, error) {
+	result := make([]T, 0)
+	err := Iterate(ctx, q, query, func(row T) error {
+		result = append(result, row)
+		return nil
+	}, args...)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// FindOne returns the first row of the query mapped to T, and whether there was one.
+func FindOne[T any](ctx context.Context, q Querier, query string, args ...interface{}) (T, bool, error) {
+	var result T
+	found := false
+	err := Iterate(ctx, q, query, func(row T) error {
+		result = row
+		found = true
+		return errStopIteration
+	}, args...)
+	if err != nil && err != errStopIteration {
+		var zero T
+		return zero, false, err
+	}
+	return result, found, nil
+}
+
+// Iterate calls the callback with each row of the query mapped to T as it is read, without
+// holding the result set in memory. An error of the callback stops the iteration and is
+// returned.
+func Iterate[T any](ctx context.Context, q Querier, query string, callback func(row T) error, args ...interface{}) error {
+	r, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	rowType := reflect.TypeOf((*T)(nil)).Elem()
+	isPtr := rowType.Kind() == reflect.Ptr
+	objectType := rowType
+	if isPtr {
+		objectType = rowType.Elem()
+	}
+	cols, err := r.Columns()
+	if err != nil {
+		return err
+	}
+	if fields := len(mappingOf(objectType).columns); len(cols) != fields {
+		return fmt.Errorf("the query selects %d columns, but %s maps %d", len(cols), objectType.String(), fields)
+	}
+	return scanStructs(r, objectType, func(object reflect.Value) error {
+		if isPtr {
+			object = object.Addr()
+		}
+		return callback(object.Interface().(T))
+	})
+}
+
+var errStopIteration = errors.New("stop iteration")
+
+// scanStructs calls the callback with each row as a new addressable value of the struct
+// type.
+func scanStructs(r *sql.Rows, objectType reflect.Type, callback func(object reflect.Value) error) error {
+	mapping := mappingOf(objectType)
+	cols, err := r.Columns()
+	if err != nil {
+		return err
+	}
+	buffer, err := mapping.scanTargets(len(cols))
+	if err != nil {
+		return err
+	}
+	for r.Next() {
+		if err := r.Scan(buffer...); err != nil {
+			return err
+		}
+		object := reflect.New(objectType).Elem()
+		if err := mapping.assign(object, buffer); err != nil {
+			return err
+		}
+		if err := callback(object); err != nil {
+			return err
+		}
+	}
+	return r.Err()
+}
@@ -0,0 +1,115 @@
+package tkt
+
+import (
+	"context"
+	"errors"
+	_ "modernc.org/sqlite"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type Point struct {
+	Id    int64
+	Label string
+	Meta  map[string]string
+}
+
+func queryConfig(t *testing.T) DatabaseConfig {
+	driver := "sqlite"
+	datasource := "file:" + filepath.Join(t.TempDir(), "query.db")
+	t.Cleanup(func() { ClosePools() })
+	config := DatabaseConfig{DatabaseDriver: &driver, DatasourceName: &datasource}
+	ExecuteTransactional(config, func(txCtx *TxCtx, args ...interface{}) interface{} {
+		txCtx.ExecSql("create table Point (Id integer primary key, Label text, Meta text)")
+		txCtx.InsertEntities("main", []Point{{1, "a", map[string]string{"k": "v"}}, {2, "b", nil}, {3, "c", nil}}, false)
+		return nil
+	})
+	return config
+}
+
+func TestQuery(t *testing.T) {
+	config := queryConfig(t)
+	ctx := context.Background()
+	ExecuteTransactional(config, func(txCtx *TxCtx, args ...interface{}) interface{} {
+		for name, q := range map[string]Querier{"TxCtx": txCtx, "Tx": txCtx.Tx(), "DB": SharedDB(config)} {
+			points, err := Query[Point](ctx, q, "select Id, Label, Meta from Point where Id < ?1 order by Id", 3)
+			if want := []Point{{1, "a", map[string]string{"k": "v"}}, {2, "b", nil}}; err != nil || !reflect.DeepEqual(points, want) {
+				t.Errorf("%s: got %+v, %v", name, points, err)
+			}
+			pointers, err := Query[*Point](ctx, q, "select Id, Label, Meta from Point where Id = ?1", 2)
+			if err != nil || len(pointers) != 1 || !reflect.DeepEqual(*pointers[0], Point{2, "b", nil}) {
+				t.Errorf("%s: got %+v, %v", name, pointers, err)
+			}
+			none, err := Query[Point](ctx, q, "select Id, Label, Meta from Point where Id > 10")
+			if err != nil || none == nil || len(none) != 0 {
+				t.Errorf("%s: got %#v, %v, want an empty slice", name, none, err)
+			}
+		}
+		return nil
+	})
+}
+
+func TestFindOne(t *testing.T) {
+	config := queryConfig(t)
+	ctx := context.Background()
+	db := SharedDB(config)
+	point, found, err := FindOne[Point](ctx, db, "select Id, Label, Meta from Point order by Id desc")
+	if err != nil || !found || point.Id != 3 {
+		t.Errorf("got %+v, %v, %v", point, found, err)
+	}
+	pointer, found, err := FindOne[*Point](ctx, db, "select Id, Label, Meta from Point where Id = ?1", 1)
+	if err != nil || !found || pointer == nil || pointer.Meta["k"] != "v" {
+		t.Errorf("got %+v, %v, %v", pointer, found, err)
+	}
+	point, found, err = FindOne[Point](ctx, db, "select Id, Label, Meta from Point where Id = 10")
+	if err != nil || found || !reflect.DeepEqual(point, Point{}) {
+		t.Errorf("got %+v, %v, %v, want not found", point, found, err)
+	}
+	pointer, found, err = FindOne[*Point](ctx, db, "select Id, Label, Meta from Point where Id = 10")
+	if err != nil || found || pointer != nil {
+		t.Errorf("got %+v, %v, %v, want not found", pointer, found, err)
+	}
+	if _, _, err := FindOne[Point](ctx, db, "select Id, Label, Meta from Nowhere"); err == nil {
+		t.Error("a query error was not returned")
+	}
+}
+
+func TestIterate(t *testing.T) {
+	config := queryConfig(t)
+	ctx := context.Background()
+	db := SharedDB(config)
+	stop := errors.New("stop")
+	seen := make([]int64, 0)
+	err := Iterate(ctx, db, "select Id, Label, Meta from Point order by Id", func(row Point) error {
+		seen = append(seen, row.Id)
+		if row.Id == 2 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || !reflect.DeepEqual(seen, []int64{1, 2}) {
+		t.Errorf("got %v after %v", err, seen)
+	}
+	for query, want := range map[string]string{
+		"select Id, Label, Meta, 1 from Point": "the query selects 4 columns, but tkt.Point maps 3",
+		"select Id, Label from Point":          "the query selects 2 columns, but tkt.Point maps 3",
+	} {
+		called := false
+		err := Iterate(ctx, db, query, func(row Point) error {
+			called = true
+			return nil
+		})
+		if err == nil || err.Error() != want || called {
+			t.Errorf("%s: got %v, want %s", query, err, want)
+		}
+	}
+	if _, err := Query[*Point](ctx, db, "select Id from Point"); err == nil {
+		t.Error("fewer columns than fields accepted")
+	}
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := Iterate(canceled, db, "select Id, Label, Meta from Point", func(row Point) error { return nil }); !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v on a canceled context", err)
+	}
+}
@@ -0,0 +1,83 @@
+package tkt
+
+import (
+	_ "modernc.org/sqlite"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type Reserved struct {
+	Key    int64  `sql:"id"`
+	Where  string `sql:"where"`
+	Spaced string `sql:"two words"`
+	Quote  string `sql:"it\"s"`
+}
+
+type LateId struct {
+	Name string
+	Id   int64
+}
+
+func TestSqliteQuotesIdentifiers(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"select", `"select"`},
+		{`a"b`, `"a""b"`},
+		{"main.Reserved", `"main"."Reserved"`},
+		{"Group By", `"Group By"`},
+		{"año", `"año"`},
+	}
+	for _, test := range tests {
+		if got := (SqliteDialect{}).QuoteIdentifier(test.name); got != test.want {
+			t.Errorf("%q quoted as %s, want %s", test.name, got, test.want)
+		}
+	}
+	d := SqliteDialect{}
+	if got, want := ForSelectDialect(d, Reserved{}, 1, "r"), `"r"."where", "r"."two words", "r"."it""s"`; got != want {
+		t.Errorf("select %s, want %s", got, want)
+	}
+	if got, want := ForInsertDialect(d, Reserved{}, 0), `("id", "where", "two words", "it""s") values(?1, ?2, ?3, ?4)`; got != want {
+		t.Errorf("insert %s, want %s", got, want)
+	}
+	if got, want := ForUpdateDialect(d, Reserved{}, 1, 2), `"where" = ?2, "two words" = ?3, "it""s" = ?4`; got != want {
+		t.Errorf("update %s, want %s", got, want)
+	}
+}
+
+func TestValidateIdentifier(t *testing.T) {
+	for _, name := range []string{"a", "a.b", `a"b`, "año", "two words"} {
+		if err := ValidateIdentifier(name); err != nil {
+			t.Errorf("%q: %v", name, err)
+		}
+	}
+	for _, name := range []string{"", "a.", ".a", "a..b", "a\nb", "a\x00b", "\xff"} {
+		if err := ValidateIdentifier(name); err == nil || !strings.HasPrefix(err.Error(), "invalid identifier") {
+			t.Errorf("%q: got %v", name, err)
+		}
+	}
+}
+
+func TestEntitiesWithTaggedId(t *testing.T) {
+	defer ClosePools()
+	driver := "sqlite"
+	datasource := "file:" + filepath.Join(t.TempDir(), "reserved.db")
+	config := DatabaseConfig{DatabaseDriver: &driver, DatasourceName: &datasource}
+	ExecuteTransactional(config, func(txCtx *TxCtx, args ...interface{}) interface{} {
+		txCtx.ExecSql(`create table "Reserved" (id integer primary key, "where" text, "two words" text, "it""s" text)`)
+		txCtx.InsertEntity("main", Reserved{Key: 1, Where: "w", Spaced: "s", Quote: "q"}, false)
+		txCtx.InsertEntity("main", Reserved{Key: 2, Where: "x", Spaced: "y", Quote: "z"}, false)
+		txCtx.UpdateEntity("main", Reserved{Key: 1, Where: "w2", Spaced: "s2", Quote: "q2"})
+		txCtx.DeleteEntity("main", Reserved{Key: 2})
+		got := txCtx.QueryStruct(Reserved{}, `select id, "where", "two words", "it""s" from "Reserved"`).([]Reserved)
+		if len(got) != 1 || got[0] != (Reserved{Key: 1, Where: "w2", Spaced: "s2", Quote: "q2"}) {
+			t.Errorf("got %+v", got)
+		}
+		if _, err := txCtx.UpdateEntityContext(txCtx.Context(), "main", LateId{Name: "a", Id: 1}); err == nil {
+			t.Error("an update with the id after other fields was accepted")
+		}
+		return nil
+	})
+}
@@ -1,19 +1,39 @@
 package tkt
 
 import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
 	"strings"
+	"unicode/utf8"
 )
 
 const (
-	malformedOriginalError  = "Malformed original JSON"
-	malformedGeneratedError = "Malformed generated JSON"
-	malformedNodeError      = "Malformed input JSON node"
-	malformedFieldError     = "Malformed input JSON field"
-	malformedListError      = "Malformed input JSON list"
-	malformedTerminalError  = "Malformed input JSON terminal"
-	sanitizedSecret         = "**********"
-	maxStringFieldLength    = 200
+	malformedOriginalError = "Malformed original JSON"
+	malformedNodeError     = "Malformed input JSON node"
+	malformedFieldError    = "Malformed input JSON field"
+	malformedListError     = "Malformed input JSON list"
+	malformedTerminalError = "Malformed input JSON terminal"
+	sanitizedSecret        = "**********"
+	maxStringFieldLength   = 200
+)
+
+const (
+	SanitizeMask    = "mask"
+	SanitizePartial = "partial"
+	SanitizeHash    = "hash"
+	SanitizeDrop    = "drop"
+)
+
+var (
+	SsnPattern        = regexp.MustCompile(`\b\d{3}-\d{2}-\d{4}\b`)
+	CreditCardPattern = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
 )
 
 var secretKeys []string
@@ -28,90 +48,339 @@ func init() {
 	}
 }
 
+// AppendSecretKeys adds keys to the ones masked by the package level functions. Prefer a
+// Sanitizer configured with its own rules.
 func AppendSecretKeys(newKeys []string) {
 	secretKeys = append(secretKeys, newKeys...)
 }
 
-func SanitizeObject(obj interface{}) string {
+// SanitizeRule selects values by property name (Key, case insensitive), by JSON Pointer
+// (Path, whose segments may be globs, "**" matching any number of them) or by a pattern
+// on string and number values (Value). Key and Path rules act on the whole value, the first
+// matching one deciding; Value rules all act, on the matching text only, except drop which
+// removes the value.
+type SanitizeRule struct {
+	Key    string
+	Path   string
+	Value  *regexp.Regexp
+	Action string
+	path   []string
+}
+
+func (o *SanitizeRule) matches(pointer []string, key string) bool {
+	if o.Key != "" && !strings.EqualFold(o.Key, key) {
+		return false
+	}
+	if o.Path != "" && !matchPointer(o.path, pointer) {
+		return false
+	}
+	return o.Key != "" || o.Path != ""
+}
+
+func matchPointer(pattern []string, pointer []string) bool {
+	if len(pattern) == 0 {
+		return len(pointer) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(pointer); i++ {
+			if matchPointer(pattern[1:], pointer[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(pointer) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], pointer[0]); !ok {
+		return false
+	}
+	return matchPointer(pattern[1:], pointer[1:])
+}
+
+func splitPointer(pointer string) []string {
+	if pointer == "" || pointer == "/" {
+		return []string{}
+	}
+	tokens := strings.Split(strings.TrimPrefix(pointer, "/"), "/")
+	for i, t := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens
+}
+
+// Sanitizer redacts decoded JSON values with its rules and truncates long strings. Hashes
+// are HMAC-SHA256 when a hash key is set.
+type Sanitizer struct {
+	rules           []SanitizeRule
+	maxStringLength int
+	hashKey         []byte
+	indent          string
+}
+
+func (o *Sanitizer) AddRule(rule SanitizeRule) *Sanitizer {
+	if !InStringList(rule.Action, []string{SanitizeMask, SanitizePartial, SanitizeHash, SanitizeDrop}) {
+		panic("Invalid sanitize action " + rule.Action)
+	}
+	if rule.Key == "" && rule.Path == "" && rule.Value == nil {
+		panic("Sanitize rule without key, path or value")
+	}
+	rule.path = splitPointer(rule.Path)
+	o.rules = append(o.rules, rule)
+	return o
+}
+
+func (o *Sanitizer) MaskKeys(keys ...string) *Sanitizer {
+	for _, key := range keys {
+		o.AddRule(SanitizeRule{Key: key, Action: SanitizeMask})
+	}
+	return o
+}
+
+// SetMaxStringLength sets the length, in runes, above which strings are truncated; zero
+// disables truncation.
+func (o *Sanitizer) SetMaxStringLength(n int) *Sanitizer {
+	o.maxStringLength = n
+	return o
+}
+
+func (o *Sanitizer) SetHashKey(key []byte) *Sanitizer {
+	o.hashKey = key
+	return o
+}
+
+// SetIndent makes the JSON output indented with the given string per level; the empty
+// string, the default, writes it compact.
+func (o *Sanitizer) SetIndent(indent string) *Sanitizer {
+	o.indent = indent
+	return o
+}
+
+// Sanitize returns a sanitized copy of a value decoded from JSON.
+func (o *Sanitizer) Sanitize(value interface{}) interface{} {
+	v, _ := o.sanitize([]string{}, "", value)
+	return v
+}
+
+// SanitizeAt returns a sanitized copy of the value found at the given JSON Pointer tokens,
+// and false when a rule drops it. Key rules see the last token that is not an array index.
+func (o *Sanitizer) SanitizeAt(pointer []string, value interface{}) (interface{}, bool) {
+	key := ""
+	for i := len(pointer) - 1; i >= 0; i-- {
+		if _, err := strconv.Atoi(pointer[i]); err != nil {
+			key = pointer[i]
+			break
+		}
+	}
+	return o.sanitize(pointer, key, value)
+}
+
+// SanitizeJson returns the sanitized JSON text, with the keys in their original order and
+// the numbers as written. Malformed input gives a JSON string telling where it broke.
+func (o *Sanitizer) SanitizeJson(data []byte) string {
+	buf := &bytes.Buffer{}
+	if err := o.WriteJson(buf, data); err != nil {
+		return string(Marshal(err.Error()))
+	}
+	return buf.String()
+}
+
+func (o *Sanitizer) SanitizeObject(obj interface{}) string {
 	rawData, err := json.Marshal(obj)
 	if err != nil {
 		return malformedOriginalError
 	}
-	return SanitizeJson(rawData)
+	return o.SanitizeJson(rawData)
 }
 
-func SanitizeJson(data []byte) string {
-	obj := parseJsonField("", data)
-	sanitized, err := json.Marshal(obj)
-	if err != nil {
-		return malformedGeneratedError
+func (o *Sanitizer) sanitize(pointer []string, key string, value interface{}) (interface{}, bool) {
+	for i := range o.rules {
+		if o.rules[i].matches(pointer, key) {
+			return o.apply(o.rules[i].Action, value)
+		}
+	}
+	switch t := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			if s, keep := o.sanitize(append(pointer[:len(pointer):len(pointer)], k), k, v); keep {
+				result[k] = s
+			}
+		}
+		return result, true
+	case []interface{}:
+		result := make([]interface{}, 0, len(t))
+		for i, v := range t {
+			if s, keep := o.sanitize(append(pointer[:len(pointer):len(pointer)], fmt.Sprint(i)), key, v); keep {
+				result = append(result, s)
+			}
+		}
+		return result, true
+	case string:
+		return o.sanitizeText(t, false)
+	case json.Number:
+		return o.sanitizeText(t.String(), true)
+	case float64:
+		return o.sanitizeText(fmt.Sprint(t), true)
+	default:
+		return value, true
 	}
-	return string(sanitized)
 }
 
-func BuildSanitizeJson(data interface{}) json.RawMessage {
-	obj := parseJsonField("", Marshal(data))
-	sanitized, err := json.Marshal(obj)
-	if err != nil {
-		return nil
+// sanitizeText applies every matching Value rule, in order, to a string or a number
+// literal, which is kept as a number when no rule matches.
+func (o *Sanitizer) sanitizeText(s string, number bool) (interface{}, bool) {
+	matched := false
+	for i := range o.rules {
+		rule := &o.rules[i]
+		if rule.Key != "" || rule.Path != "" || !rule.Value.MatchString(s) {
+			continue
+		}
+		if rule.Action == SanitizeDrop {
+			return nil, false
+		}
+		s = rule.Value.ReplaceAllStringFunc(s, func(match string) string {
+			v, _ := o.apply(rule.Action, match)
+			return v.(string)
+		})
+		matched = true
+	}
+	if matched {
+		return s, true
+	}
+	if number {
+		return json.Number(s), true
+	}
+	if o.maxStringLength > 0 && utf8.RuneCountInString(s) > o.maxStringLength {
+		runes := []rune(s)
+		return string(runes[:o.maxStringLength-1]) + "...", true
 	}
-	return sanitized
+	return s, true
 }
 
-func parseJsonField(key string, data []byte) interface{} {
-	if len(data) < 1 {
-		return malformedFieldError
-	}
-	switch data[0] {
-	case '{':
-		return parseJsonNode(data)
-	case '[':
-		return parseJsonList(key, data)
+func (o *Sanitizer) apply(action string, value interface{}) (interface{}, bool) {
+	switch action {
+	case SanitizeDrop:
+		return nil, false
+	case SanitizePartial:
+		s, ok := value.(string)
+		if !ok {
+			s = fmt.Sprint(value)
+		}
+		runes := []rune(s)
+		if len(runes) <= 4 {
+			return strings.Repeat("*", len(runes)), true
+		}
+		return strings.Repeat("*", len(runes)-4) + string(runes[len(runes)-4:]), true
+	case SanitizeHash:
+		return o.hash(value), true
 	default:
-		return parseJsonTerminal(key, data)
+		return sanitizedSecret, true
 	}
 }
 
-func parseJsonNode(data []byte) interface{} {
-	obj := make(map[string]interface{})
-	var parsed map[string]json.RawMessage
-	err := json.Unmarshal(data, &parsed)
-	if err != nil {
-		return malformedNodeError
+func (o *Sanitizer) hash(value interface{}) string {
+	data, ok := value.(string)
+	if !ok {
+		data = string(Marshal(value))
 	}
-	for key, value := range parsed {
-		obj[key] = parseJsonField(strings.ToLower(key), value)
+	if o.hashKey != nil {
+		mac := hmac.New(sha256.New, o.hashKey)
+		mac.Write([]byte(data))
+		return "hmac-sha256:" + hex.EncodeToString(mac.Sum(nil)[:16])
 	}
-	return obj
+	sum := sha256.Sum256([]byte(data))
+	return "sha256:" + hex.EncodeToString(sum[:16])
 }
 
-func parseJsonList(key string, data []byte) interface{} {
-	obj := make([]interface{}, 0)
-	var parsed []json.RawMessage
-	err := json.Unmarshal(data, &parsed)
-	if err != nil {
-		return malformedListError
+func NewSanitizer(rules ...SanitizeRule) *Sanitizer {
+	sanitizer := &Sanitizer{maxStringLength: maxStringFieldLength}
+	for _, rule := range rules {
+		sanitizer.AddRule(rule)
 	}
-	for _, value := range parsed {
-		obj = append(obj, parseJsonField(key, value))
+	return sanitizer
+}
+
+type SanitizeRuleConfig struct {
+	Key    *string `json:"key"`
+	Path   *string `json:"path"`
+	Value  *string `json:"value"`
+	Action *string `json:"action"`
+}
+
+func (o *SanitizeRuleConfig) Validate() {
+	if o.Action == nil {
+		panic("Missing action")
+	}
+	if o.Key == nil && o.Path == nil && o.Value == nil {
+		panic("Missing key, path or value")
+	}
+	if o.Value != nil {
+		regexp.MustCompile(*o.Value)
 	}
-	return obj
 }
 
-func parseJsonTerminal(key string, data []byte) interface{} {
-	if len(data) < 1 {
-		return malformedTerminalError
+func (o *SanitizeRuleConfig) Rule() SanitizeRule {
+	rule := SanitizeRule{Action: *o.Action}
+	if o.Key != nil {
+		rule.Key = *o.Key
 	}
-	if InStringList(key, secretKeys) {
-		return sanitizedSecret
+	if o.Path != nil {
+		rule.Path = *o.Path
 	}
-	if data[0] == '"' && len(data) > maxStringFieldLength {
-		return string(append(data[1:maxStringFieldLength], "..."...))
+	if o.Value != nil {
+		rule.Value = regexp.MustCompile(*o.Value)
 	}
-	var obj interface{}
-	err := json.Unmarshal(data, &obj)
-	if err != nil {
-		return malformedTerminalError
+	return rule
+}
+
+type SanitizerConfig struct {
+	Rules           []SanitizeRuleConfig `json:"rules"`
+	MaxStringLength *int                 `json:"maxStringLength"`
+	HashKey         *string              `json:"hashKey"`
+}
+
+func (o *SanitizerConfig) Validate() {
+	if o.Rules == nil {
+		panic("Missing rules")
+	}
+	for i := range o.Rules {
+		o.Rules[i].Validate()
+	}
+}
+
+func NewSanitizerFromConfig(config SanitizerConfig) *Sanitizer {
+	sanitizer := NewSanitizer()
+	for i := range config.Rules {
+		sanitizer.AddRule(config.Rules[i].Rule())
+	}
+	if config.MaxStringLength != nil {
+		sanitizer.SetMaxStringLength(*config.MaxStringLength)
+	}
+	if config.HashKey != nil {
+		sanitizer.SetHashKey([]byte(*config.HashKey))
+	}
+	return sanitizer
+}
+
+// NewDefaultSanitizer returns a sanitizer masking the secret keys, as the package level
+// functions do.
+func NewDefaultSanitizer() *Sanitizer {
+	return NewSanitizer().MaskKeys(secretKeys...)
+}
+
+func SanitizeObject(obj interface{}) string {
+	return NewDefaultSanitizer().SanitizeObject(obj)
+}
+
+func SanitizeJson(data []byte) string {
+	return NewDefaultSanitizer().SanitizeJson(data)
+}
+
+func BuildSanitizeJson(data interface{}) json.RawMessage {
+	sanitized := NewDefaultSanitizer().SanitizeJson(Marshal(data))
+	if !json.Valid([]byte(sanitized)) {
+		return nil
 	}
-	return obj
+	return json.RawMessage(sanitized)
 }
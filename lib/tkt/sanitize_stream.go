@@ -0,0 +1,320 @@
+package tkt
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// MalformedJsonError tells where sanitized input stopped being valid JSON. Kind is the
+// legacy message of the value being read: node for objects, list for arrays, terminal for
+// scalars and field for missing input.
+type MalformedJsonError struct {
+	Kind   string
+	Offset int64
+	Line   int
+	Column int
+	Err    error
+}
+
+func (o *MalformedJsonError) Error() string {
+	return fmt.Sprintf("%s at line %d, column %d: %v", o.Kind, o.Line, o.Column, o.Err)
+}
+
+func (o *MalformedJsonError) Unwrap() error {
+	return o.Err
+}
+
+// jsonSanitizer copies a JSON text token by token, so that keys keep their order and
+// numbers their literal, replacing the values the sanitizer rules select.
+type jsonSanitizer struct {
+	*Sanitizer
+	data    []byte
+	decoder *json.Decoder
+	out     *bufio.Writer
+	depth   int
+	// dropsValues tells whether a value rule may drop scalars, which must then be read
+	// ahead before their key is written.
+	dropsValues bool
+}
+
+// WriteJson writes the sanitized JSON text to w, keeping key order and number literals.
+// Malformed input is reported as a *MalformedJsonError, in which case w may have received
+// part of the output.
+func (o *Sanitizer) WriteJson(w io.Writer, data []byte) error {
+	s := &jsonSanitizer{Sanitizer: o, data: data, decoder: json.NewDecoder(bytes.NewReader(data)), out: bufio.NewWriter(w)}
+	for i := range o.rules {
+		s.dropsValues = s.dropsValues || (o.rules[i].Value != nil && o.rules[i].Action == SanitizeDrop)
+	}
+	s.decoder.UseNumber()
+	if len(bytes.TrimSpace(data)) == 0 {
+		return s.malformed(malformedFieldError, io.ErrUnexpectedEOF)
+	}
+	if err := s.value([]string{}, ""); err != nil {
+		return err
+	}
+	offset := s.nextOffset()
+	if _, err := s.decoder.Token(); err == nil {
+		return s.malformedAt(malformedTerminalError, errors.New("unexpected data after top-level value"), offset)
+	} else if err != io.EOF {
+		return s.malformed(malformedTerminalError, err)
+	}
+	return s.out.Flush()
+}
+
+func (o *jsonSanitizer) value(pointer []string, key string) error {
+	for i := range o.rules {
+		if o.rules[i].matches(pointer, key) {
+			return o.replace(o.rules[i].Action)
+		}
+	}
+	kind := kindOf(o.peek())
+	token, err := o.decoder.Token()
+	if err != nil {
+		return o.malformed(kind, err)
+	}
+	switch t := token.(type) {
+	case json.Delim:
+		if t == '{' {
+			return o.object(pointer)
+		}
+		return o.array(pointer, key)
+	case string:
+		v, _ := o.sanitizeText(t, false)
+		return o.write(v)
+	case json.Number:
+		v, keep := o.sanitizeText(t.String(), true)
+		if !keep {
+			v = nil
+		}
+		return o.write(v)
+	default:
+		return o.write(t)
+	}
+}
+
+// replace reads the next whole value and writes it as the action asks; a dropped value
+// is written as null since its key, if any, has already gone out.
+func (o *jsonSanitizer) replace(action string) error {
+	kind := kindOf(o.peek())
+	var value interface{}
+	if err := o.decoder.Decode(&value); err != nil {
+		return o.malformed(kind, err)
+	}
+	v, _ := o.apply(action, value)
+	return o.write(v)
+}
+
+func (o *jsonSanitizer) object(pointer []string) error {
+	o.out.WriteByte('{')
+	o.depth++
+	first := true
+	for o.decoder.More() {
+		token, err := o.decoder.Token()
+		if err != nil {
+			return o.malformed(malformedNodeError, err)
+		}
+		k := token.(string)
+		child := append(pointer[:len(pointer):len(pointer)], k)
+		if o.dropped(child, k) {
+			if err := o.skip(); err != nil {
+				return err
+			}
+			continue
+		}
+		o.separator(&first)
+		o.write(k)
+		o.out.WriteByte(':')
+		if o.indent != "" {
+			o.out.WriteByte(' ')
+		}
+		if err := o.value(child, k); err != nil {
+			return err
+		}
+	}
+	return o.close(malformedNodeError, '}', first)
+}
+
+func (o *jsonSanitizer) array(pointer []string, key string) error {
+	o.out.WriteByte('[')
+	o.depth++
+	first := true
+	for i := 0; o.decoder.More(); i++ {
+		child := append(pointer[:len(pointer):len(pointer)], strconv.Itoa(i))
+		if o.dropped(child, key) {
+			if err := o.skip(); err != nil {
+				return err
+			}
+			continue
+		}
+		o.separator(&first)
+		if err := o.value(child, key); err != nil {
+			return err
+		}
+	}
+	return o.close(malformedListError, ']', first)
+}
+
+// dropped tells whether the value at pointer goes away: a key or path rule drops it, or a
+// value rule drops the scalar that follows.
+func (o *jsonSanitizer) dropped(pointer []string, key string) bool {
+	for i := range o.rules {
+		if o.rules[i].matches(pointer, key) {
+			return o.rules[i].Action == SanitizeDrop
+		}
+	}
+	if !o.dropsValues {
+		return false
+	}
+	var text string
+	switch kind := o.peek(); {
+	case kind == '"':
+		var s string
+		if !o.peekValue(&s) {
+			return false
+		}
+		text = s
+	case kind == '-' || (kind >= '0' && kind <= '9'):
+		var n json.Number
+		if !o.peekValue(&n) {
+			return false
+		}
+		text = n.String()
+	default:
+		return false
+	}
+	_, keep := o.sanitizeText(text, false)
+	return !keep
+}
+
+// peekValue decodes the next value without consuming it.
+func (o *jsonSanitizer) peekValue(v interface{}) bool {
+	offset := o.nextOffset()
+	decoder := json.NewDecoder(bytes.NewReader(o.data[offset:]))
+	decoder.UseNumber()
+	return decoder.Decode(v) == nil
+}
+
+func (o *jsonSanitizer) skip() error {
+	kind := kindOf(o.peek())
+	var raw json.RawMessage
+	if err := o.decoder.Decode(&raw); err != nil {
+		return o.malformed(kind, err)
+	}
+	return nil
+}
+
+func (o *jsonSanitizer) close(kind string, delim byte, empty bool) error {
+	if _, err := o.decoder.Token(); err != nil {
+		return o.malformed(kind, err)
+	}
+	o.depth--
+	if !empty && o.indent != "" {
+		o.newline()
+	}
+	return o.out.WriteByte(delim)
+}
+
+func (o *jsonSanitizer) separator(first *bool) {
+	if !*first {
+		o.out.WriteByte(',')
+	}
+	*first = false
+	if o.indent != "" {
+		o.newline()
+	}
+}
+
+func (o *jsonSanitizer) newline() {
+	o.out.WriteByte('\n')
+	for i := 0; i < o.depth; i++ {
+		o.out.WriteString(o.indent)
+	}
+}
+
+func (o *jsonSanitizer) write(v interface{}) error {
+	if n, ok := v.(json.Number); ok {
+		_, err := o.out.WriteString(n.String())
+		return err
+	}
+	buf := &bytes.Buffer{}
+	encoder := json.NewEncoder(buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(v); err != nil {
+		return err
+	}
+	_, err := o.out.Write(bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}))
+	return err
+}
+
+// nextOffset returns the offset of the next value, past whitespace and separators.
+func (o *jsonSanitizer) nextOffset() int64 {
+	offset := o.decoder.InputOffset()
+	for offset < int64(len(o.data)) {
+		switch o.data[offset] {
+		case ' ', '\t', '\r', '\n', ',', ':':
+			offset++
+		default:
+			return offset
+		}
+	}
+	return offset
+}
+
+func (o *jsonSanitizer) peek() byte {
+	offset := o.nextOffset()
+	if offset >= int64(len(o.data)) {
+		return 0
+	}
+	return o.data[offset]
+}
+
+func kindOf(c byte) string {
+	switch c {
+	case '{':
+		return malformedNodeError
+	case '[':
+		return malformedListError
+	case 0:
+		return malformedFieldError
+	default:
+		return malformedTerminalError
+	}
+}
+
+func (o *jsonSanitizer) malformed(kind string, err error) *MalformedJsonError {
+	offset := o.decoder.InputOffset()
+	var syntax *json.SyntaxError
+	if errors.As(err, &syntax) && strings.HasSuffix(syntax.Error(), "end of JSON input") {
+		offset = int64(len(o.data))
+	} else if syntax != nil && syntax.Offset > 0 && syntax.Offset <= int64(len(o.data)) {
+		// The offset follows the offending character, or its first byte.
+		offset = syntax.Offset - 1
+		for offset > 0 && !utf8.RuneStart(o.data[offset]) {
+			offset--
+		}
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF || offset > int64(len(o.data)) {
+		offset = int64(len(o.data))
+	}
+	return o.malformedAt(kind, err, offset)
+}
+
+// malformedAt reports err at offset, with the line and the column in characters.
+func (o *jsonSanitizer) malformedAt(kind string, err error, offset int64) *MalformedJsonError {
+	line, column := 1, 1
+	for _, c := range o.data[:offset] {
+		if c == '\n' {
+			line, column = line+1, 1
+		} else if utf8.RuneStart(c) {
+			column++
+		}
+	}
+	return &MalformedJsonError{Kind: kind, Offset: offset, Line: line, Column: column, Err: err}
+}
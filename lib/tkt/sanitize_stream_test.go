@@ -0,0 +1,107 @@
+package tkt
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestWriteJsonAgreesWithSanitize(t *testing.T) {
+	sanitizers := map[string]*Sanitizer{
+		"default": NewDefaultSanitizer(),
+		"keys and paths": NewSanitizer(
+			SanitizeRule{Key: "card", Action: SanitizePartial},
+			SanitizeRule{Key: "secret", Action: SanitizeDrop},
+			SanitizeRule{Path: "/members/*/dob", Action: SanitizeMask},
+			SanitizeRule{Path: "/**/id", Action: SanitizeHash}),
+		"values": NewSanitizer(
+			SanitizeRule{Value: SsnPattern, Action: SanitizeDrop},
+			SanitizeRule{Value: regexp.MustCompile(`^\d{9}$`), Action: SanitizeMask},
+			SanitizeRule{Value: CreditCardPattern, Action: SanitizePartial}),
+	}
+	docs := []string{
+		`{"password": "a", "name": "b", "nested": {"token": {"x": 1}, "list": [1, "two", null, true]}}`,
+		`{"card": "4111111111111111", "secret": {"a": 1}, "members": [{"dob": "x", "id": 7, "secret": 2}, {"card": 12345}]}`,
+		`{"fein": 123456789, "ssn": "123-45-6789", "notes": ["123-45-6789", "card 4111 1111 1111 1111", 12]}`,
+		`[{"id": {"deep": [1, 2]}}, "plain", 1.5e3, -0]`,
+		`"123-45-6789"`,
+		`{}`,
+	}
+	for name, sanitizer := range sanitizers {
+		for _, doc := range docs {
+			buf := &bytes.Buffer{}
+			if err := sanitizer.WriteJson(buf, []byte(doc)); err != nil {
+				t.Fatalf("%s: %s: %v", name, doc, err)
+			}
+			got := decodeTestJson(t, buf.String())
+			if want := sanitizer.Sanitize(decodeTestJson(t, doc)); !reflect.DeepEqual(got, want) {
+				t.Errorf("%s: %s: got %s, want %#v", name, doc, buf, want)
+			}
+		}
+	}
+}
+
+func TestWriteJsonKeepsKeyOrderAndNumbers(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`{"z": 1, "a": 2, "m": {"y": 3, "b": 4}}`, `{"z":1,"a":2,"m":{"y":3,"b":4}}`},
+		{`{"big": 12345678901234567890123, "precise": 0.10000000000000000001, "exp": 1.50E+3}`,
+			`{"big":12345678901234567890123,"precise":0.10000000000000000001,"exp":1.50E+3}`},
+		{`{"password": 12345678901234567890123, "after": -0.0}`, `{"password":"**********","after":-0.0}`},
+		{`{"html": "<a & b>", "unicode": "ñé"}`, `{"html":"<a & b>","unicode":"ñé"}`},
+	}
+	for _, test := range tests {
+		if got := NewDefaultSanitizer().SanitizeJson([]byte(test.input)); got != test.want {
+			t.Errorf("%s: got %s, want %s", test.input, got, test.want)
+		}
+	}
+}
+
+func TestWriteJsonIndent(t *testing.T) {
+	got := NewDefaultSanitizer().SetIndent("  ").SanitizeJson([]byte(`{"a": [1, {}], "b": [], "password": "x"}`))
+	want := "{\n  \"a\": [\n    1,\n    {}\n  ],\n  \"b\": [],\n  \"password\": \"**********\"\n}"
+	if got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestWriteJsonMalformed(t *testing.T) {
+	tests := []struct {
+		input  string
+		kind   string
+		line   int
+		column int
+	}{
+		{"", malformedFieldError, 1, 1},
+		{"  \n ", malformedFieldError, 2, 2},
+		{`{"a": 1`, malformedNodeError, 1, 8},
+		{"{\"a\": [1,\n 2}", malformedListError, 2, 3},
+		{`{"a": tru}`, malformedTerminalError, 1, 10},
+		{`{"a": 1} x`, malformedTerminalError, 1, 10},
+		{`{"a": 1} {"b": 2}`, malformedTerminalError, 1, 10},
+		{`{"password": {"a": }}`, malformedNodeError, 1, 20},
+		{`{"ñ": [1, ñ]}`, malformedTerminalError, 1, 11},
+		{"{\"ñ\":\r\n [1,, 2]}", malformedTerminalError, 2, 5},
+	}
+	for _, test := range tests {
+		buf := &bytes.Buffer{}
+		err := NewDefaultSanitizer().WriteJson(buf, []byte(test.input))
+		var malformed *MalformedJsonError
+		if !errors.As(err, &malformed) {
+			t.Errorf("%q: got %v, want a MalformedJsonError", test.input, err)
+			continue
+		}
+		if malformed.Kind != test.kind || malformed.Line != test.line || malformed.Column != test.column {
+			t.Errorf("%q: got %s at %d:%d, want %s at %d:%d", test.input, malformed.Kind, malformed.Line, malformed.Column, test.kind, test.line, test.column)
+		}
+		got := NewDefaultSanitizer().SanitizeJson([]byte(test.input))
+		if !strings.HasPrefix(got, `"`+test.kind+" at line ") {
+			t.Errorf("%q: SanitizeJson gave %s", test.input, got)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package tkt
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func decodeTestJson(t *testing.T, text string) interface{} {
+	t.Helper()
+	decoder := json.NewDecoder(bytes.NewReader([]byte(text)))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		t.Fatalf("%s: %v", text, err)
+	}
+	return value
+}
+
+func TestSanitizerRules(t *testing.T) {
+	digits := regexp.MustCompile(`^\d{9}$`)
+	tests := []struct {
+		name  string
+		rules []SanitizeRule
+		input string
+		want  string
+	}{
+		{"key, any case", []SanitizeRule{{Key: "SSN", Action: SanitizeMask}},
+			`{"ssn": "123-45-6789", "name": "a"}`, `{"ssn": "**********", "name": "a"}`},
+		{"key masks the whole value", []SanitizeRule{{Key: "password", Action: SanitizeMask}},
+			`{"password": {"old": "a", "new": "b"}}`, `{"password": "**********"}`},
+		{"key inside arrays", []SanitizeRule{{Key: "ssn", Action: SanitizeMask}},
+			`{"members": [{"ssn": "1"}, {"ssn": "2", "id": 3}]}`, `{"members": [{"ssn": "**********"}, {"ssn": "**********", "id": 3}]}`},
+		{"partial", []SanitizeRule{{Key: "card", Action: SanitizePartial}},
+			`[{"card": "4111111111111111"}, {"card": "123"}, {"card": 12345}]`, `[{"card": "************1111"}, {"card": "***"}, {"card": "*2345"}]`},
+		{"drop", []SanitizeRule{{Key: "secret", Action: SanitizeDrop}},
+			`{"secret": 1, "a": [{"secret": 2, "b": 3}]}`, `{"a": [{"b": 3}]}`},
+		{"path with globs", []SanitizeRule{{Path: "/members/*/dob", Action: SanitizeMask}},
+			`{"dob": "x", "members": [{"dob": "y", "name": "n"}]}`, `{"dob": "x", "members": [{"dob": "**********", "name": "n"}]}`},
+		{"path with **", []SanitizeRule{{Path: "/**/id", Action: SanitizeMask}},
+			`{"id": 1, "a": {"b": {"id": 2, "c": 3}}}`, `{"id": "**********", "a": {"b": {"id": "**********", "c": 3}}}`},
+		{"path with escaped tokens", []SanitizeRule{{Path: "/a~1b/c~0d", Action: SanitizeMask}},
+			`{"a/b": {"c~d": 1, "e": 2}}`, `{"a/b": {"c~d": "**********", "e": 2}}`},
+		{"key and path together", []SanitizeRule{{Key: "dob", Path: "/members/**", Action: SanitizeMask}},
+			`{"dob": "x", "members": [{"dob": "y"}]}`, `{"dob": "x", "members": [{"dob": "**********"}]}`},
+		{"value in text", []SanitizeRule{{Value: SsnPattern, Action: SanitizePartial}},
+			`{"note": "ssn 123-45-6789 here"}`, `{"note": "ssn *******6789 here"}`},
+		{"value in numbers", []SanitizeRule{{Value: digits, Action: SanitizeMask}},
+			`{"fein": 123456789, "count": 12}`, `{"fein": "**********", "count": 12}`},
+		{"every value rule acts", []SanitizeRule{{Value: SsnPattern, Action: SanitizeMask}, {Value: CreditCardPattern, Action: SanitizePartial}},
+			`"123-45-6789 / 4111 1111 1111 1111"`, `"********** / ***************1111"`},
+		{"value drop", []SanitizeRule{{Value: SsnPattern, Action: SanitizeDrop}},
+			`{"a": ["x", "123-45-6789"], "b": "123-45-6789"}`, `{"a": ["x"]}`},
+		{"key rules before value rules", []SanitizeRule{{Value: SsnPattern, Action: SanitizeDrop}, {Key: "note", Action: SanitizeMask}},
+			`{"note": "123-45-6789"}`, `{"note": "**********"}`},
+		{"first key or path rule decides", []SanitizeRule{{Path: "/card", Action: SanitizePartial}, {Key: "card", Action: SanitizeDrop}},
+			`{"card": "4111111111111111"}`, `{"card": "************1111"}`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := NewSanitizer(test.rules...).Sanitize(decodeTestJson(t, test.input))
+			if want := decodeTestJson(t, test.want); !reflect.DeepEqual(got, want) {
+				t.Errorf("got %#v, want %#v", got, want)
+			}
+		})
+	}
+}
+
+func TestSanitizerHash(t *testing.T) {
+	sum := sha256.Sum256([]byte("123456789"))
+	unkeyed := "sha256:" + hex.EncodeToString(sum[:16])
+	mac := hmac.New(sha256.New, []byte("key"))
+	mac.Write([]byte("123456789"))
+	keyed := "hmac-sha256:" + hex.EncodeToString(mac.Sum(nil)[:16])
+
+	sanitizer := NewSanitizer(SanitizeRule{Key: "fein", Action: SanitizeHash})
+	if got := sanitizer.Sanitize(map[string]interface{}{"fein": "123456789"}); !reflect.DeepEqual(got, map[string]interface{}{"fein": unkeyed}) {
+		t.Errorf("unkeyed %v, want %s", got, unkeyed)
+	}
+	sanitizer.SetHashKey([]byte("key"))
+	if got := sanitizer.Sanitize(map[string]interface{}{"fein": "123456789"}); !reflect.DeepEqual(got, map[string]interface{}{"fein": keyed}) {
+		t.Errorf("keyed %v, want %s", got, keyed)
+	}
+}
+
+func TestSanitizerTruncatesLongStrings(t *testing.T) {
+	sanitizer := NewSanitizer(SanitizeRule{Value: SsnPattern, Action: SanitizeMask}).SetMaxStringLength(5)
+	got := sanitizer.Sanitize([]interface{}{"abcde", "abcdefgh", "ññññññ", "123-45-6789"})
+	want := []interface{}{"abcde", "abcd...", "ññññ...", "**********"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+	if got := sanitizer.SetMaxStringLength(0).Sanitize("abcdefgh"); got != "abcdefgh" {
+		t.Errorf("got %q with truncation off", got)
+	}
+}
+
+func TestSanitizerRejectsInvalidRules(t *testing.T) {
+	for _, rule := range []SanitizeRule{{Key: "a", Action: "erase"}, {Action: SanitizeMask}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%+v accepted", rule)
+				}
+			}()
+			NewSanitizer(rule)
+		}()
+	}
+}
+
+func TestSanitizerFromConfig(t *testing.T) {
+	config := SanitizerConfig{}
+	if err := json.Unmarshal([]byte(`{
+		"rules": [{"key": "fein", "action": "hash"}, {"value": "\\d{3}-\\d{2}-\\d{4}", "action": "partial"}],
+		"maxStringLength": 8,
+		"hashKey": "key"
+	}`), &config); err != nil {
+		t.Fatal(err)
+	}
+	config.Validate()
+	sanitizer := NewSanitizerFromConfig(config)
+	mac := hmac.New(sha256.New, []byte("key"))
+	mac.Write([]byte("12-3456789"))
+	got := sanitizer.Sanitize(decodeTestJson(t, `{"fein": "12-3456789", "ssn": "123-45-6789", "name": "a long name"}`))
+	want := map[string]interface{}{"fein": "hmac-sha256:" + hex.EncodeToString(mac.Sum(nil)[:16]), "ssn": "*******6789", "name": "a long ..."}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestSanitizeObjectMasksSecretKeys(t *testing.T) {
+	got := SanitizeObject(struct {
+		Name     string
+		Password string
+		Ssn      string
+	}{"n", "p", "s"})
+	if want := `{"Name":"n","Password":"**********","Ssn":"**********"}`; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
@@ -1,6 +1,7 @@
 package tkt
 
 import (
+	"context"
 	"database/sql"
 	_ "github.com/lib/pq"
 	"sync"
@@ -11,7 +12,22 @@ type Sequence struct {
 	LastId int64
 }
 
-var inMemoryManagerMap = make(map[string]*InMemorySequenceManager)
+// sequenceManagerKey tells apart the databases of the sequence managers.
+type sequenceManagerKey struct {
+	driver     string
+	datasource string
+	schema     string
+}
+
+func newSequenceManagerKey(config DatabaseConfig) sequenceManagerKey {
+	key := sequenceManagerKey{driver: *config.DatabaseDriver, datasource: *config.DatasourceName}
+	if config.SequenceSchema != nil {
+		key.schema = *config.SequenceSchema
+	}
+	return key
+}
+
+var inMemoryManagerMap = make(map[sequenceManagerKey]*InMemorySequenceManager)
 var mux = sync.Mutex{}
 
 type SequenceManager interface {
@@ -26,25 +42,35 @@ type InMemorySequenceManager struct {
 }
 
 func (o *InMemorySequenceManager) next(name string) int64 {
+	return o.nextIn(nil, name)
+}
+
+// nextIn returns the next id of the sequence, reading its current maximum on tx the first
+// time, or on a connection of the shared pool when tx is nil.
+func (o *InMemorySequenceManager) nextIn(tx *sql.Tx, name string) int64 {
 	o.mux.Lock()
 	defer o.mux.Unlock()
 	seq, ok := o.sequenceMap[name]
 	if !ok {
 		seq = &Sequence{name, 0}
-		o.initSequence(seq)
+		o.initSequence(tx, seq)
 		o.sequenceMap[name] = seq
 	}
 	seq.LastId = seq.LastId + 1
 	return seq.LastId
 }
 
-func (o *InMemorySequenceManager) initSequence(sequence *Sequence) {
-	db := OpenDB(o.databaseConfig)
-	defer db.Close()
-	tx, err := db.Begin()
-	CheckErr(err)
-	defer tx.Commit()
-	result, err := tx.Query("select max(id) from " + sequence.Name)
+// initSequence reads the current maximum on the caller's transaction, so that it needs no
+// connection besides the one the caller holds, whatever maxOpenConns is.
+func (o *InMemorySequenceManager) initSequence(tx *sql.Tx, sequence *Sequence) {
+	if tx == nil {
+		var err error
+		tx, err = SharedDB(o.databaseConfig).Begin()
+		CheckErr(err)
+		defer tx.Commit()
+	}
+	dialect := DialectOf(o.databaseConfig)
+	result, err := tx.Query("select max(" + quoteFolded(dialect, "id") + ") from " + quoteFolded(dialect, sequence.Name))
 	CheckErr(err)
 	defer result.Close()
 	result.Next()
@@ -57,20 +83,196 @@ func (o *InMemorySequenceManager) initSequence(sequence *Sequence) {
 	sequence.LastId = id
 }
 
+// txInMemorySequences are the in memory sequences seen from a transaction.
+type txInMemorySequences struct {
+	SequenceManager
+	manager *InMemorySequenceManager
+	tx      *sql.Tx
+}
+
+func (o *txInMemorySequences) next(name string) int64 {
+	return o.manager.nextIn(o.tx, name)
+}
+
 func NewInMemorySequenceManager(config DatabaseConfig) *InMemorySequenceManager {
 	mux.Lock()
 	defer mux.Unlock()
-	instance, ok := inMemoryManagerMap[*config.DatasourceName]
+	key := newSequenceManagerKey(config)
+	instance, ok := inMemoryManagerMap[key]
 	if !ok {
 		instance = &InMemorySequenceManager{sequenceMap: make(map[string]*Sequence, 0), databaseConfig: config, mux: sync.Mutex{}}
-		inMemoryManagerMap[*config.DatasourceName] = instance
+		inMemoryManagerMap[key] = instance
 	}
 	return instance
 }
 
+var hiLoManagerMap = make(map[sequenceManagerKey]*HiLoSequenceManager)
+
+// hiLoTable keeps, for each sequence, the first id not reserved yet.
+const hiLoTable = "SequenceBlock"
+
+// HiLoSequenceManager hands out ids from blocks reserved in the SequenceBlock table, each
+// in a transaction of its own, so that processes sharing a database never get the same id
+// and a restart goes on after the last block. The ids left in a block when the process
+// stops are skipped. The next block is reserved in the background once a fifth of the
+// current one is left. Each sequence has a lock of its own, so a slow reservation only
+// holds back the sequence it is for.
+//
+// A new sequence starts after the maximum id of the table of the same name. Reserving
+// needs a connection besides the caller's, so the pool needs maxOpenConns of at least 2.
+type HiLoSequenceManager struct {
+	SequenceManager
+	databaseConfig DatabaseConfig
+	blockSize      int64
+	sequenceMap    map[string]*hiLoSequence
+	tableOnce      sync.Once
+	tableErr       error
+	// mux guards sequenceMap.
+	mux sync.Mutex
+}
+
+type hiLoSequence struct {
+	next   int64
+	end    int64
+	refill chan hiLoBlock
+	mux    sync.Mutex
+}
+
+type hiLoBlock struct {
+	next int64
+	err  error
+}
+
+func (o *HiLoSequenceManager) next(name string) int64 {
+	seq := o.sequence(name)
+	seq.mux.Lock()
+	defer seq.mux.Unlock()
+	if seq.next == seq.end {
+		seq.next = o.nextBlock(seq, name)
+		seq.end = seq.next + o.blockSize
+	}
+	id := seq.next
+	seq.next++
+	if seq.refill == nil && (seq.end-seq.next)*5 <= o.blockSize {
+		refill := make(chan hiLoBlock, 1)
+		seq.refill = refill
+		go func() {
+			next, err := o.reserve(context.Background(), name)
+			refill <- hiLoBlock{next: next, err: err}
+		}()
+	}
+	return id
+}
+
+func (o *HiLoSequenceManager) sequence(name string) *hiLoSequence {
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	seq, ok := o.sequenceMap[name]
+	if !ok {
+		seq = &hiLoSequence{}
+		o.sequenceMap[name] = seq
+	}
+	return seq
+}
+
+// nextBlock returns the first id of the block reserved in the background or, when there is
+// none or reserving it failed, of a block reserved now.
+func (o *HiLoSequenceManager) nextBlock(seq *hiLoSequence, name string) int64 {
+	if seq.refill != nil {
+		block := <-seq.refill
+		seq.refill = nil
+		if block.err == nil {
+			return block.next
+		}
+	}
+	next, err := o.reserve(context.Background(), name)
+	CheckErr(err)
+	return next
+}
+
+// reserve takes the next block of the sequence and returns its first id.
+func (o *HiLoSequenceManager) reserve(ctx context.Context, name string) (int64, error) {
+	o.tableOnce.Do(func() {
+		o.tableErr = o.createTable(ctx)
+	})
+	if o.tableErr != nil {
+		return 0, o.tableErr
+	}
+	dialect := DialectOf(o.databaseConfig)
+	table := o.table(dialect)
+	nameColumn := dialect.QuoteIdentifier("Name")
+	nextColumn := dialect.QuoteIdentifier("NextId")
+	update := "update " + table + " set " + nextColumn + " = " + nextColumn + " + " + dialect.Placeholder(1) +
+		" where " + nameColumn + " = " + dialect.Placeholder(2)
+	r, err := ExecuteTransactionalContext(ctx, o.databaseConfig, func(txCtx *TxCtx) (interface{}, error) {
+		result, err := txCtx.ExecSqlContext(ctx, update, o.blockSize, name)
+		if err != nil {
+			return nil, err
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			var max sql.NullInt64
+			if _, err := txCtx.QuerySingletonContext(ctx, "select max("+quoteFolded(dialect, "id")+") from "+
+				quoteFolded(dialect, name), []interface{}{&max}); err != nil {
+				return nil, err
+			}
+			// A process seeding the same sequence concurrently makes this insert a no-op.
+			if _, err := txCtx.ExecSqlContext(ctx, "insert into "+table+" ("+nameColumn+", "+nextColumn+") values ("+
+				dialect.Placeholder(1)+", "+dialect.Placeholder(2)+") on conflict ("+nameColumn+") do nothing",
+				name, max.Int64+1); err != nil {
+				return nil, err
+			}
+			if _, err := txCtx.ExecSqlContext(ctx, update, o.blockSize, name); err != nil {
+				return nil, err
+			}
+		}
+		var next int64
+		_, err = txCtx.QuerySingletonContext(ctx, "select "+nextColumn+" from "+table+" where "+nameColumn+" = "+
+			dialect.Placeholder(1), []interface{}{&next}, name)
+		return next - o.blockSize, err
+	})
+	if err != nil {
+		return 0, err
+	}
+	return r.(int64), nil
+}
+
+func (o *HiLoSequenceManager) createTable(ctx context.Context) error {
+	dialect := DialectOf(o.databaseConfig)
+	_, err := ExecuteTransactionalContext(ctx, o.databaseConfig, func(txCtx *TxCtx) (interface{}, error) {
+		return txCtx.ExecSqlContext(ctx, "create table if not exists "+o.table(dialect)+" ("+
+			dialect.QuoteIdentifier("Name")+" varchar(128) primary key, "+dialect.QuoteIdentifier("NextId")+" bigint not null)")
+	})
+	return err
+}
+
+// table returns the quoted SequenceBlock table, qualified by DatabaseConfig.SequenceSchema
+// or else in the default schema of the connection.
+func (o *HiLoSequenceManager) table(dialect Dialect) string {
+	if o.databaseConfig.SequenceSchema == nil {
+		return dialect.QuoteIdentifier(hiLoTable)
+	}
+	return dialect.QuoteIdentifier(*o.databaseConfig.SequenceSchema) + "." + dialect.QuoteIdentifier(hiLoTable)
+}
+
+func NewHiLoSequenceManager(config DatabaseConfig) *HiLoSequenceManager {
+	mux.Lock()
+	defer mux.Unlock()
+	key := newSequenceManagerKey(config)
+	instance, ok := hiLoManagerMap[key]
+	if !ok {
+		instance = &HiLoSequenceManager{databaseConfig: config, blockSize: config.blockSize(),
+			sequenceMap: make(map[string]*hiLoSequence)}
+		hiLoManagerMap[key] = instance
+	}
+	return instance
+}
+
+// PgSequenceManager reads the <name>seq sequences of the database, with the NextValSql of
+// its dialect.
 type PgSequenceManager struct {
 	SequenceManager
 	tx      *sql.Tx
+	dialect Dialect
 	stmtMap map[string]*sql.Stmt
 }
 
@@ -78,7 +280,7 @@ func (o *PgSequenceManager) next(name string) int64 {
 	stmt := o.stmtMap[name]
 	if stmt == nil {
 		var err error
-		stmt, err = o.tx.Prepare("select nextval('" + name + "seq')")
+		stmt, err = o.tx.Prepare(o.dialect.NextValSql(name + "seq"))
 		CheckErr(err)
 		o.stmtMap[name] = stmt
 	}
@@ -91,7 +293,11 @@ func (o *PgSequenceManager) next(name string) int64 {
 }
 
 func NewPgSequenceManager(tx *sql.Tx) *PgSequenceManager {
-	seqs := PgSequenceManager{tx: tx, stmtMap: make(map[string]*sql.Stmt)}
+	return newDialectSequenceManager(tx, PostgresDialect{})
+}
+
+func newDialectSequenceManager(tx *sql.Tx, dialect Dialect) *PgSequenceManager {
+	seqs := PgSequenceManager{tx: tx, dialect: dialect, stmtMap: make(map[string]*sql.Stmt)}
 	return &seqs
 }
 
@@ -104,16 +310,41 @@ func (o *Sequences) Next(name string) int64 {
 	return o.manager.next(name)
 }
 
+// NextGuid returns a new identifier of the generator named by DatabaseConfig.IdGenerator,
+// UUIDv7 by default.
+func (o *Sequences) NextGuid() string {
+	name := UuidV7
+	if o.databaseConfig.IdGenerator != nil {
+		name = *o.databaseConfig.IdGenerator
+	}
+	generator, ok := IdGeneratorOf(name)
+	if !ok {
+		panic("Unknown id generator: " + name)
+	}
+	return generator.NewId()
+}
+
 func NewSequences(config DatabaseConfig, tx *sql.Tx) *Sequences {
 	manager := resolveSequenceManager(config, tx)
 	return &Sequences{databaseConfig: config, manager: manager}
 }
 
+// resolveSequenceManager defaults to the database sequences, or to in memory ones when the
+// dialect has none.
 func resolveSequenceManager(config DatabaseConfig, tx *sql.Tx) SequenceManager {
-	if config.SequenceManager == nil || *config.SequenceManager == "pgSequence" {
-		return NewPgSequenceManager(tx)
+	dialect := DialectOf(config)
+	hasSequences := dialect.NextValSql("seq") != ""
+	if config.SequenceManager == nil && !hasSequences {
+		return &txInMemorySequences{manager: NewInMemorySequenceManager(config), tx: tx}
+	} else if config.SequenceManager == nil || *config.SequenceManager == "pgSequence" {
+		if !hasSequences {
+			panic("No sequences in " + dialect.Name())
+		}
+		return newDialectSequenceManager(tx, dialect)
 	} else if *config.SequenceManager == "inMemory" {
-		return NewInMemorySequenceManager(config)
+		return &txInMemorySequences{manager: NewInMemorySequenceManager(config), tx: tx}
+	} else if *config.SequenceManager == "hiLo" {
+		return NewHiLoSequenceManager(config)
 	} else {
 		panic("Unknown sequence manager: " + *config.SequenceManager)
 	}
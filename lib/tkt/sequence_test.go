@@ -0,0 +1,108 @@
+package tkt
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func hiLoConfig(t *testing.T, blockSize int) DatabaseConfig {
+	config := sqliteConfig(t, "hilo.db", 4)
+	datasource := *config.DatasourceName + "?_pragma=busy_timeout(10000)"
+	manager := "hiLo"
+	config.DatasourceName, config.SequenceManager, config.SequenceBlockSize = &datasource, &manager, &blockSize
+	ExecuteTransactional(config, func(txCtx *TxCtx, args ...interface{}) interface{} {
+		txCtx.ExecSql("create table A (id bigint primary key)")
+		txCtx.ExecSql("create table B (id bigint primary key)")
+		return nil
+	})
+	return config
+}
+
+// settle waits for the blocks being reserved in the background.
+func settle(manager *HiLoSequenceManager) {
+	manager.mux.Lock()
+	defer manager.mux.Unlock()
+	for _, seq := range manager.sequenceMap {
+		seq.mux.Lock()
+		if seq.refill != nil {
+			block := <-seq.refill
+			seq.refill = nil
+			if block.err == nil {
+				seq.next, seq.end = block.next, block.next+manager.blockSize
+			}
+		}
+		seq.mux.Unlock()
+	}
+}
+
+func TestHiLoIdsAreUniqueUnderConcurrency(t *testing.T) {
+	defer ClosePools()
+	manager := NewHiLoSequenceManager(hiLoConfig(t, 10))
+	defer settle(manager)
+	var mux sync.Mutex
+	ids := make(map[int64]bool)
+	hammer(8, func(i int) {
+		name := []string{"A", "B"}[i%2]
+		for j := 0; j < 50; j++ {
+			id := manager.next(name)
+			mux.Lock()
+			if ids[id<<1|int64(i%2)] {
+				t.Errorf("%s: id %d given twice", name, id)
+			}
+			ids[id<<1|int64(i%2)] = true
+			mux.Unlock()
+		}
+	})
+	if len(ids) != 400 {
+		t.Errorf("%d ids, want 400", len(ids))
+	}
+}
+
+func TestHiLoSequenceReservingDoesNotHoldOthers(t *testing.T) {
+	defer ClosePools()
+	config := hiLoConfig(t, 10)
+	schema := "main"
+	config.SequenceSchema = &schema
+	manager := NewHiLoSequenceManager(config)
+	defer settle(manager)
+	// A reservation of A in progress holds the lock of A.
+	a := manager.sequence("A")
+	a.mux.Lock()
+	defer a.mux.Unlock()
+	done := make(chan int64, 1)
+	go func() {
+		done <- manager.next("B")
+	}()
+	select {
+	case id := <-done:
+		if id != 1 {
+			t.Errorf("id %d, want 1", id)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("B waits for the reservation of A")
+	}
+}
+
+func TestSequenceManagersByDatabase(t *testing.T) {
+	config := func(driver string, schema string) DatabaseConfig {
+		datasource := "file:managers.db"
+		config := DatabaseConfig{DatabaseDriver: &driver, DatasourceName: &datasource}
+		if schema != "" {
+			config.SequenceSchema = &schema
+		}
+		return config
+	}
+	base := config("sqlite", "")
+	for _, other := range []DatabaseConfig{config("postgres", ""), config("sqlite", "main"), config("sqlite", "other")} {
+		if NewHiLoSequenceManager(other) == NewHiLoSequenceManager(base) {
+			t.Errorf("hiLo: %s %v shares the manager of sqlite", *other.DatabaseDriver, other.SequenceSchema)
+		}
+		if NewInMemorySequenceManager(other) == NewInMemorySequenceManager(base) {
+			t.Errorf("inMemory: %s %v shares the manager of sqlite", *other.DatabaseDriver, other.SequenceSchema)
+		}
+	}
+	if NewHiLoSequenceManager(config("sqlite", "main")) != NewHiLoSequenceManager(config("sqlite", "main")) {
+		t.Error("the same database got two managers")
+	}
+}
@@ -2,8 +2,9 @@ package tkt
 
 import (
 	"bytes"
+	"context"
 	"database/sql"
-	"fmt"
+	"math/rand"
 	"reflect"
 	"time"
 )
@@ -12,12 +13,19 @@ var timeType = reflect.TypeOf(time.Time{})
 var byteArrayType = reflect.TypeOf([]byte{})
 
 type DatabaseConfig struct {
-	DatabaseDriver  *string `json:"databaseDriver"`
-	DatasourceName  *string `json:"datasourceName"`
-	MaxIdleConns    *int    `json:"maxIdleConns"`
-	MaxOpenConns    *int    `json:"maxOpenConns"`
-	MaxConnLifetime *int    `json:"maxConnLifetime"`
-	SequenceManager *string `json:"sequenceManager"`
+	DatabaseDriver    *string `json:"databaseDriver"`
+	DatasourceName    *string `json:"datasourceName"`
+	MaxIdleConns      *int    `json:"maxIdleConns"`
+	MaxOpenConns      *int    `json:"maxOpenConns"`
+	MaxConnLifetime   *int    `json:"maxConnLifetime"`
+	SequenceManager   *string `json:"sequenceManager"`
+	SequenceBlockSize *int    `json:"sequenceBlockSize"`
+	SequenceSchema    *string `json:"sequenceSchema"`
+	IdGenerator       *string `json:"idGenerator"`
+	IsolationLevel    *string `json:"isolationLevel"`
+	MaxRetries        *int    `json:"maxRetries"`
+	RetryBackoff      *int    `json:"retryBackoff"`
+	MaxRequestBody    *int64  `json:"maxRequestBody"`
 }
 
 func (o *DatabaseConfig) Validate() {
@@ -27,6 +35,96 @@ func (o *DatabaseConfig) Validate() {
 	if o.DatasourceName == nil {
 		panic("Invalid datasourceName")
 	}
+	if o.IsolationLevel != nil {
+		if _, ok := isolationLevels[*o.IsolationLevel]; !ok {
+			panic("Invalid isolationLevel")
+		}
+	}
+	if o.MaxRetries != nil && *o.MaxRetries < 0 {
+		panic("Invalid maxRetries")
+	}
+	if o.RetryBackoff != nil && *o.RetryBackoff <= 0 {
+		panic("Invalid retryBackoff")
+	}
+	if o.MaxRequestBody != nil && *o.MaxRequestBody <= 0 {
+		panic("Invalid maxRequestBody")
+	}
+	if o.SequenceBlockSize != nil && *o.SequenceBlockSize <= 0 {
+		panic("Invalid sequenceBlockSize")
+	}
+	if o.IdGenerator != nil {
+		if _, ok := IdGeneratorOf(*o.IdGenerator); !ok {
+			panic("Invalid idGenerator")
+		}
+	}
+}
+
+var isolationLevels = map[string]sql.IsolationLevel{
+	"default":          sql.LevelDefault,
+	"read uncommitted": sql.LevelReadUncommitted,
+	"read committed":   sql.LevelReadCommitted,
+	"repeatable read":  sql.LevelRepeatableRead,
+	"serializable":     sql.LevelSerializable,
+}
+
+const (
+	defaultBlockSize    = 100
+	defaultMaxRetries   = 3
+	defaultRetryBackoff = 20
+	maxRetryBackoff     = 2000
+	// defaultMaxRequestBody bounds, in bytes, the request bodies buffered to be retried.
+	defaultMaxRequestBody = 10 << 20
+)
+
+func (o *DatabaseConfig) txOptions(readOnly bool) *sql.TxOptions {
+	options := &sql.TxOptions{ReadOnly: readOnly}
+	if o.IsolationLevel != nil {
+		level, ok := isolationLevels[*o.IsolationLevel]
+		if !ok {
+			panic("Invalid isolationLevel " + *o.IsolationLevel)
+		}
+		options.Isolation = level
+	}
+	return options
+}
+
+func (o *DatabaseConfig) maxRetries() int {
+	if o.MaxRetries == nil {
+		return defaultMaxRetries
+	}
+	return *o.MaxRetries
+}
+
+func (o *DatabaseConfig) maxRequestBody() int64 {
+	if o.MaxRequestBody == nil {
+		return defaultMaxRequestBody
+	}
+	return *o.MaxRequestBody
+}
+
+func (o *DatabaseConfig) blockSize() int64 {
+	if o.SequenceBlockSize == nil {
+		return defaultBlockSize
+	}
+	return int64(*o.SequenceBlockSize)
+}
+
+// retryDelay returns the pause before the given retry, counted from 0: the backoff, in
+// milliseconds, doubled on every retry up to 2 s, of which a random half is taken off.
+func (o *DatabaseConfig) retryDelay(retry int) time.Duration {
+	backoff := defaultRetryBackoff
+	if o.RetryBackoff != nil {
+		backoff = *o.RetryBackoff
+	}
+	delay := backoff
+	for i := 0; i < retry && delay < maxRetryBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxRetryBackoff {
+		delay = maxRetryBackoff
+	}
+	d := time.Duration(delay) * time.Millisecond
+	return d/2 + time.Duration(rand.Int63n(int64(d/2)+1))
 }
 
 func CloseDB(db *sql.DB) {
@@ -41,9 +139,28 @@ func RollbackOnPanic(tx *sql.Tx) {
 }
 
 func OpenDB(config DatabaseConfig) *sql.DB {
+	db, err := openDB(config)
+	CheckErr(err)
+	return db
+}
+
+// OpenDBContext opens the database and checks, within ctx, that it can be reached.
+func OpenDBContext(ctx context.Context, config DatabaseConfig) (*sql.DB, error) {
+	db, err := openDB(config)
+	if err != nil {
+		return nil, err
+	}
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+func openDB(config DatabaseConfig) (*sql.DB, error) {
 	db, err := sql.Open(*config.DatabaseDriver, *config.DatasourceName)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	if config.MaxIdleConns != nil {
 		db.SetMaxIdleConns(*config.MaxIdleConns)
@@ -54,194 +171,177 @@ func OpenDB(config DatabaseConfig) *sql.DB {
 	if config.MaxConnLifetime != nil {
 		db.SetConnMaxLifetime(time.Duration(*config.MaxConnLifetime) * time.Second)
 	}
-	return db
+	return db, nil
 }
 
 func PrepareStmt(tx *sql.Tx, sql string) *sql.Stmt {
-	stmt, err := tx.Prepare(sql)
+	stmt, err := PrepareStmtContext(context.Background(), tx, sql)
 	CheckErr(err)
 	return stmt
 }
 
+func PrepareStmtContext(ctx context.Context, tx *sql.Tx, sql string) (*sql.Stmt, error) {
+	return tx.PrepareContext(ctx, sql)
+}
+
 func QuerySingleton(tx *sql.Tx, fields []interface{}, sql string, args ...interface{}) bool {
-	stmt, err := tx.Prepare(sql)
+	found, err := QuerySingletonContext(context.Background(), tx, fields, sql, args...)
 	CheckErr(err)
-	return QuerySingletonStmt(stmt, fields, args...)
+	return found
+}
+
+func QuerySingletonContext(ctx context.Context, tx *sql.Tx, fields []interface{}, sql string, args ...interface{}) (bool, error) {
+	stmt, err := tx.PrepareContext(ctx, sql)
+	if err != nil {
+		return false, err
+	}
+	defer stmt.Close()
+	return QuerySingletonStmtContext(ctx, stmt, fields, args...)
 }
 
 func QuerySingletonStmt(stmt *sql.Stmt, fields []interface{}, args ...interface{}) bool {
-	r, err := stmt.Query(args...)
+	found, err := QuerySingletonStmtContext(context.Background(), stmt, fields, args...)
 	CheckErr(err)
+	return found
+}
+
+func QuerySingletonStmtContext(ctx context.Context, stmt *sql.Stmt, fields []interface{}, args ...interface{}) (bool, error) {
+	r, err := stmt.QueryContext(ctx, args...)
+	if err != nil {
+		return false, err
+	}
 	defer r.Close()
 	if r.Next() {
-		CheckErr(r.Scan(fields...))
-		return true
-	} else {
-		return false
+		return true, r.Scan(fields...)
 	}
+	return false, r.Err()
 }
 
 func ExecSql(tx *sql.Tx, sql string, args ...interface{}) *sql.Result {
-	stmt, err := tx.Prepare(sql)
+	r, err := ExecSqlContext(context.Background(), tx, sql, args...)
 	CheckErr(err)
-	return ExecStmt(stmt, args...)
+	return &r
+}
+
+func ExecSqlContext(ctx context.Context, tx *sql.Tx, sql string, args ...interface{}) (sql.Result, error) {
+	stmt, err := tx.PrepareContext(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	defer stmt.Close()
+	return ExecStmtContext(ctx, stmt, args...)
 }
 
 func ExecStmt(stmt *sql.Stmt, args ...interface{}) *sql.Result {
-	r, err := stmt.Exec(args...)
+	r, err := ExecStmtContext(context.Background(), stmt, args...)
 	CheckErr(err)
 	return &r
 }
 
+func ExecStmtContext(ctx context.Context, stmt *sql.Stmt, args ...interface{}) (sql.Result, error) {
+	return stmt.ExecContext(ctx, args...)
+}
+
 func QuerySql(tx *sql.Tx, sql string, args ...interface{}) *sql.Rows {
-	stmt, err := tx.Prepare(sql)
+	r, err := QuerySqlContext(context.Background(), tx, sql, args...)
 	CheckErr(err)
-	return QueryStmt(stmt, args...)
+	return r
+}
+
+// QuerySqlContext runs a query. The statement it prepares is closed with the rows.
+func QuerySqlContext(ctx context.Context, tx *sql.Tx, sql string, args ...interface{}) (*sql.Rows, error) {
+	return tx.QueryContext(ctx, sql, args...)
 }
 
 func QueryStmt(stmt *sql.Stmt, args ...interface{}) *sql.Rows {
-	r, err := stmt.Query(args...)
+	r, err := QueryStmtContext(context.Background(), stmt, args...)
 	CheckErr(err)
 	return r
 }
 
+func QueryStmtContext(ctx context.Context, stmt *sql.Stmt, args ...interface{}) (*sql.Rows, error) {
+	return stmt.QueryContext(ctx, args...)
+}
+
 func Scan(r *sql.Rows, vars ...interface{}) {
 	CheckErr(r.Scan(vars...))
 }
 
 func FindStruct(tx *sql.Tx, template interface{}, sql string, queryParams ...interface{}) interface{} {
-	stmt, err := tx.Prepare(sql)
+	result, err := FindStructContext(context.Background(), tx, template, sql, queryParams...)
 	CheckErr(err)
-	defer stmt.Close()
-	return FindStructStmt(stmt, template, queryParams...)
+	return result
 }
 
-func FindStructStmt(stmt *sql.Stmt, template interface{}, queryParams ...interface{}) interface{} {
-	result := QueryStructStmt(stmt, template, queryParams...)
-	value := reflect.ValueOf(result)
-	if value.Len() == 0 {
-		objectType := reflect.TypeOf(template)
-		return reflect.New(reflect.PtrTo(objectType)).Elem().Interface()
-	} else {
-		o := value.Index(0)
-		return o.Addr().Interface()
+func FindStructContext(ctx context.Context, tx *sql.Tx, template interface{}, sql string, queryParams ...interface{}) (interface{}, error) {
+	stmt, err := tx.PrepareContext(ctx, sql)
+	if err != nil {
+		return nil, err
 	}
-}
-
-func QueryStruct(tx *sql.Tx, template interface{}, sql string, queryParams ...interface{}) interface{} {
-	stmt, err := tx.Prepare(sql)
-	CheckErr(err)
 	defer stmt.Close()
-	return QueryStructStmt(stmt, template, queryParams...)
+	return FindStructStmtContext(ctx, stmt, template, queryParams...)
 }
 
-func QueryStructStmt(stmt *sql.Stmt, template interface{}, queryParams ...interface{}) interface{} {
-	objectType := reflect.TypeOf(template)
-	fields := listStructFields(objectType, 0)
-	r, e := stmt.Query(queryParams...)
-	CheckErr(e)
-	count := len(fields)
-	cols, e := r.Columns()
-	CheckErr(e)
-	if len(cols) > count {
-		panic("Result set column count greater than struct field count")
-	}
-	buffer := make([]interface{}, len(fields))
-	for i := range fields {
-		buffer[i] = reflect.New(fields[i].Type).Interface()
-	}
-	arr := reflect.MakeSlice(reflect.SliceOf(objectType), 0, 0)
-	for r.Next() {
-		CheckErr(r.Scan(buffer...))
-		object := reflect.New(objectType).Elem()
-		bufferToFields(object, buffer, 0)
-		arr = reflect.Append(arr, object)
-	}
-	r.Close()
-	return arr.Interface()
+func FindStructStmt(stmt *sql.Stmt, template interface{}, queryParams ...interface{}) interface{} {
+	result, err := FindStructStmtContext(context.Background(), stmt, template, queryParams...)
+	CheckErr(err)
+	return result
 }
 
-func bufferToFields(object reflect.Value, buffer []interface{}, offset int) int {
-	instanceType := object.Type()
-	instance := object
-	isPtrStruct := object.Kind() == reflect.Ptr
-	if isPtrStruct {
-		instanceType = object.Type().Elem()
-		instance = reflect.Indirect(reflect.New(instanceType))
+// FindStructStmtContext returns a pointer to the first row, or a nil pointer of the
+// template type when there is none.
+func FindStructStmtContext(ctx context.Context, stmt *sql.Stmt, template interface{}, queryParams ...interface{}) (interface{}, error) {
+	result, err := QueryStructStmtContext(ctx, stmt, template, queryParams...)
+	if err != nil {
+		return nil, err
 	}
-	n := 0
-	created := !isPtrStruct
-	for i := 0; i < instanceType.NumField(); i++ {
-		of := instance.Field(i)
-		if isArrayField(of.Type()) {
-			continue
-		}
-		v := buffer[n+offset]
-		indirect := reflect.ValueOf(v).Elem()
-		if indirect.Kind() == reflect.Ptr && !indirect.IsNil() && !created {
-			object.Set(instance.Addr())
-			created = true
-		}
-		if isStructPtrField(of) {
-			n += bufferToFields(of, buffer, n+offset)
-		} else if isStructField(of) {
-			n += bufferToFields(of, buffer, n+offset)
-		} else {
-			of.Set(indirect)
-			n++
-		}
+	value := reflect.ValueOf(result)
+	if value.Len() == 0 {
+		objectType := reflect.TypeOf(template)
+		return reflect.New(reflect.PtrTo(objectType)).Elem().Interface(), nil
 	}
-	return n
-}
-
-func isArrayField(t reflect.Type) bool {
-	kind := t.Kind()
-	return (kind == reflect.Slice || kind == reflect.Array) && t != byteArrayType
+	return value.Index(0).Addr().Interface(), nil
 }
 
-func isStructField(value reflect.Value) bool {
-	return value.Kind() == reflect.Struct && value.Type() != timeType
+func QueryStruct(tx *sql.Tx, template interface{}, sql string, queryParams ...interface{}) interface{} {
+	result, err := QueryStructContext(context.Background(), tx, template, sql, queryParams...)
+	CheckErr(err)
+	return result
 }
 
-func isStructPtrField(value reflect.Value) bool {
-	if value.Kind() == reflect.Ptr {
-		instanceType := value.Type().Elem()
-		return instanceType.Kind() == reflect.Struct && instanceType != timeType
-	} else {
-		return false
+func QueryStructContext(ctx context.Context, tx *sql.Tx, template interface{}, sql string, queryParams ...interface{}) (interface{}, error) {
+	stmt, err := tx.PrepareContext(ctx, sql)
+	if err != nil {
+		return nil, err
 	}
-
+	defer stmt.Close()
+	return QueryStructStmtContext(ctx, stmt, template, queryParams...)
 }
 
-func listStructFields(structType reflect.Type, offset int) []reflect.StructField {
-	fields := make([]reflect.StructField, 0)
-	return addStructFields(structType, fields, offset)
+func QueryStructStmt(stmt *sql.Stmt, template interface{}, queryParams ...interface{}) interface{} {
+	result, err := QueryStructStmtContext(context.Background(), stmt, template, queryParams...)
+	CheckErr(err)
+	return result
 }
 
-func addStructFields(structType reflect.Type, fields []reflect.StructField, offset int) []reflect.StructField {
-	if structType.Kind() == reflect.Ptr {
-		structType = structType.Elem()
+// QueryStructStmtContext returns the rows as a slice of the template type. The result columns
+// map to the struct columns in order, and struct columns past the last one are left zero.
+func QueryStructStmtContext(ctx context.Context, stmt *sql.Stmt, template interface{}, queryParams ...interface{}) (interface{}, error) {
+	objectType := reflect.TypeOf(template)
+	r, err := stmt.QueryContext(ctx, queryParams...)
+	if err != nil {
+		return nil, err
 	}
-	for i := offset; i < structType.NumField(); i++ {
-		f := structType.Field(i)
-		structPtr := false
-		kind := f.Type.Kind()
-		if kind == reflect.Ptr {
-			instanceType := f.Type.Elem()
-			if instanceType.Kind() == reflect.Struct && instanceType != timeType {
-				structPtr = true
-				fields = addStructFields(instanceType, fields, 0)
-			}
-		}
-		if !structPtr {
-			if kind == reflect.Struct && f.Type != timeType {
-				fields = addStructFields(f.Type, fields, 0)
-			} else if !isArrayField(f.Type) {
-				fields = append(fields, f)
-			}
-		}
+	defer r.Close()
+	arr := reflect.MakeSlice(reflect.SliceOf(objectType), 0, 0)
+	err = scanStructs(r, objectType, func(object reflect.Value) error {
+		arr = reflect.Append(arr, object)
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
-	return fields
+	return arr.Interface(), nil
 }
 
 type FieldInfo struct {
@@ -250,10 +350,18 @@ type FieldInfo struct {
 }
 
 func ExecStruct(tx *sql.Tx, sql string, data interface{}) int64 {
-	stmt, e := tx.Prepare(sql)
-	CheckErr(e)
+	id, err := ExecStructContext(context.Background(), tx, sql, data)
+	CheckErr(err)
+	return id
+}
+
+func ExecStructContext(ctx context.Context, tx *sql.Tx, sql string, data interface{}) (int64, error) {
+	stmt, err := tx.PrepareContext(ctx, sql)
+	if err != nil {
+		return 0, err
+	}
 	defer stmt.Close()
-	return ExecStructStmt(stmt, data)
+	return ExecStructStmtOffContext(ctx, stmt, data, 0)
 }
 
 func ExecStructStmt(stmt *sql.Stmt, data interface{}) int64 {
@@ -261,98 +369,97 @@ func ExecStructStmt(stmt *sql.Stmt, data interface{}) int64 {
 }
 
 func ExecStructStmtOff(stmt *sql.Stmt, data interface{}, offset int) int64 {
-	objectType := reflect.TypeOf(data)
-	fields := listStructFields(objectType, offset)
-	buffer := make([]interface{}, len(fields))
-	value := reflect.ValueOf(data)
-	fieldsToBuffer(value, buffer, offset)
-	r, err := stmt.Exec(buffer...)
+	id, err := ExecStructStmtOffContext(context.Background(), stmt, data, offset)
 	CheckErr(err)
-	lastId, _ := r.LastInsertId()
-	return lastId
+	return id
 }
 
-func fieldsToBuffer(value reflect.Value, buffer []interface{}, offset int) {
-	fields := buildObjectFields(value)
-	for i := 0; i < len(buffer); i++ {
-		f := fields[i+offset]
-		buffer[i] = f.Interface()
+// ExecStructStmtOffContext runs the statement with the struct fields from offset on as
+// arguments, and returns the last insert id when the driver reports one.
+func ExecStructStmtOffContext(ctx context.Context, stmt *sql.Stmt, data interface{}, offset int) (int64, error) {
+	args, err := structArgs(data, offset)
+	if err != nil {
+		return 0, err
+	}
+	r, err := stmt.ExecContext(ctx, args...)
+	if err != nil {
+		return 0, err
 	}
+	lastId, _ := r.LastInsertId()
+	return lastId, nil
 }
 
-func buildObjectFields(value reflect.Value) []reflect.Value {
-	fields := make([]reflect.Value, 0)
-	for i := 0; i < value.NumField(); i++ {
-		f := value.Field(i)
-		if f.Kind() == reflect.Struct {
-			fields = append(fields, buildObjectFields(f)...)
-		} else {
-			fields = append(fields, f)
-		}
-	}
-	return fields
+// structArgs returns the struct columns from offset on as statement arguments.
+func structArgs(data interface{}, offset int) ([]interface{}, error) {
+	return mappingOf(reflect.TypeOf(data)).args(reflect.ValueOf(data), offset)
 }
 
 func ForInsert(template interface{}, offset int) string {
+	return ForInsertDialect(PostgresDialect{}, template, offset)
+}
+
+// ForInsertDialect returns the column list and values clause of an insert of the template
+// fields from offset on, with the placeholders of the dialect.
+func ForInsertDialect(dialect Dialect, template interface{}, offset int) string {
 	objectType := reflect.TypeOf(template)
 	buffer := bytes.NewBufferString("(")
-	buffer.WriteString(forSelect(objectType, nil, offset))
+	buffer.WriteString(forSelect(dialect, objectType, nil, offset))
 	buffer.WriteString(") values(")
-	n := 0
-	for i := offset; i < objectType.NumField(); i++ {
-		if n > 0 {
+	for i := range mappingOf(objectType).columnNames(offset) {
+		if i > 0 {
 			buffer.WriteString(", ")
 		}
-		n++
-		buffer.WriteString(fmt.Sprintf("$%d", n))
+		buffer.WriteString(dialect.Placeholder(i + 1))
 	}
 	buffer.WriteString(")")
 	return buffer.String()
 }
 
 func ForUpdate(template interface{}, offset int, firstNum int) string {
-	objectType := reflect.TypeOf(template)
+	return ForUpdateDialect(PostgresDialect{}, template, offset, firstNum)
+}
+
+// ForUpdateDialect returns the set clause of an update of the template fields from offset
+// on, numbering the placeholders of the dialect from firstNum.
+func ForUpdateDialect(dialect Dialect, template interface{}, offset int, firstNum int) string {
 	buffer := bytes.NewBufferString("")
-	for i := 0; i < objectType.NumField()-offset; i++ {
+	for i, column := range mappingOf(reflect.TypeOf(template)).columnNames(offset) {
 		if i > 0 {
 			buffer.WriteString(", ")
 		}
-		field := objectType.Field(i + offset)
-		if v, ok := field.Tag.Lookup("sql"); ok {
-			buffer.WriteString(v)
-		} else {
-			buffer.WriteString(field.Name)
-		}
-		buffer.WriteString(fmt.Sprintf(" = $%d", i+firstNum))
+		buffer.WriteString(quoteFolded(dialect, column))
+		buffer.WriteString(" = ")
+		buffer.WriteString(dialect.Placeholder(i + firstNum))
 	}
 	return buffer.String()
 }
 
 func ForSelect(template interface{}, offset int, alias ...string) string {
+	return ForSelectDialect(PostgresDialect{}, template, offset, alias...)
+}
+
+// ForSelectDialect returns the quoted columns of the template fields from offset on,
+// qualified by the alias if any.
+func ForSelectDialect(dialect Dialect, template interface{}, offset int, alias ...string) string {
 	objectType := reflect.TypeOf(template)
 	if len(alias) == 0 {
-		return forSelect(objectType, nil, offset)
+		return forSelect(dialect, objectType, nil, offset)
 	} else {
-		return forSelect(objectType, &alias[0], offset)
+		return forSelect(dialect, objectType, &alias[0], offset)
 	}
 }
 
-func forSelect(objectType reflect.Type, alias *string, offset int) string {
+func forSelect(dialect Dialect, objectType reflect.Type, alias *string, offset int) string {
 	buffer := bytes.NewBufferString("")
-	for i := 0; i < objectType.NumField()-offset; i++ {
+	for i, column := range mappingOf(objectType).columnNames(offset) {
 		if i > 0 {
 			buffer.WriteString(", ")
 		}
 		if alias != nil {
-			buffer.WriteString(*alias)
+			buffer.WriteString(quoteFolded(dialect, *alias))
 			buffer.WriteString(".")
 		}
-		field := objectType.Field(i + offset)
-		if v, ok := field.Tag.Lookup("sql"); ok {
-			buffer.WriteString(v)
-		} else {
-			buffer.WriteString(field.Name)
-		}
+		buffer.WriteString(quoteFolded(dialect, column))
 	}
 	return buffer.String()
 }
@@ -362,3 +469,12 @@ func ExecuteDatabase(config DatabaseConfig, delegate func(db *sql.DB)) {
 	defer CloseDB(db)
 	delegate(db)
 }
+
+func ExecuteDatabaseContext(ctx context.Context, config DatabaseConfig, delegate func(db *sql.DB) error) error {
+	db, err := OpenDBContext(ctx, config)
+	if err != nil {
+		return err
+	}
+	defer CloseDB(db)
+	return delegate(db)
+}
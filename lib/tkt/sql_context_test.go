@@ -0,0 +1,212 @@
+package tkt
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+type contextRow struct {
+	Id   int64
+	Name string
+}
+
+func beginFakeTx(t *testing.T, config DatabaseConfig) *TxCtx {
+	db, err := OpenDBContext(context.Background(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	tx, err := db.BeginTx(context.Background(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { tx.Rollback() })
+	return NewTxCtx(config, tx, db)
+}
+
+func TestExecuteTransactionalContextCommits(t *testing.T) {
+	fake, config := newFakeDB(t)
+	ran := false
+	r, err := ExecuteTransactionalContext(context.Background(), config, func(txCtx *TxCtx) (interface{}, error) {
+		txCtx.AddFuture(func() { ran = true })
+		_, err := txCtx.ExecSqlContext(txCtx.Context(), "insert into t values ($1)", 1)
+		return "done", err
+	})
+	if err != nil || r != "done" {
+		t.Fatalf("got %v, %v", r, err)
+	}
+	if commits, rollbacks, _ := fake.counts(); commits != 1 || rollbacks != 0 || !ran {
+		t.Errorf("commits %d, rollbacks %d, future ran %v", commits, rollbacks, ran)
+	}
+	if got := fake.statements(); !reflect.DeepEqual(got, []string{"insert into t values ($1) [1]"}) {
+		t.Errorf("statements %q", got)
+	}
+}
+
+func TestExecuteTransactionalContextRollsBackOnError(t *testing.T) {
+	fake, config := newFakeDB(t)
+	failure := errors.New("duplicate key")
+	fake.fails("insert into t values ($1)", failure)
+	ran := false
+	r, err := ExecuteTransactionalContext(context.Background(), config, func(txCtx *TxCtx) (interface{}, error) {
+		txCtx.AddFuture(func() { ran = true })
+		_, err := txCtx.ExecSqlContext(txCtx.Context(), "insert into t values ($1)", 1)
+		return "done", err
+	})
+	if !errors.Is(err, failure) || r != nil {
+		t.Fatalf("got %v, %v", r, err)
+	}
+	if commits, rollbacks, _ := fake.counts(); commits != 0 || rollbacks != 1 || ran {
+		t.Errorf("commits %d, rollbacks %d, future ran %v", commits, rollbacks, ran)
+	}
+}
+
+func TestExecuteTransactionalContextRollsBackOnPanic(t *testing.T) {
+	fake, config := newFakeDB(t)
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("recovered %v", r)
+			}
+		}()
+		ExecuteTransactionalContext(context.Background(), config, func(txCtx *TxCtx) (interface{}, error) {
+			panic("boom")
+		})
+	}()
+	if commits, rollbacks, _ := fake.counts(); commits != 0 || rollbacks != 1 {
+		t.Errorf("commits %d, rollbacks %d", commits, rollbacks)
+	}
+}
+
+func TestExecuteTransactionalContextCanceled(t *testing.T) {
+	fake, config := newFakeDB(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	called := false
+	_, err := ExecuteTransactionalContext(ctx, config, func(txCtx *TxCtx) (interface{}, error) {
+		called = true
+		return nil, nil
+	})
+	if !errors.Is(err, context.Canceled) || called || fake.beginCount != 0 {
+		t.Errorf("got %v, callback called %v, %d transactions begun", err, called, fake.beginCount)
+	}
+
+	ctx, cancel = context.WithCancel(context.Background())
+	_, err = ExecuteTransactionalContext(ctx, config, func(txCtx *TxCtx) (interface{}, error) {
+		cancel()
+		return txCtx.ExecSqlContext(txCtx.Context(), "insert into t values ($1)", 1)
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("got %v, want the cancellation", err)
+	}
+	if commits, _, _ := fake.counts(); commits != 0 || len(fake.statements()) != 0 {
+		t.Errorf("commits %d, statements %q", commits, fake.statements())
+	}
+}
+
+func TestQueryContextHelpers(t *testing.T) {
+	fake, config := newFakeDB(t)
+	fake.returns("select id, name from t where id = $1", []string{"id", "name"}, []driver.Value{int64(7), "seven"})
+	fake.returns("select id, name from t where id < 0", []string{"id", "name"})
+	fake.returns("select id, name, extra from t", []string{"id", "name", "extra"}, []driver.Value{int64(1), "one", "x"})
+	txCtx := beginFakeTx(t, config)
+	ctx := context.Background()
+
+	var id int64
+	var name string
+	found, err := txCtx.QuerySingletonContext(ctx, "select id, name from t where id = $1", []interface{}{&id, &name}, 7)
+	if err != nil || !found || id != 7 || name != "seven" {
+		t.Errorf("got %v %v %d %s", found, err, id, name)
+	}
+	found, err = txCtx.QuerySingletonContext(ctx, "select id, name from t where id < 0", []interface{}{&id, &name})
+	if err != nil || found {
+		t.Errorf("got %v %v, want not found", found, err)
+	}
+
+	rows, err := QueryStructContext(ctx, txCtx.Tx(), contextRow{}, "select id, name from t where id = $1", 7)
+	if want := []contextRow{{7, "seven"}}; err != nil || !reflect.DeepEqual(rows, want) {
+		t.Errorf("got %v %v", rows, err)
+	}
+	one, err := txCtx.FindStructContext(ctx, contextRow{}, "select id, name from t where id < 0")
+	if err != nil || one.(*contextRow) != nil {
+		t.Errorf("got %v %v, want a nil *contextRow", one, err)
+	}
+	if _, err := QueryStructContext(ctx, txCtx.Tx(), contextRow{}, "select id, name, extra from t"); err == nil {
+		t.Error("more columns than fields accepted")
+	}
+}
+
+func TestQuerySqlContextClosesItsStatement(t *testing.T) {
+	fake, config := newFakeDB(t)
+	fake.returns("select id, name from t", []string{"id", "name"}, []driver.Value{int64(1), "one"}, []driver.Value{int64(2), "two"})
+	txCtx := beginFakeTx(t, config)
+	for i := 0; i < 3; i++ {
+		rows, err := QuerySqlContext(context.Background(), txCtx.Tx(), "select id, name from t")
+		if err != nil {
+			t.Fatal(err)
+		}
+		count := 0
+		for rows.Next() {
+			count++
+		}
+		rows.Close()
+		if _, _, open := fake.counts(); count != 2 || open != 0 {
+			t.Errorf("read %d rows, %d statements left open", count, open)
+		}
+	}
+	if _, err := QuerySqlContext(context.Background(), txCtx.Tx(), "select id, name from t where"); err == nil {
+		t.Error("an unknown query succeeded")
+	}
+	if _, _, open := fake.counts(); open != 0 {
+		t.Errorf("%d statements left open by a failed query", open)
+	}
+}
+
+func TestContextHelpersReturnErrors(t *testing.T) {
+	fake, config := newFakeDB(t)
+	prepareFailure := errors.New("syntax error")
+	execFailure := errors.New("constraint violated")
+	fake.fails("prepare select nonsense", prepareFailure)
+	fake.fails("delete from t", execFailure)
+	txCtx := beginFakeTx(t, config)
+	ctx := context.Background()
+
+	if _, err := QueryStructContext(ctx, txCtx.Tx(), contextRow{}, "select nonsense"); !errors.Is(err, prepareFailure) {
+		t.Errorf("QueryStructContext: %v", err)
+	}
+	if _, err := txCtx.QuerySqlContext(ctx, "select nonsense"); !errors.Is(err, prepareFailure) {
+		t.Errorf("QuerySqlContext: %v", err)
+	}
+	if _, err := ExecSqlContext(ctx, txCtx.Tx(), "delete from t"); !errors.Is(err, execFailure) {
+		t.Errorf("ExecSqlContext: %v", err)
+	}
+	if _, err := txCtx.UpdateEntityContext(ctx, "s", struct{ Name string }{"a"}); err == nil {
+		t.Error("UpdateEntityContext accepted an entity without Id")
+	}
+	func() {
+		defer func() {
+			if r := recover(); r != execFailure {
+				t.Errorf("ExecSql recovered %v", r)
+			}
+		}()
+		txCtx.ExecSql("delete from t")
+	}()
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := txCtx.ExecSqlContext(canceled, "update t set name = $1", "a"); !errors.Is(err, context.Canceled) {
+		t.Errorf("ExecSqlContext on a canceled context: %v", err)
+	}
+	if _, err := QuerySqlContext(canceled, txCtx.Tx(), "select id, name from t"); !errors.Is(err, context.Canceled) {
+		t.Errorf("QuerySqlContext on a canceled context: %v", err)
+	}
+	if got := fake.statements(); !reflect.DeepEqual(got, []string{"delete from t []", "delete from t []"}) {
+		t.Errorf("statements %q", got)
+	}
+	if _, err := OpenDBContext(canceled, config); !errors.Is(err, context.Canceled) {
+		t.Errorf("OpenDBContext on a canceled context: %v", err)
+	}
+}
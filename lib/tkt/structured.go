@@ -0,0 +1,345 @@
+package tkt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+const (
+	FormatText   = "text"
+	FormatJson   = "json"
+	FormatLogfmt = "logfmt"
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+var callerRegexp = regexp.MustCompile(`^([^\s:]+\.go:\d+): `)
+
+func (o Level) String() string {
+	if o < LevelDebug || o > LevelError {
+		return fmt.Sprintf("level(%d)", int(o))
+	}
+	return levelNames[o]
+}
+
+func ParseLevel(s string) (Level, bool) {
+	switch strings.ToLower(s) {
+	case "debug", "trace":
+		return LevelDebug, true
+	case "info":
+		return LevelInfo, true
+	case "warn", "warning":
+		return LevelWarn, true
+	case "error", "fatal":
+		return LevelError, true
+	default:
+		return LevelInfo, false
+	}
+}
+
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// StructuredLogger builds one record per call, with a time, a level, a message and
+// key-value fields, and hands it to a LogPipeline. Loggers derived with With or WithTag
+// share the pipeline and level.
+type StructuredLogger struct {
+	pipeline *LogPipeline
+	level    Level
+	tag      string
+	fields   []Field
+}
+
+func (o *StructuredLogger) Level() Level {
+	return o.level
+}
+
+func (o *StructuredLogger) Pipeline() *LogPipeline {
+	return o.pipeline
+}
+
+func (o *StructuredLogger) Enabled(level Level) bool {
+	return level >= o.level
+}
+
+// With returns a logger that adds the given key-value pairs to every record.
+func (o *StructuredLogger) With(keyValues ...interface{}) *StructuredLogger {
+	fields := make([]Field, len(o.fields), len(o.fields)+len(keyValues)/2)
+	copy(fields, o.fields)
+	fields = append(fields, toFields(keyValues)...)
+	return &StructuredLogger{pipeline: o.pipeline, level: o.level, tag: o.tag, fields: fields}
+}
+
+// WithTag returns a logger whose records carry the given tag, as the Logger(tag) ones do.
+func (o *StructuredLogger) WithTag(tag string) *StructuredLogger {
+	return &StructuredLogger{pipeline: o.pipeline, level: o.level, tag: tag, fields: o.fields}
+}
+
+func (o *StructuredLogger) Debug(msg string, keyValues ...interface{}) {
+	o.Log(LevelDebug, msg, keyValues...)
+}
+
+func (o *StructuredLogger) Info(msg string, keyValues ...interface{}) {
+	o.Log(LevelInfo, msg, keyValues...)
+}
+
+func (o *StructuredLogger) Warn(msg string, keyValues ...interface{}) {
+	o.Log(LevelWarn, msg, keyValues...)
+}
+
+func (o *StructuredLogger) Error(msg string, keyValues ...interface{}) {
+	o.Log(LevelError, msg, keyValues...)
+}
+
+func (o *StructuredLogger) Log(level Level, msg string, keyValues ...interface{}) {
+	o.log(level, msg, "", keyValues)
+}
+
+func (o *StructuredLogger) log(level Level, msg string, caller string, keyValues []interface{}) {
+	if !o.Enabled(level) {
+		return
+	}
+	fields := o.fields
+	if len(keyValues) > 0 {
+		fields = make([]Field, 0, len(o.fields)+len(keyValues)/2)
+		fields = append(fields, o.fields...)
+		fields = append(fields, toFields(keyValues)...)
+	}
+	o.pipeline.Dispatch(&LogRecord{Time: time.Now(), Level: level, Tag: o.tag, Message: msg, Caller: caller, Fields: fields})
+}
+
+// StdLogger adapts the structured logger to *log.Logger so that existing Printf style call
+// sites emit records at the given level. The caller is kept in the record.
+func (o *StructuredLogger) StdLogger(level Level) *log.Logger {
+	return log.New(&structuredWriter{logger: o, level: level}, "", log.Lshortfile)
+}
+
+type structuredWriter struct {
+	logger *StructuredLogger
+	level  Level
+}
+
+func (o *structuredWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimRight(string(p), "\r\n")
+	if m := callerRegexp.FindStringSubmatch(msg); m != nil {
+		o.logger.log(o.level, msg[len(m[0]):], m[1], nil)
+	} else {
+		o.logger.log(o.level, msg, "", nil)
+	}
+	return len(p), nil
+}
+
+func toFields(keyValues []interface{}) []Field {
+	fields := make([]Field, 0, len(keyValues)/2+1)
+	for i := 0; i < len(keyValues); i += 2 {
+		if i+1 == len(keyValues) {
+			fields = append(fields, Field{"!BADKEY", keyValues[i]})
+			break
+		}
+		key, ok := keyValues[i].(string)
+		if !ok {
+			key = fmt.Sprint(keyValues[i])
+		}
+		fields = append(fields, Field{key, keyValues[i+1]})
+	}
+	return fields
+}
+
+func fieldValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case error:
+		return t.Error()
+	case fmt.Stringer:
+		return t.String()
+	default:
+		return v
+	}
+}
+
+// FormatRecord renders a record as one line in the given format. The text format is the
+// one of the tagged loggers: "tag: date time file:line: message", followed by the fields.
+func FormatRecord(buf *bytes.Buffer, format string, record *LogRecord) {
+	switch format {
+	case FormatJson:
+		writeJsonRecord(buf, record)
+	case FormatLogfmt:
+		writeLogfmtRecord(buf, record)
+	default:
+		writeTextRecord(buf, record)
+	}
+}
+
+func recordFields(record *LogRecord) []Field {
+	fields := make([]Field, 0, 5+len(record.Fields))
+	fields = append(fields, Field{"time", record.Time.Format(time.RFC3339Nano)}, Field{"level", record.Level.String()}, Field{"msg", record.Message})
+	if record.Tag != "" && record.Tag != record.Level.String() {
+		fields = append(fields, Field{"tag", record.Tag})
+	}
+	if record.Caller != "" {
+		fields = append(fields, Field{"caller", record.Caller})
+	}
+	return append(fields, record.Fields...)
+}
+
+func writeJsonRecord(buf *bytes.Buffer, record *LogRecord) {
+	buf.WriteByte('{')
+	for i, f := range recordFields(record) {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		key, _ := json.Marshal(f.Key)
+		buf.Write(key)
+		buf.WriteByte(':')
+		value, err := json.Marshal(fieldValue(f.Value))
+		if err != nil {
+			value, _ = json.Marshal(fmt.Sprint(f.Value))
+		}
+		buf.Write(value)
+	}
+	buf.WriteString("}\n")
+}
+
+func writeLogfmtRecord(buf *bytes.Buffer, record *LogRecord) {
+	for i, f := range recordFields(record) {
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		writeLogfmtField(buf, f)
+	}
+	buf.WriteByte('\n')
+}
+
+func writeTextRecord(buf *bytes.Buffer, record *LogRecord) {
+	tag := record.Tag
+	if tag == "" {
+		tag = record.Level.String()
+	}
+	buf.WriteString(tag)
+	buf.WriteString(": ")
+	buf.WriteString(record.Time.Format("2006/01/02 15:04:05 "))
+	if record.Caller != "" {
+		buf.WriteString(record.Caller)
+		buf.WriteString(": ")
+	}
+	buf.WriteString(record.Message)
+	for _, f := range record.Fields {
+		buf.WriteByte(' ')
+		writeLogfmtField(buf, f)
+	}
+	buf.WriteByte('\n')
+}
+
+func writeLogfmtField(buf *bytes.Buffer, f Field) {
+	buf.WriteString(logfmtKey(f.Key))
+	buf.WriteByte('=')
+	buf.WriteString(logfmtValue(fieldValue(f.Value)))
+}
+
+func logfmtKey(key string) string {
+	return strings.Map(func(r rune) rune {
+		if r <= ' ' || r == '=' || r == '"' {
+			return '_'
+		}
+		return r
+	}, key)
+}
+
+func logfmtValue(v interface{}) string {
+	var s string
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		s = t
+	case bool:
+		return strconv.FormatBool(t)
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(t)
+	default:
+		b, err := json.Marshal(t)
+		if err != nil {
+			s = fmt.Sprint(t)
+		} else {
+			s = string(b)
+		}
+	}
+	if s == "" || strings.ContainsAny(s, " =\"\t\r\n\\") {
+		return strconv.Quote(s)
+	}
+	return s
+}
+
+// NewStructuredLogger returns a logger writing to w in the given format.
+func NewStructuredLogger(w io.Writer, format string, level Level) *StructuredLogger {
+	pipeline := NewLogPipeline()
+	pipeline.AddSink(NewWriterSink(w, format), LevelDebug)
+	return NewPipelineLogger(pipeline, level)
+}
+
+func NewPipelineLogger(pipeline *LogPipeline, level Level) *StructuredLogger {
+	return &StructuredLogger{pipeline: pipeline, level: level}
+}
+
+var structuredLogger = NewStructuredLogger(os.Stdout, FormatLogfmt, LevelInfo)
+
+// StructuredLog returns the process wide structured logger, configured by InitLoggers.
+func StructuredLog() *StructuredLogger {
+	return structuredLogger
+}
+
+func SetStructuredLog(logger *StructuredLogger) {
+	structuredLogger = logger
+}
+
+type loggerContextKey struct{}
+
+type contextLogger struct {
+	logger *StructuredLogger
+	mux    sync.Mutex
+}
+
+// WithLogger returns a context carrying a logger, usually one holding request fields.
+func WithLogger(ctx context.Context, logger *StructuredLogger) context.Context {
+	return context.WithValue(ctx, loggerContextKey{}, &contextLogger{logger: logger})
+}
+
+// ContextLogger returns the logger stored in ctx by WithLogger, or the process wide one.
+func ContextLogger(ctx context.Context) *StructuredLogger {
+	if holder, ok := ctx.Value(loggerContextKey{}).(*contextLogger); ok {
+		holder.mux.Lock()
+		defer holder.mux.Unlock()
+		return holder.logger
+	}
+	return structuredLogger
+}
+
+// AddContextFields adds key-value pairs to the logger stored in ctx, so that they show in
+// every later record of the request, including the one logged by InterceptLogging.
+func AddContextFields(ctx context.Context, keyValues ...interface{}) {
+	if holder, ok := ctx.Value(loggerContextKey{}).(*contextLogger); ok {
+		holder.mux.Lock()
+		defer holder.mux.Unlock()
+		holder.logger = holder.logger.With(keyValues...)
+	}
+}
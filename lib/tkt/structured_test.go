@@ -0,0 +1,122 @@
+package tkt
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var logfmtTimeRegexp = regexp.MustCompile(`^time=\S+ `)
+
+func jsonRecords(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	records := make([]map[string]interface{}, 0)
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("%q: %v", line, err)
+		}
+		if _, ok := record["time"].(string); !ok {
+			t.Errorf("%q has no time", line)
+		}
+		delete(record, "time")
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestStructuredLoggerJson(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewStructuredLogger(buf, FormatJson, LevelInfo).With("service", "core")
+	logger.Debug("hidden")
+	logger.Info("started", "port", 8080, "tls", false)
+	logger.With("requestId", "r1").Warn("slow", "error", errors.New("timeout"), "odd")
+	logger.Error("done")
+	want := []map[string]interface{}{
+		{"level": "info", "msg": "started", "service": "core", "port": 8080.0, "tls": false},
+		{"level": "warn", "msg": "slow", "service": "core", "requestId": "r1", "error": "timeout", "!BADKEY": "odd"},
+		{"level": "error", "msg": "done", "service": "core"},
+	}
+	if got := jsonRecords(t, buf); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestStructuredLoggerLogfmt(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewStructuredLogger(buf, FormatLogfmt, LevelDebug)
+	logger.Debug("a message", "empty", "", "quoted", `say "hi"`, "key with=space", 1.5, "nil", nil, "list", []int{1, 2})
+	want := `level=debug msg="a message" empty="" quoted="say \"hi\"" key_with_space=1.5 nil=null list=[1,2]` + "\n"
+	if got := logfmtTimeRegexp.ReplaceAllString(buf.String(), ""); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	for s, want := range map[string]Level{"debug": LevelDebug, "TRACE": LevelDebug, "info": LevelInfo, "Warning": LevelWarn, "fatal": LevelError} {
+		if level, ok := ParseLevel(s); !ok || level != want {
+			t.Errorf("ParseLevel(%q) = %v, %t", s, level, ok)
+		}
+	}
+	if _, ok := ParseLevel("loud"); ok {
+		t.Error("ParseLevel accepted loud")
+	}
+	if s := Level(7).String(); s != "level(7)" {
+		t.Errorf("Level(7) = %q", s)
+	}
+}
+
+func TestContextLogger(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewStructuredLogger(buf, FormatJson, LevelInfo)
+	ctx := WithLogger(context.Background(), logger.With("requestId", "r1"))
+	AddContextFields(ctx, "sender", "acme")
+	ContextLogger(ctx).Info("checked")
+	AddContextFields(context.Background(), "ignored", true)
+	if ContextLogger(context.Background()) != StructuredLog() {
+		t.Error("a context without a logger does not fall back to the process logger")
+	}
+	want := []map[string]interface{}{{"level": "info", "msg": "checked", "requestId": "r1", "sender": "acme"}}
+	if got := jsonRecords(t, buf); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestInterceptLogging(t *testing.T) {
+	buf := &bytes.Buffer{}
+	logger := NewStructuredLogger(buf, FormatJson, LevelInfo)
+	handler := InterceptLogging(func(w http.ResponseWriter, r *http.Request) {
+		AddContextFields(r.Context(), "sender", "acme")
+		ContextLogger(r.Context()).Info("handled")
+		w.WriteHeader(http.StatusTeapot)
+	})
+	r := httptest.NewRequest(http.MethodPost, "/validate", nil)
+	r = r.WithContext(WithLogger(r.Context(), logger))
+	r.Header.Set("X-Request-Id", "r1")
+	w := httptest.NewRecorder()
+	handler(w, r)
+	if id := w.Header().Get("X-Request-Id"); id != "r1" {
+		t.Errorf("X-Request-Id = %q", id)
+	}
+	records := jsonRecords(t, buf)
+	if len(records) != 2 {
+		t.Fatalf("%d records: %v", len(records), records)
+	}
+	if !reflect.DeepEqual(records[0], map[string]interface{}{"level": "info", "msg": "handled", "requestId": "r1", "sender": "acme"}) {
+		t.Errorf("handler record %v", records[0])
+	}
+	completed := records[1]
+	if completed["msg"] != "request completed" || completed["requestId"] != "r1" || completed["sender"] != "acme" ||
+		completed["status"] != 418.0 || completed["method"] != "POST" || completed["path"] != "/validate" {
+		t.Errorf("completion record %v", completed)
+	}
+}
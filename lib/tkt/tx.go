@@ -1,16 +1,22 @@
 package tkt
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"github.com/lib/pq"
+	"io"
 	"net/http"
 	"reflect"
+	"time"
 )
 
 type Future func()
 
 type TxCtx struct {
+	ctx            context.Context
 	databaseConfig DatabaseConfig
 	tx             *sql.Tx
 	db             *sql.DB
@@ -19,7 +25,9 @@ type TxCtx struct {
 	autoIdMap      map[string]*sql.Stmt
 	updMap         map[string]*sql.Stmt
 	delMap         map[string]*sql.Stmt
+	upsMap         map[string]*sql.Stmt
 	sequences      *Sequences
+	dialect        Dialect
 	future         []Future
 }
 
@@ -31,6 +39,10 @@ func (o *TxCtx) Db() *sql.DB {
 	return o.db
 }
 
+func (o *TxCtx) Dialect() Dialect {
+	return o.dialect
+}
+
 func (o *TxCtx) Seq() *Sequences {
 	return o.sequences
 }
@@ -40,21 +52,55 @@ func (o *TxCtx) NextId(name string) *int64 {
 	return &id
 }
 
+func (o *TxCtx) NextGuid() string {
+	return o.sequences.NextGuid()
+}
+
+func (o *TxCtx) Context() context.Context {
+	return o.ctx
+}
+
 func (o *TxCtx) FindStruct(template interface{}, sql string, queryParams ...interface{}) interface{} {
-	stmt := o.resolveStmt(sql)
-	return FindStructStmt(stmt, template, queryParams...)
+	result, err := o.FindStructContext(o.ctx, template, sql, queryParams...)
+	CheckErr(err)
+	return result
+}
+
+func (o *TxCtx) FindStructContext(ctx context.Context, template interface{}, sql string, queryParams ...interface{}) (interface{}, error) {
+	stmt, err := o.resolveStmtContext(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	return FindStructStmtContext(ctx, stmt, template, queryParams...)
 }
 
 func (o *TxCtx) QueryStruct(template interface{}, sql string, queryParams ...interface{}) interface{} {
-	stmt := o.resolveStmt(sql)
-	return QueryStructStmt(stmt, template, queryParams...)
+	result, err := o.QueryStructContext(o.ctx, template, sql, queryParams...)
+	CheckErr(err)
+	return result
+}
+
+func (o *TxCtx) QueryStructContext(ctx context.Context, template interface{}, sql string, queryParams ...interface{}) (interface{}, error) {
+	stmt, err := o.resolveStmtContext(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	return QueryStructStmtContext(ctx, stmt, template, queryParams...)
 }
 
 func (o *TxCtx) QueryStructStmt(stmt *sql.Stmt, template interface{}, queryParams ...interface{}) interface{} {
-	return QueryStructStmt(stmt, template, queryParams...)
+	result, err := QueryStructStmtContext(o.ctx, stmt, template, queryParams...)
+	CheckErr(err)
+	return result
 }
 
 func (o *TxCtx) InsertEntity(schema string, data interface{}, autoId bool) int64 {
+	id, err := o.InsertEntityContext(o.ctx, schema, data, autoId)
+	CheckErr(err)
+	return id
+}
+
+func (o *TxCtx) InsertEntityContext(ctx context.Context, schema string, data interface{}, autoId bool) (int64, error) {
 	offset := 0
 	if autoId {
 		offset = 1
@@ -66,105 +112,200 @@ func (o *TxCtx) InsertEntity(schema string, data interface{}, autoId bool) int64
 	} else {
 		insMap = o.insMap
 	}
+	returning := autoId && o.dialect.SupportsReturning()
 	key := schema + "." + name
 	stmt, ok := insMap[key]
 	if !ok {
-		sentence := "insert into " + schema + "." + name + ForInsert(data, offset)
+		sentence := "insert into " + o.tableName(schema, name) + ForInsertDialect(o.dialect, data, offset)
+		if returning {
+			sentence += " returning " + quoteFolded(o.dialect, mappingOf(reflect.TypeOf(data)).columns[0].name)
+		}
 		var err error
-		stmt, err = o.tx.Prepare(sentence)
-		CheckErr(err)
+		stmt, err = o.tx.PrepareContext(ctx, sentence)
+		if err != nil {
+			return 0, err
+		}
 		insMap[key] = stmt
 	}
-	return o.ExecStructStmt(stmt, data, offset)
+	if !returning {
+		return ExecStructStmtOffContext(ctx, stmt, data, offset)
+	}
+	args, err := structArgs(data, offset)
+	if err != nil {
+		return 0, err
+	}
+	var id interface{}
+	if err := stmt.QueryRowContext(ctx, args...).Scan(&id); err != nil {
+		return 0, err
+	}
+	generated, _ := id.(int64)
+	return generated, nil
 }
 
 func (o *TxCtx) UpdateEntity(schema string, entity interface{}) int64 {
+	id, err := o.UpdateEntityContext(o.ctx, schema, entity)
+	CheckErr(err)
+	return id
+}
+
+func (o *TxCtx) UpdateEntityContext(ctx context.Context, schema string, entity interface{}) (int64, error) {
 	objectType := reflect.TypeOf(entity)
 	name := objectType.Name()
 	key := schema + "." + name
 	stmt, ok := o.updMap[key]
 	if !ok {
-		idName := o.resolveIdName(objectType)
-		sentence := "update " + schema + "." + objectType.Name() + " set " + ForUpdate(entity, 1, 2) +
-			" where " + idName + " = $1"
-		var err error
-		stmt, err = o.tx.Prepare(sentence)
-		CheckErr(err)
+		mapping := mappingOf(objectType)
+		id, err := mapping.idColumn()
+		if err != nil {
+			return 0, err
+		}
+		if id != 0 {
+			return 0, fmt.Errorf("Id field of %s is not its first field", name)
+		}
+		sentence := "update " + o.tableName(schema, name) + " set " + ForUpdateDialect(o.dialect, entity, 1, 2) +
+			" where " + quoteFolded(o.dialect, mapping.columns[id].name) + " = " + o.dialect.Placeholder(1)
+		stmt, err = o.tx.PrepareContext(ctx, sentence)
+		if err != nil {
+			return 0, err
+		}
 		o.updMap[key] = stmt
 	}
-	return o.ExecStructStmt(stmt, entity, 0)
+	return ExecStructStmtOffContext(ctx, stmt, entity, 0)
 }
 
-func (o *TxCtx) resolveIdName(objectType reflect.Type) string {
-	idField, ok := objectType.FieldByName("Id")
-	if !ok {
-		panic(fmt.Sprintf("Id field not found for %s", objectType.Name()))
-	}
-	idName, ok := idField.Tag.Lookup("sql")
-	if ok {
-		return idName
-	} else {
-		return "Id"
+// tableName returns the quoted name of the table of an entity, folded as if created
+// unquoted, qualified by the schema, kept as given, unless it is empty.
+func (o *TxCtx) tableName(schema string, name string) string {
+	if schema == "" {
+		return quoteFolded(o.dialect, name)
 	}
+	return o.dialect.QuoteIdentifier(schema) + "." + quoteFolded(o.dialect, name)
 }
 
 func (o *TxCtx) DeleteEntity(schema string, entity interface{}) {
+	CheckErr(o.DeleteEntityContext(o.ctx, schema, entity))
+}
+
+func (o *TxCtx) DeleteEntityContext(ctx context.Context, schema string, entity interface{}) error {
 	objectType := reflect.TypeOf(entity)
 	name := objectType.Name()
+	mapping := mappingOf(objectType)
+	idColumn, err := mapping.idColumn()
+	if err != nil {
+		return err
+	}
+	column := &mapping.columns[idColumn]
 	key := schema + "." + name
 	stmt, ok := o.delMap[key]
 	if !ok {
-		idName := o.resolveIdName(objectType)
-		sentence := "delete from " + schema + "." + name + " where " + idName + " = $1"
-		var err error
-		stmt, err = o.tx.Prepare(sentence)
-		CheckErr(err)
+		sentence := "delete from " + o.tableName(schema, name) + " where " + quoteFolded(o.dialect, column.name) +
+			" = " + o.dialect.Placeholder(1)
+		stmt, err = o.tx.PrepareContext(ctx, sentence)
+		if err != nil {
+			return err
+		}
 		o.delMap[key] = stmt
 	}
-	o.ExecStmt(stmt, reflect.ValueOf(entity).FieldByName("Id").Interface())
+	id, err := column.value(reflect.ValueOf(entity))
+	if err != nil {
+		return err
+	}
+	_, err = ExecStmtContext(ctx, stmt, id)
+	return err
 }
 
 func (o *TxCtx) ExecStruct(sql string, data interface{}, offset ...int) int64 {
-	stmt := o.resolveStmt(sql)
-	return o.ExecStructStmt(stmt, data, offset...)
+	id, err := o.ExecStructContext(o.ctx, sql, data, offset...)
+	CheckErr(err)
+	return id
+}
+
+func (o *TxCtx) ExecStructContext(ctx context.Context, sql string, data interface{}, offset ...int) (int64, error) {
+	stmt, err := o.resolveStmtContext(ctx, sql)
+	if err != nil {
+		return 0, err
+	}
+	return o.ExecStructStmtContext(ctx, stmt, data, offset...)
 }
 
 func (o *TxCtx) ExecStructStmt(stmt *sql.Stmt, data interface{}, varOffset ...int) int64 {
+	id, err := o.ExecStructStmtContext(o.ctx, stmt, data, varOffset...)
+	CheckErr(err)
+	return id
+}
+
+func (o *TxCtx) ExecStructStmtContext(ctx context.Context, stmt *sql.Stmt, data interface{}, varOffset ...int) (int64, error) {
+	offset := 0
 	if len(varOffset) > 0 {
-		return ExecStructStmtOff(stmt, data, varOffset[0])
-	} else {
-		return ExecStructStmt(stmt, data)
+		offset = varOffset[0]
 	}
+	return ExecStructStmtOffContext(ctx, stmt, data, offset)
 }
 
 func (o *TxCtx) ExecSql(sql string, args ...interface{}) *sql.Result {
-	stmt := o.resolveStmt(sql)
-	return ExecStmt(stmt, args...)
+	r, err := o.ExecSqlContext(o.ctx, sql, args...)
+	CheckErr(err)
+	return &r
+}
+
+func (o *TxCtx) ExecSqlContext(ctx context.Context, sql string, args ...interface{}) (sql.Result, error) {
+	stmt, err := o.resolveStmtContext(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	return ExecStmtContext(ctx, stmt, args...)
 }
 
 func (o *TxCtx) ExecStmt(stmt *sql.Stmt, args ...interface{}) *sql.Result {
-	return ExecStmt(stmt, args...)
+	r, err := ExecStmtContext(o.ctx, stmt, args...)
+	CheckErr(err)
+	return &r
 }
 
 func (o *TxCtx) QuerySql(sql string, args ...interface{}) *sql.Rows {
-	stmt := o.resolveStmt(sql)
-	return QueryStmt(stmt, args...)
+	r, err := o.QuerySqlContext(o.ctx, sql, args...)
+	CheckErr(err)
+	return r
+}
+
+func (o *TxCtx) QuerySqlContext(ctx context.Context, sql string, args ...interface{}) (*sql.Rows, error) {
+	stmt, err := o.resolveStmtContext(ctx, sql)
+	if err != nil {
+		return nil, err
+	}
+	return QueryStmtContext(ctx, stmt, args...)
+}
+
+// QueryContext is QuerySqlContext, which makes TxCtx a Querier.
+func (o *TxCtx) QueryContext(ctx context.Context, sql string, args ...interface{}) (*sql.Rows, error) {
+	return o.QuerySqlContext(ctx, sql, args...)
 }
 
 func (o *TxCtx) QuerySingleton(sql string, fields []interface{}, args ...interface{}) bool {
-	stmt := o.resolveStmt(sql)
-	return QuerySingletonStmt(stmt, fields, args...)
+	found, err := o.QuerySingletonContext(o.ctx, sql, fields, args...)
+	CheckErr(err)
+	return found
+}
+
+func (o *TxCtx) QuerySingletonContext(ctx context.Context, sql string, fields []interface{}, args ...interface{}) (bool, error) {
+	stmt, err := o.resolveStmtContext(ctx, sql)
+	if err != nil {
+		return false, err
+	}
+	return QuerySingletonStmtContext(ctx, stmt, fields, args...)
 }
 
-func (o *TxCtx) resolveStmt(sql string) *sql.Stmt {
+func (o *TxCtx) resolveStmtContext(ctx context.Context, sql string) (*sql.Stmt, error) {
 	stmt, ok := o.stmtMap[sql]
 	if !ok {
 		var err error
-		stmt, err = o.tx.Prepare(sql)
-		CheckErr(err)
+		stmt, err = o.tx.PrepareContext(ctx, sql)
+		if err != nil {
+			return nil, err
+		}
 		o.stmtMap[sql] = stmt
 	}
-	return stmt
+	return stmt, nil
 }
 
 func (o *TxCtx) AddFuture(f func()) {
@@ -176,72 +317,193 @@ func (o *TxCtx) AddFuture(f func()) {
 	}
 }
 
+func (o *TxCtx) runFutures() {
+	for _, f := range o.future {
+		f()
+	}
+}
+
 func NewTxCtx(databaseConfig DatabaseConfig, tx *sql.Tx, db *sql.DB) *TxCtx {
+	return NewTxCtxContext(context.Background(), databaseConfig, tx, db)
+}
+
+// NewTxCtxContext returns a TxCtx whose panicking methods run within ctx, usually the one
+// the transaction was begun with.
+func NewTxCtxContext(ctx context.Context, databaseConfig DatabaseConfig, tx *sql.Tx, db *sql.DB) *TxCtx {
 	sequences := NewSequences(databaseConfig, tx)
-	txCtx := TxCtx{tx: tx, db: db, stmtMap: make(map[string]*sql.Stmt), insMap: make(map[string]*sql.Stmt),
+	txCtx := TxCtx{ctx: ctx, tx: tx, db: db, stmtMap: make(map[string]*sql.Stmt), insMap: make(map[string]*sql.Stmt),
 		autoIdMap: make(map[string]*sql.Stmt), updMap: make(map[string]*sql.Stmt), delMap: make(map[string]*sql.Stmt),
-		sequences: sequences, databaseConfig: databaseConfig}
+		upsMap: make(map[string]*sql.Stmt), sequences: sequences, dialect: DialectOf(databaseConfig), databaseConfig: databaseConfig}
 	return &txCtx
 }
 
 func InterceptTransactional(databaseConfig *DatabaseConfig, delegate func(txCtx *TxCtx, w http.ResponseWriter, r *http.Request)) http.HandlerFunc {
+	return interceptTransactional(databaseConfig, false, delegate)
+}
+
+func InterceptReadOnlyTransactional(databaseConfig *DatabaseConfig, delegate func(txCtx *TxCtx, w http.ResponseWriter, r *http.Request)) http.HandlerFunc {
+	return interceptTransactional(databaseConfig, true, delegate)
+}
+
+// interceptTransactional runs the delegate in a transaction begun with the request
+// context, so that a client going away cancels the statements and rolls back. When retries
+// are enabled, the request body, up to maxRequestBody bytes, and the response are buffered,
+// so that the delegate can run again when the transaction is retried and nothing is sent
+// before the commit; a larger body is refused with 413. Without retries the delegate reads
+// the request and writes the response itself.
+func interceptTransactional(databaseConfig *DatabaseConfig, readOnly bool, delegate func(txCtx *TxCtx, w http.ResponseWriter, r *http.Request)) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		db := OpenDB(*databaseConfig)
-		defer CloseDB(db)
-		tx, err := db.Begin()
-		if err != nil {
-			panic(err)
+		if databaseConfig.maxRetries() == 0 {
+			CheckErr(runTransactional(r.Context(), *databaseConfig, readOnly, func(txCtx *TxCtx) error {
+				delegate(txCtx, w, r.WithContext(context.WithValue(r.Context(), "txCtx", txCtx)))
+				return nil
+			}))
+			return
 		}
-		defer RollbackOnPanic(tx)
-		txCtx := NewTxCtx(*databaseConfig, tx, db)
-		ctx := context.WithValue(r.Context(), "txCtx", txCtx)
-		delegate(txCtx, w, r.WithContext(ctx))
-		CheckErr(tx.Commit())
-		if txCtx.future != nil {
-			for _, f := range txCtx.future {
-				f()
-			}
+		limit := databaseConfig.maxRequestBody()
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+		if err != nil && int64(len(body)) == limit {
+			http.Error(w, fmt.Sprintf("request body larger than %d bytes", limit), http.StatusRequestEntityTooLarge)
+			return
 		}
+		CheckErr(err)
+		var response *bufferedResponseWriter
+		err = runTransactional(r.Context(), *databaseConfig, readOnly, func(txCtx *TxCtx) error {
+			response = newBufferedResponseWriter()
+			request := r.WithContext(context.WithValue(r.Context(), "txCtx", txCtx))
+			request.Body = io.NopCloser(bytes.NewReader(body))
+			delegate(txCtx, response, request)
+			return nil
+		})
+		CheckErr(err)
+		response.flush(w)
 	}
 }
 
-func InterceptReadOnlyTransactional(databaseConfig *DatabaseConfig, delegate func(txCtx *TxCtx, w http.ResponseWriter, r *http.Request)) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		db := OpenDB(*databaseConfig)
-		defer CloseDB(db)
-		txOptions := sql.TxOptions{ReadOnly: true}
-		tx, err := db.BeginTx(context.Background(), &txOptions)
-		if err != nil {
-			panic(err)
+func ExecuteTransactional(config DatabaseConfig, callback func(txCtx *TxCtx, args ...interface{}) interface{}, args ...interface{}) interface{} {
+	var r interface{}
+	CheckErr(runTransactional(context.Background(), config, false, func(txCtx *TxCtx) error {
+		r = callback(txCtx, args...)
+		return nil
+	}))
+	return r
+}
+
+// ExecuteTransactionalContext runs the callback in a transaction begun with ctx. The
+// transaction is rolled back when the callback fails or panics, and committed otherwise;
+// serialization failures and deadlocks run it again, as configured in DatabaseConfig.
+// The futures only run once the final attempt is committed.
+func ExecuteTransactionalContext(ctx context.Context, config DatabaseConfig, callback func(txCtx *TxCtx) (interface{}, error)) (interface{}, error) {
+	var r interface{}
+	err := runTransactional(ctx, config, false, func(txCtx *TxCtx) error {
+		var err error
+		r, err = callback(txCtx)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// retryableCodes are the Postgres SQLSTATEs after which a transaction may succeed if
+// run again: serialization_failure and deadlock_detected.
+var retryableCodes = []string{"40001", "40P01"}
+
+// IsRetryable tells whether err, or an error it wraps, is a Postgres serialization failure
+// or deadlock.
+func IsRetryable(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && InStringList(string(pqErr.Code), retryableCodes)
+}
+
+func runTransactional(ctx context.Context, config DatabaseConfig, readOnly bool, callback func(txCtx *TxCtx) error) error {
+	db, err := pools.resolve(config)
+	if err != nil {
+		return err
+	}
+	options := config.txOptions(readOnly)
+	for retry := 0; ; retry++ {
+		txCtx, err := attemptTransactional(ctx, db, config, options, callback)
+		if err == nil {
+			txCtx.runFutures()
+			return nil
 		}
-		defer RollbackOnPanic(tx)
-		txCtx := NewTxCtx(*databaseConfig, tx, db)
-		ctx := context.WithValue(r.Context(), "txCtx", txCtx)
-		delegate(txCtx, w, r.WithContext(ctx))
-		CheckErr(tx.Commit())
-		if txCtx.future != nil {
-			for _, f := range txCtx.future {
-				f()
-			}
+		if !IsRetryable(err) || retry >= config.maxRetries() {
+			return err
+		}
+		StructuredLog().Warn("retrying transaction", "error", err, "retry", retry+1)
+		select {
+		case <-time.After(config.retryDelay(retry)):
+		case <-ctx.Done():
+			return ctx.Err()
 		}
 	}
 }
 
-func ExecuteTransactional(config DatabaseConfig, callback func(txCtx *TxCtx, args ...interface{}) interface{}, args ...interface{}) interface{} {
-	db := OpenDB(config)
-	defer CloseDB(db)
-	tx, err := db.Begin()
+// attemptTransactional runs the callback once and commits. A panic carrying a retryable
+// error is returned as that error, any other one goes on after the rollback.
+func attemptTransactional(ctx context.Context, db *sql.DB, config DatabaseConfig, options *sql.TxOptions, callback func(txCtx *TxCtx) error) (txCtx *TxCtx, err error) {
+	tx, err := db.BeginTx(ctx, options)
 	if err != nil {
-		panic(err)
-	}
-	defer RollbackOnPanic(tx)
-	txCtx := NewTxCtx(config, tx, db)
-	r := callback(txCtx, args...)
-	CheckErr(tx.Commit())
-	if txCtx.future != nil {
-		for _, f := range txCtx.future {
-			f()
+		return nil, err
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+			if e, ok := r.(error); ok && IsRetryable(e) {
+				txCtx, err = nil, e
+				return
+			}
+			panic(r)
 		}
+	}()
+	txCtx = NewTxCtxContext(ctx, config, tx, db)
+	if err := callback(txCtx); err != nil {
+		tx.Rollback()
+		return nil, err
 	}
-	return r
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return txCtx, nil
+}
+
+// bufferedResponseWriter holds a response until the transaction that produced it is
+// committed.
+type bufferedResponseWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (o *bufferedResponseWriter) Header() http.Header {
+	return o.header
+}
+
+func (o *bufferedResponseWriter) WriteHeader(status int) {
+	if o.status == 0 {
+		o.status = status
+	}
+}
+
+func (o *bufferedResponseWriter) Write(b []byte) (int, error) {
+	if o.status == 0 {
+		o.status = http.StatusOK
+	}
+	return o.body.Write(b)
+}
+
+func (o *bufferedResponseWriter) flush(w http.ResponseWriter) {
+	for k, v := range o.header {
+		w.Header()[k] = v
+	}
+	if o.status != 0 {
+		w.WriteHeader(o.status)
+	}
+	w.Write(o.body.Bytes())
+}
+
+func newBufferedResponseWriter() *bufferedResponseWriter {
+	return &bufferedResponseWriter{header: make(http.Header)}
 }
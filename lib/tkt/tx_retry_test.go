@@ -0,0 +1,309 @@
+package tkt
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"github.com/lib/pq"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var serializationFailure = &pq.Error{Code: "40001", Message: "could not serialize access"}
+
+func retryConfig(t *testing.T, maxRetries int) (*fakeDB, DatabaseConfig) {
+	fake, config := newFakeDB(t)
+	backoff := 1
+	config.MaxRetries = &maxRetries
+	config.RetryBackoff = &backoff
+	t.Cleanup(func() { ClosePools() })
+	return fake, config
+}
+
+func TestIsRetryable(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{serializationFailure, true},
+		{&pq.Error{Code: "40P01"}, true},
+		{fmt.Errorf("insert: %w", serializationFailure), true},
+		{&pq.Error{Code: "23505"}, false},
+		{errors.New("40001"), false},
+		{nil, false},
+	}
+	for _, test := range tests {
+		if got := IsRetryable(test.err); got != test.want {
+			t.Errorf("%v: got %v", test.err, got)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	backoff := 20
+	config := DatabaseConfig{RetryBackoff: &backoff}
+	bounds := []struct {
+		retry    int
+		min, max time.Duration
+	}{
+		{0, 10 * time.Millisecond, 20 * time.Millisecond},
+		{1, 20 * time.Millisecond, 40 * time.Millisecond},
+		{3, 80 * time.Millisecond, 160 * time.Millisecond},
+		{7, time.Second, 2 * time.Second},
+		{40, time.Second, 2 * time.Second},
+	}
+	for _, b := range bounds {
+		seen := make(map[time.Duration]bool)
+		for i := 0; i < 100; i++ {
+			d := config.retryDelay(b.retry)
+			if d < b.min || d > b.max {
+				t.Fatalf("retry %d: delay %v out of [%v, %v]", b.retry, d, b.min, b.max)
+			}
+			seen[d] = true
+		}
+		if len(seen) < 2 {
+			t.Errorf("retry %d: the delay has no jitter", b.retry)
+		}
+	}
+	if d := (&DatabaseConfig{}).retryDelay(0); d < 10*time.Millisecond || d > 20*time.Millisecond {
+		t.Errorf("default delay %v", d)
+	}
+}
+
+func TestTxOptions(t *testing.T) {
+	level := "serializable"
+	config := DatabaseConfig{IsolationLevel: &level}
+	if got := config.txOptions(true); got.Isolation != sql.LevelSerializable || !got.ReadOnly {
+		t.Errorf("got %+v", got)
+	}
+	if got := (&DatabaseConfig{}).txOptions(false); got.Isolation != sql.LevelDefault || got.ReadOnly {
+		t.Errorf("got %+v", got)
+	}
+	driver, datasource, invalid, negative := "postgres", "x", "snapshot", -1
+	for _, config := range []DatabaseConfig{
+		{DatabaseDriver: &driver, DatasourceName: &datasource, IsolationLevel: &invalid},
+		{DatabaseDriver: &driver, DatasourceName: &datasource, MaxRetries: &negative},
+		{DatabaseDriver: &driver, DatasourceName: &datasource, RetryBackoff: &negative},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("%+v accepted", config)
+				}
+			}()
+			config.Validate()
+		}()
+	}
+}
+
+func TestExecuteTransactionalRetries(t *testing.T) {
+	tests := []struct {
+		name    string
+		attempt func(fake *fakeDB, txCtx *TxCtx, n int) error
+	}{
+		{"returned error", func(fake *fakeDB, txCtx *TxCtx, n int) error {
+			if n < 3 {
+				return fmt.Errorf("update: %w", serializationFailure)
+			}
+			return nil
+		}},
+		{"panicked error", func(fake *fakeDB, txCtx *TxCtx, n int) error {
+			if n < 3 {
+				CheckErr(&pq.Error{Code: "40P01"})
+			}
+			return nil
+		}},
+		{"failed commit", func(fake *fakeDB, txCtx *TxCtx, n int) error {
+			if n < 3 {
+				fake.fails("commit", serializationFailure)
+			} else {
+				fake.fails("commit", nil)
+			}
+			return nil
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fake, config := retryConfig(t, 3)
+			attempts, futures := 0, 0
+			r, err := ExecuteTransactionalContext(context.Background(), config, func(txCtx *TxCtx) (interface{}, error) {
+				attempts++
+				txCtx.AddFuture(func() { futures++ })
+				return attempts, test.attempt(fake, txCtx, attempts)
+			})
+			if err != nil || r != 3 {
+				t.Fatalf("got %v, %v", r, err)
+			}
+			if commits, _, _ := fake.counts(); attempts != 3 || futures != 1 || commits != 1 {
+				t.Errorf("%d attempts, %d futures run, %d commits", attempts, futures, commits)
+			}
+		})
+	}
+}
+
+func TestExecuteTransactionalStopsRetrying(t *testing.T) {
+	fake, config := retryConfig(t, 2)
+	attempts := 0
+	_, err := ExecuteTransactionalContext(context.Background(), config, func(txCtx *TxCtx) (interface{}, error) {
+		attempts++
+		return nil, serializationFailure
+	})
+	if !IsRetryable(err) || attempts != 3 {
+		t.Errorf("got %v after %d attempts, want 3", err, attempts)
+	}
+
+	failure := errors.New("constraint violated")
+	attempts = 0
+	_, err = ExecuteTransactionalContext(context.Background(), config, func(txCtx *TxCtx) (interface{}, error) {
+		attempts++
+		return nil, failure
+	})
+	if err != failure || attempts != 1 {
+		t.Errorf("got %v after %d attempts, want 1", err, attempts)
+	}
+
+	attempts = 0
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Errorf("recovered %v", r)
+			}
+		}()
+		ExecuteTransactional(config, func(txCtx *TxCtx, args ...interface{}) interface{} {
+			attempts++
+			panic("boom")
+		})
+	}()
+	if commits, rollbacks, _ := fake.counts(); attempts != 1 || commits != 0 || rollbacks != 5 {
+		t.Errorf("%d attempts, %d commits, %d rollbacks", attempts, commits, rollbacks)
+	}
+}
+
+func TestExecuteTransactionalCanceledDuringBackoff(t *testing.T) {
+	_, config := retryConfig(t, 3)
+	backoff := 60000
+	config.RetryBackoff = &backoff
+	ctx, cancel := context.WithCancel(context.Background())
+	attempts := 0
+	done := make(chan error, 1)
+	go func() {
+		_, err := ExecuteTransactionalContext(ctx, config, func(txCtx *TxCtx) (interface{}, error) {
+			attempts++
+			return nil, serializationFailure
+		})
+		done <- err
+	}()
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) || attempts != 1 {
+			t.Errorf("got %v after %d attempts", err, attempts)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("the backoff ignores the context")
+	}
+}
+
+func TestInterceptTransactionalRetries(t *testing.T) {
+	_, config := retryConfig(t, 3)
+	attempts := 0
+	handler := InterceptTransactional(&config, func(txCtx *TxCtx, w http.ResponseWriter, r *http.Request) {
+		attempts++
+		body, err := io.ReadAll(r.Body)
+		CheckErr(err)
+		w.Header().Set("X-Attempt", fmt.Sprint(attempts))
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, "%s %d", body, attempts)
+		if attempts < 2 {
+			CheckErr(serializationFailure)
+		}
+	})
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest("POST", "/", strings.NewReader("payload")))
+	if recorder.Code != http.StatusCreated || recorder.Body.String() != "payload 2" || recorder.Header().Get("X-Attempt") != "2" {
+		t.Errorf("got %d %q %v", recorder.Code, recorder.Body, recorder.Header())
+	}
+}
+
+func TestInterceptTransactionalWithoutRetries(t *testing.T) {
+	fake, config := retryConfig(t, 0)
+	attempts := 0
+	handler := InterceptTransactional(&config, func(txCtx *TxCtx, w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if _, buffered := w.(*bufferedResponseWriter); buffered {
+			t.Error("response buffered without retries")
+		}
+		io.Copy(w, r.Body)
+		if attempts == 2 {
+			CheckErr(serializationFailure)
+		}
+	})
+	recorder := httptest.NewRecorder()
+	handler(recorder, httptest.NewRequest("POST", "/", strings.NewReader("payload")))
+	if recorder.Body.String() != "payload" {
+		t.Errorf("got %q", recorder.Body)
+	}
+	func() {
+		defer func() {
+			if r := recover(); r != serializationFailure {
+				t.Errorf("recovered %v, want the serialization failure", r)
+			}
+		}()
+		handler(httptest.NewRecorder(), httptest.NewRequest("POST", "/", strings.NewReader("payload")))
+	}()
+	if commits, rollbacks, _ := fake.counts(); attempts != 2 || commits != 1 || rollbacks != 1 {
+		t.Errorf("%d attempts, %d commits, %d rollbacks", attempts, commits, rollbacks)
+	}
+}
+
+func TestInterceptTransactionalRefusesLargeBodies(t *testing.T) {
+	_, config := retryConfig(t, 3)
+	limit := int64(4)
+	config.MaxRequestBody = &limit
+	calls := 0
+	handler := InterceptTransactional(&config, func(txCtx *TxCtx, w http.ResponseWriter, r *http.Request) {
+		calls++
+		io.Copy(w, r.Body)
+	})
+	for body, want := range map[string]int{"four": http.StatusOK, "payload": http.StatusRequestEntityTooLarge} {
+		recorder := httptest.NewRecorder()
+		handler(recorder, httptest.NewRequest("POST", "/", strings.NewReader(body)))
+		if recorder.Code != want {
+			t.Errorf("%q: status %d, want %d", body, recorder.Code, want)
+		}
+	}
+	if calls != 1 {
+		t.Errorf("delegate called %d times, want once", calls)
+	}
+}
+
+func TestInterceptTransactionalRecoversPanics(t *testing.T) {
+	fake, config := retryConfig(t, 3)
+	failure := errors.New("constraint violated")
+	handler := InterceptTransactional(&config, func(txCtx *TxCtx, w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprint(w, "half written")
+		CheckErr(failure)
+	})
+	recorder := httptest.NewRecorder()
+	func() {
+		defer func() {
+			if r := recover(); r != failure {
+				t.Errorf("recovered %v, want the delegate failure", r)
+			}
+		}()
+		handler(recorder, httptest.NewRequest("POST", "/", strings.NewReader("payload")))
+	}()
+	if recorder.Body.Len() != 0 || recorder.Code != http.StatusOK {
+		t.Errorf("sent %d %q from a rolled back transaction", recorder.Code, recorder.Body)
+	}
+	if commits, rollbacks, _ := fake.counts(); commits != 0 || rollbacks != 1 {
+		t.Errorf("%d commits, %d rollbacks, want one rollback and no retry", commits, rollbacks)
+	}
+}
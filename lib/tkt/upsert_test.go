@@ -0,0 +1,116 @@
+package tkt
+
+import (
+	_ "modernc.org/sqlite"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+type Price struct {
+	Id     int64
+	Code   string `sql:"code"`
+	Region string
+	Amount int64
+}
+
+type Tag struct {
+	ItemId int64
+	Label  string
+}
+
+func upsertConfig(t *testing.T) DatabaseConfig {
+	driver := "sqlite"
+	datasource := "file:" + filepath.Join(t.TempDir(), "upsert.db")
+	t.Cleanup(func() { ClosePools() })
+	config := DatabaseConfig{DatabaseDriver: &driver, DatasourceName: &datasource}
+	ExecuteTransactional(config, func(txCtx *TxCtx, args ...interface{}) interface{} {
+		txCtx.ExecSql(`create table Price (Id integer primary key, code text not null, Region text not null, Amount integer not null, unique (code, Region))`)
+		txCtx.ExecSql(`create table Tag (ItemId integer not null, Label text not null, primary key (ItemId, Label))`)
+		return nil
+	})
+	return config
+}
+
+func prices(txCtx *TxCtx) []Price {
+	return txCtx.QueryStruct(Price{}, "select Id, code, Region, Amount from Price order by Id").([]Price)
+}
+
+func TestUpsertEntity(t *testing.T) {
+	config := upsertConfig(t)
+	ExecuteTransactional(config, func(txCtx *TxCtx, args ...interface{}) interface{} {
+		if n := txCtx.UpsertEntity("main", Price{Id: 1, Code: "A", Region: "EU", Amount: 10}); n != 1 {
+			t.Errorf("insert affected %d rows", n)
+		}
+		if n := txCtx.UpsertEntity("main", Price{Id: 1, Code: "A", Region: "EU", Amount: 12}); n != 1 {
+			t.Errorf("update affected %d rows", n)
+		}
+		if n := txCtx.UpsertEntity("main", Price{Id: 2, Code: "A", Region: "US", Amount: 20}); n != 1 {
+			t.Errorf("second insert affected %d rows", n)
+		}
+		want := []Price{{1, "A", "EU", 12}, {2, "A", "US", 20}}
+		if got := prices(txCtx); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+		return nil
+	})
+}
+
+func TestUpsertEntityWithKeyColumns(t *testing.T) {
+	config := upsertConfig(t)
+	ExecuteTransactional(config, func(txCtx *TxCtx, args ...interface{}) interface{} {
+		txCtx.UpsertEntity("main", Price{Id: 1, Code: "A", Region: "EU", Amount: 10}, "code", "Region")
+		txCtx.UpsertEntity("main", Price{Id: 5, Code: "A", Region: "EU", Amount: 11}, "code", "Region")
+		txCtx.UpsertEntity("main", Price{Id: 6, Code: "B", Region: "EU", Amount: 30}, "code", "Region")
+		want := []Price{{5, "A", "EU", 11}, {6, "B", "EU", 30}}
+		if got := prices(txCtx); !reflect.DeepEqual(got, want) {
+			t.Errorf("got %+v, want %+v", got, want)
+		}
+		if _, err := txCtx.UpsertEntityContext(txCtx.Context(), "main", Price{Id: 7, Code: "B", Region: "EU"}, "Region"); err == nil {
+			t.Error("an upsert on columns without a unique constraint succeeded")
+		}
+		return nil
+	})
+}
+
+func TestUpsertEntityWithOnlyKeyColumns(t *testing.T) {
+	config := upsertConfig(t)
+	ExecuteTransactional(config, func(txCtx *TxCtx, args ...interface{}) interface{} {
+		if n := txCtx.UpsertEntity("main", Tag{ItemId: 1, Label: "red"}, "ItemId", "Label"); n != 1 {
+			t.Errorf("insert affected %d rows", n)
+		}
+		if n := txCtx.UpsertEntity("main", Tag{ItemId: 1, Label: "red"}, "ItemId", "Label"); n != 0 {
+			t.Errorf("do nothing affected %d rows", n)
+		}
+		var count int64
+		txCtx.QuerySingleton("select count(*) from Tag", []interface{}{&count})
+		if count != 1 {
+			t.Errorf("%d tags, want 1", count)
+		}
+		if _, err := txCtx.UpsertEntityContext(txCtx.Context(), "main", Tag{ItemId: 2, Label: "blue"}); err == nil {
+			t.Error("an upsert of an entity without id and keys succeeded")
+		}
+		return nil
+	})
+}
+
+func TestInsertEntitiesInChunks(t *testing.T) {
+	config := upsertConfig(t)
+	ExecuteTransactional(config, func(txCtx *TxCtx, args ...interface{}) interface{} {
+		rows := make([]Price, 2*bulkRows+7)
+		for i := range rows {
+			rows[i] = Price{Code: "C", Region: string(rune('a'+i%26)) + string(rune('a'+i/26)), Amount: int64(i)}
+		}
+		if n := txCtx.InsertEntities("main", rows, true); n != int64(len(rows)) {
+			t.Errorf("inserted %d rows, want %d", n, len(rows))
+		}
+		if n := txCtx.InsertEntities("main", []Price{}, true); n != 0 {
+			t.Errorf("inserted %d rows of none", n)
+		}
+		got := prices(txCtx)
+		if len(got) != len(rows) || got[0].Id != 1 || got[len(got)-1] != (Price{int64(len(rows)), "C", rows[len(rows)-1].Region, int64(len(rows) - 1)}) {
+			t.Errorf("got %d rows, first %+v, last %+v", len(got), got[0], got[len(got)-1])
+		}
+		return nil
+	})
+}
@@ -2,6 +2,9 @@ package tkt
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -200,6 +203,70 @@ func interceptStats(delegate func(w http.ResponseWriter, r *http.Request)) http.
 	}
 }
 
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (o *statusRecorder) WriteHeader(status int) {
+	o.status = status
+	o.ResponseWriter.WriteHeader(status)
+}
+
+// maxRequestIdLength bounds the X-Request-Id accepted from clients, which goes into every log
+// line of the request.
+const maxRequestIdLength = 128
+
+// InterceptLogging gives each request an id, taken from X-Request-Id when valid or else
+// generated, and a context logger carrying it; see ContextLogger. A record is logged when
+// the request ends.
+func InterceptLogging(delegate func(w http.ResponseWriter, r *http.Request)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		requestId := r.Header.Get("X-Request-Id")
+		if !validRequestId(requestId) {
+			requestId = newRequestId()
+		}
+		w.Header().Set("X-Request-Id", requestId)
+		logger := ContextLogger(r.Context()).With("requestId", requestId)
+		ctx := WithLogger(context.WithValue(r.Context(), requestIdContextKey{}, requestId), logger)
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		t0 := time.Now()
+		delegate(recorder, r.WithContext(ctx))
+		ContextLogger(ctx).Info("request completed", "method", r.Method, "path", r.URL.Path,
+			"status", recorder.status, "durationMs", time.Since(t0).Milliseconds())
+	}
+}
+
+type requestIdContextKey struct{}
+
+// RequestId returns the id given to the request by InterceptLogging.
+func RequestId(ctx context.Context) string {
+	id, _ := ctx.Value(requestIdContextKey{}).(string)
+	return id
+}
+
+// validRequestId tells whether a client supplied id can be logged as it is: not empty, at
+// most maxRequestIdLength long and made of letters, digits and the punctuation of UUIDs,
+// ULIDs and trace ids, so that it cannot forge or break log lines.
+func validRequestId(id string) bool {
+	if id == "" || len(id) > maxRequestIdLength {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("-_.:", c)) {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestId() string {
+	b := make([]byte, 16)
+	_, err := rand.Read(b)
+	CheckErr(err)
+	return hex.EncodeToString(b)
+}
+
 func catchFatal(writer http.ResponseWriter, r *http.Request) {
 	if e := recover(); e != nil {
 		Logger("error").Printf("Error executing %s", r.URL.String())
@@ -0,0 +1,44 @@
+package tkt
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestInterceptLoggingRequestId(t *testing.T) {
+	for _, c := range []struct {
+		header string
+		kept   bool
+	}{
+		{"", false},
+		{"0190f5c2-7c3e-7a4b-9c1d-2f6e8a9b0c1d", true},
+		{"01J2Z3Y4X5W6V7T8S9R0QPNMKH", true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"svc.api:42_a", true},
+		{"forged\nlevel=ERROR msg=\"breach\"", false},
+		{"a b", false},
+		{`"quoted"`, false},
+		{strings.Repeat("a", maxRequestIdLength), true},
+		{strings.Repeat("a", maxRequestIdLength+1), false},
+	} {
+		var seen string
+		handler := InterceptLogging(func(w http.ResponseWriter, r *http.Request) {
+			seen = RequestId(r.Context())
+		})
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.Header.Set("X-Request-Id", c.header)
+		w := httptest.NewRecorder()
+		handler(w, r)
+		if c.kept && seen != c.header {
+			t.Errorf("%q: request id %q, want it kept", c.header, seen)
+		}
+		if !c.kept && (seen == c.header || len(seen) != 32) {
+			t.Errorf("%q: request id %q, want a generated one", c.header, seen)
+		}
+		if w.Header().Get("X-Request-Id") != seen {
+			t.Errorf("%q: response header %q, want %q", c.header, w.Header().Get("X-Request-Id"), seen)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"json-schema-validation/internal/audit"
+	"json-schema-validation/lib/tkt"
+	"os"
+)
+
+const auditFileMaxSize = 100 << 20
+
+type auditFlags struct {
+	*auditDbFlags
+	file *string
+}
+
+func addAuditFlags(flags *flag.FlagSet) *auditFlags {
+	return &auditFlags{
+		auditDbFlags: addAuditDbFlags(flags),
+		file:         flags.String("audit-file", "", "audit trail file, rotated and gzipped every 100 MiB"),
+	}
+}
+
+type auditDbFlags struct {
+	driver     *string
+	datasource *string
+	schema     *string
+}
+
+func addAuditDbFlags(flags *flag.FlagSet) *auditDbFlags {
+	return &auditDbFlags{
+		driver:     flags.String("audit-db-driver", "postgres", "database driver of the audit trail table: postgres or sqlite"),
+		datasource: flags.String("audit-db", "", "datasource name of the audit trail table"),
+		schema:     flags.String("audit-schema", "", "database schema of the audit trail table (default audit, main for sqlite)"),
+	}
+}
+
+func (o *auditDbFlags) config() tkt.DatabaseConfig {
+	return tkt.DatabaseConfig{DatabaseDriver: o.driver, DatasourceName: o.datasource}
+}
+
+// schemaName returns the schema flag, defaulting to audit, or to main, the only schema of
+// a SQLite database.
+func (o *auditDbFlags) schemaName() string {
+	if *o.schema != "" {
+		return *o.schema
+	}
+	if !tkt.DialectOf(o.config()).SupportsSchemas() {
+		return "main"
+	}
+	return "audit"
+}
+
+func (o *auditFlags) sinks() []audit.Sink {
+	var sinks []audit.Sink
+	if *o.file != "" {
+		writer := &tkt.LogWriter{FileName: *o.file, MaxSize: auditFileMaxSize, Compress: true}
+		sinks = append(sinks, audit.NewFileSink(writer))
+	}
+	if *o.datasource != "" {
+		sinks = append(sinks, audit.NewSqlSink(o.config(), o.schemaName()))
+	}
+	return sinks
+}
+
+// trail returns the audit trail of the flags, or nil when none is configured. The
+// pending migrations of the database table are applied.
+func (o *auditFlags) trail() *audit.Trail {
+	sinks := o.sinks()
+	if len(sinks) == 0 {
+		return nil
+	}
+	for _, sink := range sinks {
+		if db, ok := sink.(*audit.SqlSink); ok {
+			db.Migrate()
+		}
+	}
+	return audit.NewTrail(sinks...)
+}
+
+func auditVerify(args []string) int {
+	flags := flag.NewFlagSet("audit-verify", flag.ExitOnError)
+	auditFlags := addAuditFlags(flags)
+	first := flags.Int64("first-sequence", 1, "sequence the chain starts at, when older entries were removed on purpose")
+	flags.Parse(args)
+	sinks := auditFlags.sinks()
+	if len(sinks) == 0 {
+		fmt.Fprintln(os.Stderr, "audit-verify: one of -audit-file or -audit-db is required")
+		flags.Usage()
+		return 2
+	}
+	status := 0
+	for _, sink := range sinks {
+		name := *auditFlags.file
+		if _, ok := sink.(*audit.SqlSink); ok {
+			name = auditFlags.schemaName() + ".ValidationAudit"
+		}
+		result, err := audit.VerifyFrom(sink, *first)
+		if err != nil {
+			fmt.Printf("%s: chain broken: %v\n", name, err)
+			status = 1
+			continue
+		}
+		if result.Count == 0 {
+			fmt.Printf("%s: no entries\n", name)
+			continue
+		}
+		fmt.Printf("%s: %d entries verified, sequence %d to %d\n", name, result.Count, result.First, result.Last)
+	}
+	return status
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"flag"
+	"json-schema-validation/lib/tkt"
+)
+
+type lenientFlags struct {
+	lenient   *bool
+	generator *string
+}
+
+func addLenientFlags(flags *flag.FlagSet) *lenientFlags {
+	return &lenientFlags{
+		lenient:   flags.Bool("lenient", false, "give payloads without transmissionGUID one"),
+		generator: flags.String("guid-generator", tkt.UuidV7, "generator of the transmissionGUIDs given in lenient mode: uuidv4, uuidv7 or ulid"),
+	}
+}
+
+// guids returns the generator of lenient mode, nil when not lenient, and false for an
+// unknown generator.
+func (o *lenientFlags) guids() (tkt.IdGenerator, bool) {
+	generator, ok := tkt.IdGeneratorOf(*o.generator)
+	if !*o.lenient {
+		return nil, ok
+	}
+	return generator, ok
+}
@@ -1,12 +1,79 @@
 package main
 
 import (
+	"bytes"
+	"flag"
+	"fmt"
 	"json-schema-validation/internal/server"
+	"json-schema-validation/lib/tkt"
 	"log"
+	_ "modernc.org/sqlite"
+	"os"
 )
 
+var commands = map[string]func(args []string) int{
+	"serve":        serve,
+	"validate":     validate,
+	"audit-verify": auditVerify,
+	"migrate":      migrate,
+}
+
 func main() {
-	srv := server.NewHttpServer(":8080")
-	log.Println("Server is running ...")
+	args := os.Args[1:]
+	command := "serve"
+	if len(args) > 0 {
+		if _, ok := commands[args[0]]; ok {
+			command = args[0]
+			args = args[1:]
+		}
+	}
+	os.Exit(commands[command](args))
+}
+
+func serve(args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := flags.String("addr", ":8080", "address to listen on")
+	adminAddr := flags.String("admin-addr", "", "address of the operational endpoints, such as localhost:8081; none by default")
+	logFormat := flags.String("log-format", tkt.FormatLogfmt, "log format: text, json or logfmt")
+	logLevel := flags.String("log-level", "info", "minimum log level: debug, info, warn or error")
+	auditFlags := addAuditFlags(flags)
+	trustedProxies := flags.String("trusted-proxies", "", "comma separated addresses and networks of the proxies whose X-Forwarded-For names the caller")
+	lenientFlags := addLenientFlags(flags)
+	piiHashKeyFile := flags.String("pii-hash-key-file", "", "file holding the HMAC key of the x-pii hash values; without one they are dropped")
+	flags.Parse(args)
+	level, ok := tkt.ParseLevel(*logLevel)
+	guids, known := lenientFlags.guids()
+	proxies, err := server.ParseTrustedProxies(*trustedProxies)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "serve:", err)
+		flags.Usage()
+		return 2
+	}
+	if !ok || !known || !tkt.InStringList(*logFormat, []string{tkt.FormatText, tkt.FormatJson, tkt.FormatLogfmt}) {
+		flags.Usage()
+		return 2
+	}
+	var piiHashKey []byte
+	if *piiHashKeyFile != "" {
+		if piiHashKey, err = os.ReadFile(*piiHashKeyFile); err == nil && len(bytes.TrimSpace(piiHashKey)) == 0 {
+			err = fmt.Errorf("%s is empty", *piiHashKeyFile)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "serve:", err)
+			return 2
+		}
+		piiHashKey = bytes.TrimSpace(piiHashKey)
+	}
+	tkt.SetStructuredLog(tkt.NewStructuredLogger(os.Stdout, *logFormat, level))
+	srv := server.NewHttpServer(*addr, auditFlags.trail(), guids, proxies, nil, piiHashKey)
+	if *adminAddr != "" {
+		admin := server.NewAdminServer(*adminAddr)
+		go func() {
+			log.Fatal(admin.ListenAndServe())
+		}()
+		tkt.StructuredLog().Info("admin server is running", "addr", *adminAddr)
+	}
+	tkt.StructuredLog().Info("server is running", "addr", *addr)
 	log.Fatal(srv.ListenAndServe())
+	return 0
 }
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"json-schema-validation/internal/audit"
+	"json-schema-validation/lib/tkt"
+	"os"
+	"strconv"
+)
+
+// migrate applies (up [version]), rolls back (down [steps]) or lists (status) the
+// migrations of the audit database.
+func migrate(args []string) int {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dbFlags := addAuditDbFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: core migrate -audit-db <datasource> [-audit-db-driver <driver>] [-audit-schema <schema>] up [version] | down [steps] | status")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	action, number := flags.Arg(0), int64(0)
+	if flags.NArg() > 1 {
+		var err error
+		if number, err = strconv.ParseInt(flags.Arg(1), 10, 64); err != nil || number < 1 {
+			flags.Usage()
+			return 2
+		}
+	}
+	if *dbFlags.datasource == "" || flags.NArg() > 2 || !tkt.InStringList(action, []string{"up", "down", "status"}) ||
+		(action == "status" && flags.NArg() > 1) {
+		flags.Usage()
+		return 2
+	}
+	defer tkt.ClosePools()
+	migrator := audit.NewMigrator(dbFlags.config(), dbFlags.schemaName())
+	ctx := context.Background()
+	switch action {
+	case "up":
+		done, err := migrator.Up(ctx, number)
+		for _, m := range done {
+			fmt.Printf("applied %d_%s\n", m.Version, m.Name)
+		}
+		return migrationResult(err, len(done))
+	case "down":
+		if number == 0 {
+			number = 1
+		}
+		done, err := migrator.Down(ctx, int(number))
+		for _, m := range done {
+			fmt.Printf("rolled back %d_%s\n", m.Version, m.Name)
+		}
+		return migrationResult(err, len(done))
+	default:
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+			return 1
+		}
+		for _, s := range statuses {
+			state := "pending"
+			if s.Applied {
+				state = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if s.Modified {
+				state += ", modified since"
+			}
+			if s.Missing {
+				state += ", file missing"
+			}
+			fmt.Printf("%6d %-40s %s\n", s.Version, s.Name, state)
+		}
+		return 0
+	}
+}
+
+func migrationResult(err error, count int) int {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "migrate: %v\n", err)
+		return 1
+	}
+	if count == 0 {
+		fmt.Println("nothing to do")
+	}
+	return 0
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime/metrics"
+	"sync"
+	"time"
+)
+
+const heapMetric = "/memory/classes/heap/objects:bytes"
+
+// memoryStats samples the live heap while a command runs, so the streaming and in-memory
+// validation paths can be compared on the same payload.
+type memoryStats struct {
+	mux     sync.Mutex
+	peak    uint64
+	started time.Time
+	done    chan struct{}
+}
+
+func startMemoryStats() *memoryStats {
+	o := &memoryStats{started: time.Now(), done: make(chan struct{})}
+	go func() {
+		ticker := time.NewTicker(5 * time.Millisecond)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-o.done:
+				return
+			case <-ticker.C:
+				o.sample()
+			}
+		}
+	}()
+	return o
+}
+
+func (o *memoryStats) sample() {
+	sample := []metrics.Sample{{Name: heapMetric}}
+	metrics.Read(sample)
+	o.mux.Lock()
+	defer o.mux.Unlock()
+	if v := sample[0].Value.Uint64(); v > o.peak {
+		o.peak = v
+	}
+}
+
+func (o *memoryStats) report(w io.Writer) {
+	close(o.done)
+	o.sample()
+	fmt.Fprintf(w, "elapsed %v, peak heap %.1f MiB\n", time.Since(o.started).Round(time.Millisecond), float64(o.peak)/(1<<20))
+}
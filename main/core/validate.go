@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"json-schema-validation/internal/server"
+	"json-schema-validation/lib/tkt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var extensionContentTypes = map[string]string{
+	".json": "application/json",
+	".yaml": "application/yaml",
+	".yml":  "application/yaml",
+	".xml":  "application/xml",
+}
+
+var language string
+
+func validate(args []string) int {
+	flags := flag.NewFlagSet("validate", flag.ExitOnError)
+	contentType := flags.String("type", "", "content type of the input; guessed from the file extension when empty")
+	normalize := flags.Bool("normalize", false, "print the normalized document when valid")
+	stream := flags.Bool("stream", false, "validate JSON input while reading it instead of loading it whole")
+	stats := flags.Bool("stats", false, "report elapsed time and peak heap usage")
+	lang := flags.String("lang", os.Getenv("LANG"), "language of the error messages (en, es)")
+	lenientFlags := addLenientFlags(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: core validate [-type content-type] [-normalize | -stream] [-lenient [-guid-generator name]] [-stats] [-lang lang] [file ...]")
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	guids, ok := lenientFlags.guids()
+	if !ok || (*stream && (*normalize || guids != nil)) {
+		flags.Usage()
+		return 2
+	}
+	files := flags.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	validator := server.NewValidator()
+	validator.SetLenient(guids)
+	language = server.ResolveLanguage(strings.SplitN(*lang, ".", 2)[0])
+	if *stats {
+		defer startMemoryStats().report(os.Stderr)
+	}
+	status := 0
+	for _, name := range files {
+		var valid bool
+		if *stream {
+			valid = streamFile(validator, name, *contentType)
+		} else {
+			valid = validateFile(validator, name, *contentType, *normalize)
+		}
+		if !valid {
+			status = 1
+		}
+	}
+	return status
+}
+
+func validateFile(validator *server.Validator, name string, contentType string, normalize bool) bool {
+	data, err := readInput(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		return false
+	}
+	doc, err := validator.Decode(inputContentType(name, contentType), data)
+	if err != nil {
+		printDecodeError(name, err)
+		return false
+	}
+	if guid, ok := validator.AssignTransmissionGUID(doc); ok {
+		fmt.Fprintf(os.Stderr, "%s: transmissionGUID %s assigned\n", name, guid)
+	}
+	failure := validator.Validate(doc, normalize)
+	if failure != nil {
+		validator.Explain(failure, doc, language)
+		printFailure(name, failure)
+		return false
+	}
+	if normalize {
+		tkt.JsonPretty(doc.Value, os.Stdout)
+	} else {
+		fmt.Printf("%s: valid\n", name)
+	}
+	return true
+}
+
+func streamFile(validator *server.Validator, name string, contentType string) bool {
+	if err := validator.CheckStream(inputContentType(name, contentType), false); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		return false
+	}
+	r, err := openInput(name)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+		return false
+	}
+	defer r.Close()
+	failure, err := validator.ValidateStream(bufio.NewReader(r))
+	if err != nil {
+		printDecodeError(name, err)
+		return false
+	}
+	if failure != nil {
+		validator.Explain(failure, nil, language)
+		printFailure(name, failure)
+		return false
+	}
+	fmt.Printf("%s: valid\n", name)
+	return true
+}
+
+// inputContentType returns the content type given, or else the one of the file extension.
+func inputContentType(name string, contentType string) string {
+	if contentType == "" {
+		return extensionContentTypes[strings.ToLower(filepath.Ext(name))]
+	}
+	return contentType
+}
+
+func printDecodeError(name string, err error) {
+	if se, ok := err.(*server.SyntaxError); ok && se.Line > 0 {
+		fmt.Fprintf(os.Stderr, "%s:%d:%d: [offset %d] %s\n", name, se.Line, se.Column, se.Offset, se.Message)
+	} else {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", name, err)
+	}
+}
+
+func printFailure(name string, failure *server.ValidationFailure) {
+	for _, leaf := range failure.Leaves() {
+		fmt.Fprintf(os.Stderr, "%s:%d:%d: [offset %d] %s: %s\n", name, leaf.Line, leaf.Column, leaf.Offset,
+			displayPointer(leaf.InstanceLocation), leaf.Explanation)
+		if leaf.Description != "" {
+			fmt.Fprintf(os.Stderr, "    %s\n", leaf.Description)
+		}
+	}
+}
+
+func readInput(name string) ([]byte, error) {
+	if name == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(name)
+}
+
+func openInput(name string) (io.ReadCloser, error) {
+	if name == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(name)
+}
+
+func displayPointer(pointer string) string {
+	if pointer == "" {
+		return "/"
+	}
+	return pointer
+}